@@ -0,0 +1,230 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/360EntSecGroup-Skylar/excelize/v2"
+)
+
+// cellAddress is a cell or range reference which may be qualified by a workbook and a sheet.
+// e.g. "B3", "Sheet2!B3", "'My Sheet'!A1:C4", "sales:Sheet1!A1", "sales:A1", "Sales[Amount]", "InvoiceTotal"
+type cellAddress struct {
+	book  *Spreadsheet
+	sheet string
+	ref   string
+	// qualifier is the "book:sheet!" part written in the reference
+	qualifier string
+	// table is the name of the table if the reference is a structured reference like "Sales[Amount]"
+	table string
+}
+
+// parseCellAddress parses the reference.
+// Unqualified workbook and sheet are resolved to the current workbook and its active sheet.
+func parseCellAddress(s string) *cellAddress {
+	addr := &cellAddress{book: execContext.spreadsheet}
+
+	rest := s
+	bookName := ""
+	sheet := ""
+	if i := indexUnquoted(s, '!'); 0 <= i {
+		rest = s[i+1:]
+		q := s[:i]
+		// sheet names can not contain ':', so it is the workbook name
+		if j := indexUnquoted(q, ':'); 0 <= j {
+			bookName = q[:j]
+			q = q[j+1:]
+		}
+		sheet = unquoteSheetName(q)
+	} else if j := strings.Index(s, ":"); 0 <= j {
+		// "book:A1" if the left side of ':' is an open workbook, otherwise a range
+		// "B:B" and "A1:B2" are ranges even if the workbooks are named "B" and "A1"
+		_, _, _, _, err := parseRangeRef(s, 0, 0)
+		if _, ok := execContext.books[s[:j]]; ok && err != nil {
+			bookName = s[:j]
+			rest = s[j+1:]
+		}
+	}
+
+	if bookName != "" {
+		b, ok := execContext.books[bookName]
+		if !ok {
+			fatalError("workbook '%s' is not open", bookName)
+		}
+		addr.book = b
+		addr.qualifier = bookName + ":"
+	}
+
+	if sheet != "" {
+		if !addr.book.existSheetName(sheet) {
+			fatalError("sheet '%s' not exist", sheet)
+		}
+		addr.sheet = sheet
+		addr.qualifier += quoteSheetName(sheet) + "!"
+	} else {
+		addr.sheet = addr.book.activeSheet
+		if addr.qualifier != "" {
+			addr.qualifier += quoteSheetName(addr.sheet) + "!"
+		}
+	}
+
+	addr.ref = strings.TrimSpace(rest)
+	if isStructuredRef(addr.ref) {
+		addr.resolveTable()
+	}
+	if addr.table == "" && isDefinedNameRef(addr.ref) {
+		addr.resolveName()
+	}
+	return addr
+}
+
+// resolveTable replaces the structured reference like "Sales[Amount]" with the range of the table.
+// The table name which is not found is left as it is.
+func (a *cellAddress) resolveTable() {
+	sheet, ref, ok, err := a.book.resolveTableRef(a.ref)
+	if err != nil {
+		fatalError("%v", err)
+	}
+	if !ok {
+		if strings.Contains(a.ref, "[") {
+			fatalError("table of '%s' not exist", a.ref)
+		}
+		return
+	}
+	// the table may be in the other sheet, so the sheet is always qualified
+	a.table = structuredRefReg.FindStringSubmatch(a.ref)[1]
+	a.sheet = sheet
+	a.ref = ref
+	a.qualifier = a.bookQualifier() + quoteSheetName(sheet) + "!"
+}
+
+// resolveName replaces the defined name like "InvoiceTotal" with the cell or the range.
+// The name scoped to the addressed sheet is preferred to the name scoped to the workbook.
+// The name which is not found is left as it is.
+func (a *cellAddress) resolveName() {
+	sheet, ref, ok, err := a.book.resolveDefinedName(a.ref, a.sheet)
+	if err != nil {
+		fatalError("%v", err)
+	}
+	if !ok {
+		return
+	}
+	a.sheet = sheet
+	a.ref = ref
+	a.qualifier = a.bookQualifier() + quoteSheetName(sheet) + "!"
+}
+
+// bookQualifier returns the "book:" part of the qualifier, or "".
+func (a *cellAddress) bookQualifier() string {
+	if i := indexUnquoted(a.qualifier, ':'); 0 <= i {
+		return a.qualifier[:i+1]
+	}
+	return ""
+}
+
+// indexUnquoted returns the index of the first c which is not enclosed by single quotes.
+func indexUnquoted(s string, c byte) int {
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\'' {
+			quoted = !quoted
+			continue
+		}
+		if !quoted && s[i] == c {
+			return i
+		}
+	}
+	return -1
+}
+
+// unquoteSheetName removes the single quotes like "'My Sheet'".
+func unquoteSheetName(name string) string {
+	name = strings.TrimSpace(name)
+	if 2 <= len(name) && name[0] == '\'' && name[len(name)-1] == '\'' {
+		name = strings.ReplaceAll(name[1:len(name)-1], "''", "'")
+	}
+	return name
+}
+
+// quoteSheetName encloses the sheet name with single quotes if needed.
+func quoteSheetName(name string) string {
+	for _, c := range name {
+		if !unicode.IsLetter(c) && !unicode.IsDigit(c) && c != '_' {
+			return "'" + strings.ReplaceAll(name, "'", "''") + "'"
+		}
+	}
+	return name
+}
+
+func (a *cellAddress) isRange() bool {
+	return isRangeRef(a.ref)
+}
+
+// isCell reports whether the address is a cell like "A1".
+func (a *cellAddress) isCell() bool {
+	_, _, err := excelize.CellNameToCoordinates(a.ref)
+	return err == nil
+}
+
+func (a *cellAddress) getRange() *Range {
+	r := a.book.getSheetRange(a.sheet, a.ref)
+	r.qualifier = a.qualifier
+	return r
+}
+
+// getCellRange returns the range of the address. The range of a cell is the cell only.
+func (a *cellAddress) getCellRange() *Range {
+	if a.isRange() {
+		return a.getRange()
+	}
+	r := a.book.getSheetRange(a.sheet, a.ref+":"+a.ref)
+	r.qualifier = a.qualifier
+	return r
+}
+
+func (a *cellAddress) getValue() string {
+	return a.book.getSheetCellValue(a.sheet, a.ref)
+}
+
+func (a *cellAddress) setValue(v interface{}) {
+	a.book.setSheetCellValue(a.sheet, a.ref, v)
+}
+
+// copyFrom copies the values in the src range to the address.
+// The address is the top left cell, or the range which has the same size as src.
+func (a *cellAddress) copyFrom(src *Range) {
+	var to *Range
+	if a.isRange() {
+		to = a.getRange()
+		if to.width() != src.width() || to.height() != src.height() {
+			fatalError("range '%s' and '%s' are different sizes", src.ref(), to.ref())
+		}
+	} else {
+		to = a.book.getSheetRange(a.sheet, a.ref+":"+a.ref)
+		to.endCol = to.startCol + src.width() - 1
+		to.endRow = to.startRow + src.height() - 1
+	}
+
+	// read all values before writing for overlapped ranges
+	values := src.values()
+	for y, row := range values {
+		for x, v := range row {
+			to.book.setSheetCellValue(to.sheet, to.axis(x, y), typedCellValue(v))
+		}
+	}
+}
+
+// setRowValues sets the values to the cells in the row from the address.
+func (a *cellAddress) setRowValues(values []string) {
+	col, row, err := excelize.CellNameToCoordinates(a.ref)
+	if err != nil {
+		fatalError("cell '%s' set value failed", a.ref)
+	}
+	for i, v := range values {
+		axis, err := excelize.CoordinatesToCellName(col+i, row)
+		if err != nil {
+			fatalError("cell '%s' set value failed", a.ref)
+		}
+		a.book.setSheetCellValue(a.sheet, axis, typedCellValue(v))
+	}
+}
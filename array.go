@@ -0,0 +1,105 @@
+package main
+
+import "fmt"
+
+// Array is an associative array.
+// Keys are kept in insertion order so that 'for (k in a)' is stable.
+// The slots of the deleted keys are left in keys until they are compacted.
+type Array struct {
+	keys []string
+	// index is the position of each key in keys
+	index map[string]int
+	elems map[string]Node
+}
+
+func NewArray() *Array {
+	a := &Array{}
+	a.clear()
+	return a
+}
+
+func (a *Array) get(key string) Node {
+	v, ok := a.elems[key]
+	if !ok {
+		return NewStringExpression("")
+	}
+	return v
+}
+
+func (a *Array) set(key string, value Node) Node {
+	if _, ok := a.elems[key]; !ok {
+		a.index[key] = len(a.keys)
+		a.keys = append(a.keys, key)
+	}
+	a.elems[key] = value
+	return value
+}
+
+func (a *Array) exist(key string) bool {
+	_, ok := a.elems[key]
+	return ok
+}
+
+func (a *Array) delete(key string) {
+	if _, ok := a.elems[key]; !ok {
+		return
+	}
+	delete(a.elems, key)
+	delete(a.index, key)
+	// compact the keys when the half of them are deleted
+	if len(a.index) < len(a.keys)/2 {
+		a.keys = a.keyList()
+		for i, k := range a.keys {
+			a.index[k] = i
+		}
+	}
+}
+
+func (a *Array) clear() {
+	a.keys = make([]string, 0)
+	a.index = make(map[string]int)
+	a.elems = make(map[string]Node)
+}
+
+func (a *Array) length() int {
+	return len(a.elems)
+}
+
+// keyList returns a copy of the keys, so that the array can be modified while iterating.
+func (a *Array) keyList() []string {
+	keys := make([]string, 0, len(a.elems))
+	for i, k := range a.keys {
+		// the slot is deleted if the key is deleted or set again after deleting
+		if j, ok := a.index[k]; ok && j == i {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+func (a *Array) eval() Node {
+	return a
+}
+
+func (a *Array) asNumber() float64 {
+	fatalError("array can not evaluate as a number")
+	return 0
+}
+
+func (a *Array) asString() string {
+	fatalError("array can not evaluate as a string")
+	return ""
+}
+
+func (a *Array) isTruthy() bool {
+	fatalError("array can not evaluate as a truthy")
+	return false
+}
+
+func (a *Array) nodeType() int {
+	return NodeTypeArray
+}
+
+func (a *Array) String() string {
+	return fmt.Sprintf("[Type: Array] (has %d elements)", a.length())
+}
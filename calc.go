@@ -0,0 +1,245 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/360EntSecGroup-Skylar/excelize/v2"
+	"github.com/xuri/efp"
+)
+
+// The formulas are calculated in-process by CalcCellValue of excelize.
+// excelize reads the cached values of the referred cells, so the formula cells which are referred
+// are calculated first, and the results are set to the cells as the cached values.
+// excelize resolves only the defined names of the ranges, so the defined names are replaced with
+// their references or their formulas before calculating.
+
+var formulaErrorReg = regexp.MustCompile(`^#(NULL!|DIV/0!|VALUE!|REF!|NAME\?|NUM!|N/A)$`)
+
+// formulaCalc calculates the formulas in the workbook.
+type formulaCalc struct {
+	book    *Spreadsheet
+	results map[string]string
+	running map[string]bool
+	// formulas is the cells which have a formula of each sheet
+	formulas map[string][]string
+}
+
+func newFormulaCalc(book *Spreadsheet) *formulaCalc {
+	return &formulaCalc{
+		book:     book,
+		results:  make(map[string]string),
+		running:  make(map[string]bool),
+		formulas: make(map[string][]string),
+	}
+}
+
+// calcAll calculates all formulas in the workbook, and returns the number of them.
+func (s *Spreadsheet) calcAll() int {
+	c := newFormulaCalc(s)
+	n := 0
+	for _, sheet := range s.getSheetList() {
+		for _, axis := range c.sheetFormulas(sheet) {
+			c.cellValue(sheet, axis)
+			n++
+		}
+	}
+	return n
+}
+
+func (c *formulaCalc) sheetFormulas(sheet string) []string {
+	axes, ok := c.formulas[sheet]
+	if !ok {
+		axes = c.book.getSheetFormulaAxes(sheet)
+		c.formulas[sheet] = axes
+	}
+	return axes
+}
+
+// cellValue returns the value of the cell like "12", "TRUE" or "#DIV/0!". The formula of the cell is calculated.
+func (c *formulaCalc) cellValue(sheet string, axis string) string {
+	key := sheet + "!" + axis
+	if v, ok := c.results[key]; ok {
+		return v
+	}
+	if f := c.book.getSheetCellFormula(sheet, axis); f != "" {
+		return c.calcCell(sheet, axis, f)
+	}
+	return c.book.getSheetCellRawValue(sheet, axis)
+}
+
+// calcCell calculates the formula of the cell, and sets the result as the cached value.
+func (c *formulaCalc) calcCell(sheet string, axis string, formula string) string {
+	key := sheet + "!" + axis
+	if c.running[key] {
+		fatalError("calc(): circular reference in '%s!%s'", quoteSheetName(sheet), axis)
+	}
+	c.running[key] = true
+	expanded := c.expandNames(formula, sheet, map[string]bool{})
+	c.calcRefs(expanded, sheet)
+	v := c.book.calcSheetFormula(sheet, axis, formula, expanded)
+	delete(c.running, key)
+
+	c.results[key] = v
+	c.book.setSheetCellValue(sheet, axis, formulaCellValue(v))
+	return v
+}
+
+// calcRefs calculates the formula cells which the formula refers.
+func (c *formulaCalc) calcRefs(formula string, sheet string) {
+	mapFormulaRefs(formula, func(refSheet string, ref *formulaRef) bool {
+		if refSheet == "" {
+			refSheet = sheet
+		}
+		if !c.book.existSheetName(refSheet) {
+			return true
+		}
+		if !ref.area {
+			axis, _ := excelize.CoordinatesToCellName(ref.col[0], ref.row[0])
+			c.cellValue(refSheet, axis)
+			return true
+		}
+		for _, axis := range c.sheetFormulas(refSheet) {
+			col, row, _ := excelize.CellNameToCoordinates(axis)
+			if (ref.col[0] == 0 || ref.col[0] <= col && col <= ref.col[1]) && (ref.row[0] == 0 || ref.row[0] <= row && row <= ref.row[1]) {
+				c.cellValue(refSheet, axis)
+			}
+		}
+		return true
+	})
+}
+
+// expandNames replaces the defined names seen from the sheet with their references, or their formulas in parentheses.
+// The unknown names are left for excelize to be "#NAME?".
+func (c *formulaCalc) expandNames(formula string, sheet string, expanding map[string]bool) string {
+	ps := efp.ExcelParser()
+	tokens := ps.Parse(formula)
+	changed := false
+	for i, t := range tokens {
+		if t.TType != efp.TokenTypeOperand || t.TSubType != efp.TokenSubTypeRange {
+			continue
+		}
+		nameSheet, name := sheet, t.TValue
+		if j := indexUnquoted(name, '!'); 0 <= j {
+			nameSheet, name = unquoteSheetName(name[:j]), name[j+1:]
+		}
+		if !isDefinedNameRef(name) {
+			continue
+		}
+		n := c.book.getDefinedName(name, nameSheet)
+		if n == nil {
+			continue
+		}
+		refersTo := strings.TrimPrefix(n.refersTo, "=")
+		if _, _, err := c.book.parseNameRef(refersTo); err == nil {
+			tokens[i].TValue = refersTo
+		} else {
+			key := n.scope + "!" + strings.ToUpper(n.name)
+			if expanding[key] {
+				fatalError("calc(): circular reference in name '%s'", n.name)
+			}
+			expanding[key] = true
+			tokens[i].TValue = "(" + c.expandNames(refersTo, sheet, expanding) + ")"
+			delete(expanding, key)
+		}
+		changed = true
+	}
+	if !changed {
+		return formula
+	}
+	return renderFormula(tokens)
+}
+
+// renderFormula returns the formula of the tokens. The quotes in the strings are escaped unlike Render of efp.
+func renderFormula(tokens []efp.Token) string {
+	var b strings.Builder
+	for _, t := range tokens {
+		switch {
+		case t.TType == efp.TokenTypeFunction && t.TSubType == efp.TokenSubTypeStart:
+			b.WriteString(t.TValue + "(")
+		case t.TType == efp.TokenTypeSubexpression && t.TSubType == efp.TokenSubTypeStart:
+			b.WriteString("(")
+		case t.TSubType == efp.TokenSubTypeStop:
+			b.WriteString(")")
+		case t.TType == efp.TokenTypeOperand && t.TSubType == efp.TokenSubTypeText:
+			b.WriteString(`"` + strings.ReplaceAll(t.TValue, `"`, `""`) + `"`)
+		case t.TType == efp.TokenTypeOperatorInfix && t.TSubType == efp.TokenSubTypeIntersection:
+			b.WriteString(" ")
+		default:
+			b.WriteString(t.TValue)
+		}
+	}
+	return b.String()
+}
+
+// calcSheetFormula calculates the formula of the cell by excelize.
+// The expanded formula is calculated instead of the formula of the cell, and the formula of the cell is kept.
+// The errors of excelize which are not the errors of Excel are "#NAME?" for the unknown functions, otherwise "#VALUE!".
+func (s *Spreadsheet) calcSheetFormula(sheet string, axis string, formula string, expanded string) string {
+	var v string
+	var err error
+	calc := func() { v, err = s.file.CalcCellValue(sheet, axis) }
+	if expanded == formula {
+		calc()
+	} else {
+		s.withSheetCellFormula(sheet, axis, expanded, calc)
+	}
+
+	if err != nil {
+		msg := err.Error()
+		if formulaErrorReg.MatchString(msg) {
+			return msg
+		}
+		if strings.HasPrefix(msg, "not support ") {
+			return "#NAME?"
+		}
+		return "#VALUE!"
+	}
+	// excelize leaves the errors of the floating point like "3.300000000000001"
+	// Excel keeps the 15 significant digits
+	if f, ok := maybeNumber(v); ok {
+		f, _ = strconv.ParseFloat(strconv.FormatFloat(f, 'g', 15, 64), 64)
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	return v
+}
+
+// withSheetCellFormula calls f while the formula of the cell is replaced,
+// because CalcCellValue calculates the formula of the cell.
+func (s *Spreadsheet) withSheetCellFormula(sheet string, axis string, formula string, f func()) {
+	_, row, _ := excelize.CellNameToCoordinates(axis)
+	if ws := s.file.Sheet[s.getSheetParts()[sheet]]; ws != nil {
+		for i := range ws.SheetData.Row {
+			r := &ws.SheetData.Row[i]
+			if r.R != row {
+				continue
+			}
+			for j := range r.C {
+				if cell := &r.C[j]; strings.EqualFold(cell.R, axis) && cell.F != nil {
+					// the shared formula is also restored
+					saved := *cell.F
+					cell.F.Content, cell.F.T, cell.F.Ref, cell.F.Si = formula, "", "", ""
+					f()
+					*cell.F = saved
+					return
+				}
+			}
+		}
+	}
+	f()
+}
+
+// formulaCellValue returns the result of the formula as the value to set to a cell.
+func formulaCellValue(v string) interface{} {
+	if f, ok := maybeNumber(v); ok {
+		return f
+	}
+	switch v {
+	case "TRUE":
+		return true
+	case "FALSE":
+		return false
+	}
+	return v
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/360EntSecGroup-Skylar/excelize/v2"
+)
+
+const (
+	relTypeComments   = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/comments"
+	relTypeVMLDrawing = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/vmlDrawing"
+)
+
+var vmlShapeReg = regexp.MustCompile(`(?s)<v:shape\b.*?</v:shape>`)
+var vmlAnchorReg = regexp.MustCompile(`<x:Row>(\d+)</x:Row>\s*<x:Column>(\d+)</x:Column>`)
+
+// commentInfo is a comment(note) of a cell.
+type commentInfo struct {
+	cell   string
+	author string
+	text   string
+}
+
+// commentsXML is the part of the comments "xl/commentsN.xml".
+type commentsXML struct {
+	Authors  []string `xml:"authors>author"`
+	Comments []struct {
+		Ref      string `xml:"ref,attr"`
+		AuthorID int    `xml:"authorId,attr"`
+		Text     struct {
+			T    string   `xml:"t"`
+			Runs []string `xml:"r>t"`
+		} `xml:"text"`
+	} `xml:"commentList>comment"`
+}
+
+// getSheetComments returns the comments of the sheet in the order of the rows and the columns.
+func (s *Spreadsheet) getSheetComments(sheet string) []*commentInfo {
+	part := s.getSheetRelationship(sheet, relTypeComments)
+	if part == "" {
+		return nil
+	}
+	// the comments changed by excelize are not written to the file list until saving
+	content := s.file.XLSX[part]
+	if c := s.file.Comments[part]; c != nil {
+		content, _ = xml.Marshal(c)
+	}
+	var x commentsXML
+	if err := xml.NewDecoder(bytes.NewReader(content)).Decode(&x); err != nil {
+		return nil
+	}
+
+	comments := make([]*commentInfo, 0, len(x.Comments))
+	for _, c := range x.Comments {
+		info := &commentInfo{cell: c.Ref, text: c.Text.T + strings.Join(c.Text.Runs, "")}
+		// excelize writes the empty first line for the comments without the author
+		if c.AuthorID < len(x.Authors) && (len(c.Text.Runs) == 0 || c.Text.Runs[0] != "") {
+			info.author = x.Authors[c.AuthorID]
+		}
+		// Excel writes the author like "Author:" as the first line of the text
+		if 0 < len(c.Text.Runs) && strings.HasSuffix(c.Text.Runs[0], ":") {
+			if rest := strings.TrimPrefix(info.text, c.Text.Runs[0]); strings.HasPrefix(rest, "\n") {
+				info.author = strings.TrimSuffix(c.Text.Runs[0], ":")
+				info.text = rest[1:]
+			}
+		}
+		comments = append(comments, info)
+	}
+	sort.SliceStable(comments, func(i, j int) bool {
+		c1, r1, _ := excelize.CellNameToCoordinates(comments[i].cell)
+		c2, r2, _ := excelize.CellNameToCoordinates(comments[j].cell)
+		if r1 != r2 {
+			return r1 < r2
+		}
+		return c1 < c2
+	})
+	return comments
+}
+
+// getSheetComment returns the comment of the cell, or nil.
+func (s *Spreadsheet) getSheetComment(sheet string, axis string) *commentInfo {
+	for _, c := range s.getSheetComments(sheet) {
+		if strings.EqualFold(c.cell, axis) {
+			return c
+		}
+	}
+	return nil
+}
+
+// setSheetComment replaces the comment of the cell. The empty text removes the comment.
+// The author is written as the first line of the text like Excel.
+func (s *Spreadsheet) setSheetComment(sheet string, axis string, text string, author string) {
+	col, row, err := excelize.CellNameToCoordinates(axis)
+	if err != nil {
+		fatalError("cell '%s' is invalid", axis)
+	}
+	exist := s.getSheetRelationship(sheet, relTypeComments) != ""
+	if exist {
+		s.removeSheetComment(sheet, axis, col, row)
+	}
+	if text == "" {
+		return
+	}
+
+	format := map[string]string{"author": "", "text": text}
+	if author != "" {
+		format = map[string]string{"author": author + ":", "text": "\n" + text}
+	}
+	b, _ := json.Marshal(format)
+	if err := s.file.AddComment(sheet, axis, string(b)); err != nil {
+		fatalError("cell '%s' set comment failed (%v)", axis, err)
+	}
+	if c := s.file.Comments[s.getSheetRelationship(sheet, relTypeComments)]; !exist && c != nil && 0 < len(c.Authors) {
+		// excelize adds the first line to the authors of the new comments part
+		c.Authors[0].Author = author
+	}
+
+	// excelize may add the shapes of the existing comments again
+	vml := s.file.VMLDrawing[s.getSheetRelationship(sheet, relTypeVMLDrawing)]
+	if vml == nil {
+		return
+	}
+	last := map[string]int{}
+	for i, shape := range vml.Shape {
+		last[vmlAnchorReg.FindString(shape.Val)] = i
+	}
+	shapes := vml.Shape[:0]
+	for i, shape := range vml.Shape {
+		if last[vmlAnchorReg.FindString(shape.Val)] == i {
+			shapes = append(shapes, shape)
+		}
+	}
+	vml.Shape = shapes
+}
+
+// removeSheetComment removes the comment of the cell and its shape.
+func (s *Spreadsheet) removeSheetComment(sheet string, axis string, col int, row int) {
+	// GetComments loads the comments of the file into excelize
+	s.file.GetComments()
+	if c := s.file.Comments[s.getSheetRelationship(sheet, relTypeComments)]; c != nil {
+		list := c.CommentList.Comment[:0]
+		for _, comment := range c.CommentList.Comment {
+			if !strings.EqualFold(comment.Ref, axis) {
+				list = append(list, comment)
+			}
+		}
+		c.CommentList.Comment = list
+	}
+
+	part := s.getSheetRelationship(sheet, relTypeVMLDrawing)
+	isCell := func(shape string) bool {
+		m := vmlAnchorReg.FindStringSubmatch(shape)
+		return m != nil && m[1] == strconv.Itoa(row-1) && m[2] == strconv.Itoa(col-1)
+	}
+	if vml := s.file.VMLDrawing[part]; vml != nil {
+		shapes := vml.Shape[:0]
+		for _, shape := range vml.Shape {
+			if !isCell(shape.Val) {
+				shapes = append(shapes, shape)
+			}
+		}
+		vml.Shape = shapes
+	}
+	if d := s.file.DecodeVMLDrawing[part]; d != nil {
+		shapes := d.Shape[:0]
+		for _, shape := range d.Shape {
+			if !isCell(shape.Val) {
+				shapes = append(shapes, shape)
+			}
+		}
+		d.Shape = shapes
+	}
+	if content, ok := s.file.XLSX[part]; ok {
+		s.file.XLSX[part] = vmlShapeReg.ReplaceAllFunc(content, func(shape []byte) []byte {
+			if isCell(string(shape)) {
+				return nil
+			}
+			return shape
+		})
+	}
+}
+
+// moveSheetComments moves the comments of the sheet to the cells which f returns for the columns and the rows.
+// The comment is removed if f returns false.
+func (s *Spreadsheet) moveSheetComments(sheet string, f func(col int, row int) (int, int, bool)) {
+	type move struct {
+		comment *commentInfo
+		axis    string
+		ok      bool
+	}
+	var moves []move
+	for _, c := range s.getSheetComments(sheet) {
+		col, row, err := excelize.CellNameToCoordinates(c.cell)
+		if err != nil {
+			continue
+		}
+		toCol, toRow, ok := f(col, row)
+		if ok && toCol == col && toRow == row {
+			continue
+		}
+		axis, _ := excelize.CoordinatesToCellName(toCol, toRow)
+		moves = append(moves, move{comment: c, axis: axis, ok: ok})
+		s.removeSheetComment(sheet, c.cell, col, row)
+	}
+	// the comments are added after removing all to move onto the cells of the other moved comments
+	for _, m := range moves {
+		if m.ok {
+			s.setSheetComment(sheet, m.axis, m.comment.text, m.comment.author)
+		}
+	}
+}
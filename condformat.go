@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/360EntSecGroup-Skylar/excelize/v2"
+)
+
+// The conditional formatting is specified by a rule like "> 100", "between 1 and 10", "top 10%",
+// "above average", "duplicate" or a formula like "=$B2>100".
+// The relative references of the formula are relative to the top left cell of the range like Excel.
+
+// condFormat is the conditional format for excelize.SetConditionalFormat.
+type condFormat struct {
+	Type         string `json:"type"`
+	Criteria     string `json:"criteria"`
+	Format       int    `json:"format"`
+	Value        string `json:"value,omitempty"`
+	Minimum      string `json:"minimum,omitempty"`
+	Maximum      string `json:"maximum,omitempty"`
+	Percent      bool   `json:"percent,omitempty"`
+	AboveAverage bool   `json:"above_average,omitempty"`
+	MinType      string `json:"min_type,omitempty"`
+	MidType      string `json:"mid_type,omitempty"`
+	MaxType      string `json:"max_type,omitempty"`
+	MidValue     string `json:"mid_value,omitempty"`
+	MinColor     string `json:"min_color,omitempty"`
+	MidColor     string `json:"mid_color,omitempty"`
+	MaxColor     string `json:"max_color,omitempty"`
+	BarColor     string `json:"bar_color,omitempty"`
+}
+
+var condOperators = map[string]string{
+	"==": "==",
+	"!=": "!=",
+	"<>": "!=",
+	">":  ">",
+	">=": ">=",
+	"<":  "<",
+	"<=": "<=",
+}
+
+// parseCondRule parses the rule of the conditional formatting for the range.
+func parseCondRule(rule string, r *Range) (*condFormat, error) {
+	rule = strings.TrimSpace(rule)
+	if strings.HasPrefix(rule, "=") && !strings.HasPrefix(rule, "==") {
+		return &condFormat{Type: "formula", Criteria: rule[1:]}, nil
+	}
+
+	fields := strings.Fields(rule)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("empty rule")
+	}
+	op := strings.ToLower(fields[0])
+	rest := strings.TrimSpace(rule[len(fields[0]):])
+	if op == "not" && 2 <= len(fields) && strings.EqualFold(fields[1], "between") {
+		op = "not between"
+		rest = strings.TrimSpace(rest[len(fields[1]):])
+	}
+
+	switch op {
+	case "between", "not between":
+		i := strings.Index(strings.ToLower(rest), " and ")
+		if i < 0 {
+			return nil, fmt.Errorf("'%s' needs 2 values like 'between 1 and 10'", rule)
+		}
+		return &condFormat{Type: "cell", Criteria: op,
+			Minimum: condValue(rest[:i]), Maximum: condValue(rest[i+5:])}, nil
+	case "top", "bottom":
+		n, percent := strings.TrimSuffix(rest, "%"), strings.HasSuffix(rest, "%")
+		if n == "" {
+			n = "10"
+		}
+		if v, err := strconv.Atoi(n); err != nil || v < 1 {
+			return nil, fmt.Errorf("'%s' is invalid rank", rest)
+		}
+		if op == "bottom" {
+			// excelize can not write the bottom rule, so it is written as the formula
+			return &condFormat{Type: "formula", Criteria: bottomFormula(r, n, percent)}, nil
+		}
+		return &condFormat{Type: "top", Criteria: "=", Value: n, Percent: percent}, nil
+	case "above", "below":
+		if !strings.EqualFold(rest, "average") {
+			return nil, fmt.Errorf("'%s' is invalid rule", rule)
+		}
+		return &condFormat{Type: "average", Criteria: "=", AboveAverage: op == "above"}, nil
+	case "duplicate", "unique":
+		if rest != "" {
+			return nil, fmt.Errorf("'%s' is invalid rule", rule)
+		}
+		return &condFormat{Type: op, Criteria: "="}, nil
+	}
+
+	if c, ok := condOperators[op]; ok {
+		if rest == "" {
+			return nil, fmt.Errorf("'%s' needs a value", rule)
+		}
+		return &condFormat{Type: "cell", Criteria: c, Value: condValue(rest)}, nil
+	}
+	// the operator may not be separated from the value like ">100"
+	for _, c := range []string{">=", "<=", "<>", "!=", "==", ">", "<"} {
+		if strings.HasPrefix(rule, c) {
+			return parseCondRule(c+" "+rule[len(c):], r)
+		}
+	}
+	return nil, fmt.Errorf("'%s' is invalid rule", rule)
+}
+
+// condValue returns the value of the rule as a formula.
+// A number and a formula like "=$B$1" are used as is, and the others are quoted as a string.
+func condValue(v string) string {
+	v = strings.TrimSpace(v)
+	if strings.HasPrefix(v, "=") {
+		return v[1:]
+	}
+	if _, ok := maybeNumber(v); ok {
+		return v
+	}
+	if 2 <= len(v) && v[0] == '"' && v[len(v)-1] == '"' {
+		return v
+	}
+	return `"` + strings.ReplaceAll(v, `"`, `""`) + `"`
+}
+
+// bottomFormula returns the formula which matches the bottom n or n% values of the range.
+func bottomFormula(r *Range, n string, percent bool) string {
+	cell := r.axis(0, 0)
+	area := formulaRef{
+		col:    [2]int{r.startCol, r.endCol},
+		row:    [2]int{r.startRow, r.endRow},
+		absCol: [2]bool{true, true},
+		absRow: [2]bool{true, true},
+		area:   true,
+	}
+	if percent {
+		return fmt.Sprintf("AND(ISNUMBER(%s),%s<=PERCENTILE(%s,%s/100))", cell, cell, area, n)
+	}
+	return fmt.Sprintf("AND(ISNUMBER(%s),%s<=SMALL(%s,%s))", cell, cell, area, n)
+}
+
+// addSheetCondFormat adds the conditional formatting which applies the style to the cells matching the rule.
+func (s *Spreadsheet) addSheetCondFormat(r *Range, rule string, spec *styleSpec) error {
+	format, err := parseCondRule(rule, r)
+	if err != nil {
+		return err
+	}
+	st := &excelize.Style{}
+	spec.apply(st, &excelize.Font{})
+	b, _ := json.Marshal(st)
+	if format.Format, err = s.file.NewConditionalStyle(string(b)); err != nil {
+		return err
+	}
+	return s.setSheetCondFormat(r, format)
+}
+
+// addSheetColorScale adds the color scale from the color of the minimum to the color of the maximum.
+// The middle color is of the 50th percentile if the 3 colors are given.
+func (s *Spreadsheet) addSheetColorScale(r *Range, colors []string) error {
+	if len(colors) < 2 || 3 < len(colors) {
+		return fmt.Errorf("color scale needs 2 or 3 colors")
+	}
+	for i, c := range colors {
+		color, ok := styleColor(c)
+		if !ok {
+			return fmt.Errorf("'%s' is invalid color", c)
+		}
+		colors[i] = color
+	}
+	format := &condFormat{Type: "2_color_scale", Criteria: "=", MinType: "min", MaxType: "max",
+		MinColor: colors[0], MaxColor: colors[len(colors)-1]}
+	if len(colors) == 3 {
+		format.Type = "3_color_scale"
+		format.MidType = "percentile"
+		format.MidValue = "50"
+		format.MidColor = colors[1]
+	}
+	return s.setSheetCondFormat(r, format)
+}
+
+// addSheetDataBar adds the data bars of the color.
+func (s *Spreadsheet) addSheetDataBar(r *Range, color string) error {
+	c, ok := styleColor(color)
+	if !ok {
+		return fmt.Errorf("'%s' is invalid color", color)
+	}
+	return s.setSheetCondFormat(r, &condFormat{Type: "data_bar", Criteria: "=", MinType: "min", MaxType: "max", BarColor: c})
+}
+
+func (s *Spreadsheet) setSheetCondFormat(r *Range, format *condFormat) error {
+	b, _ := json.Marshal([]*condFormat{format})
+	return s.file.SetConditionalFormat(r.sheet, r.sqref(), string(b))
+}
+
+// adjustSheetCondFormats adjusts the ranges and the formulas of the conditional formats of the sheet
+// for inserting(offset > 0) or deleting(offset < 0) the rows or the columns at num.
+// The conditional formats of the deleted ranges are removed.
+func (s *Spreadsheet) adjustSheetCondFormats(sheet string, rows bool, num int, offset int) {
+	ws := s.file.Sheet[s.getSheetParts()[sheet]]
+	if ws == nil {
+		return
+	}
+	list := ws.ConditionalFormatting[:0]
+	for _, cf := range ws.ConditionalFormatting {
+		if cf.SQRef = adjustSqref(cf.SQRef, sheet, rows, num, offset); cf.SQRef == "" {
+			continue
+		}
+		for _, rule := range cf.CfRule {
+			for i, formula := range rule.Formula {
+				rule.Formula[i] = adjustFormula(formula, sheet, sheet, rows, num, offset)
+			}
+		}
+		list = append(list, cf)
+	}
+	ws.ConditionalFormatting = list
+}
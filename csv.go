@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/360EntSecGroup-Skylar/excelize/v2"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/transform"
+)
+
+// CSV is read and written as RFC 4180, where a quoted field can contain the delimiters, the quotes("") and the newlines.
+// The format is specified in the syntax of the style like "delim=tab;quote=single;encoding=shift_jis;crlf;bom".
+
+// csvOptions is the format of CSV.
+type csvOptions struct {
+	delim rune
+	// quote is 0 if the fields are never quoted
+	quote rune
+	// encoding is nil for UTF-8
+	encoding encoding.Encoding
+	crlf     bool
+	bom      bool
+	// text keeps the imported values as strings
+	text bool
+}
+
+var csvCharNames = map[string]rune{
+	"comma":     ',',
+	"tab":       '\t',
+	"semicolon": ';',
+	"pipe":      '|',
+	"space":     ' ',
+	"double":    '"',
+	"single":    '\'',
+	"none":      0,
+}
+
+// parseCSVOptions parses the options of CSV. The delimiter is tab for the path like "data.tsv", otherwise comma.
+func parseCSVOptions(spec string, path string) (*csvOptions, error) {
+	opts := &csvOptions{delim: ',', quote: '"'}
+	if strings.HasSuffix(strings.ToLower(path), ".tsv") {
+		opts.delim = '\t'
+	}
+	for _, item := range splitStyleSpec(spec) {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		key, value := item, ""
+		if i := strings.IndexByte(item, '='); i >= 0 {
+			key = strings.TrimSpace(item[:i])
+			value = unquoteStyleValue(strings.TrimSpace(item[i+1:]))
+		}
+		switch strings.ToLower(key) {
+		case "delim":
+			c, ok := csvChar(value)
+			if !ok || c == 0 || c == '\r' || c == '\n' {
+				return nil, fmt.Errorf("'%s' is invalid delimiter", value)
+			}
+			opts.delim = c
+		case "quote":
+			c, ok := csvChar(value)
+			if !ok || c == '\r' || c == '\n' {
+				return nil, fmt.Errorf("'%s' is invalid quote", value)
+			}
+			opts.quote = c
+		case "encoding":
+			e, err := textEncoding(value)
+			if err != nil {
+				return nil, err
+			}
+			opts.encoding = e
+		case "crlf":
+			opts.crlf = true
+		case "bom":
+			opts.bom = true
+		case "text":
+			opts.text = true
+		default:
+			return nil, fmt.Errorf("'%s' is unknown option of csv", key)
+		}
+	}
+	if opts.delim == opts.quote {
+		return nil, fmt.Errorf("delimiter and quote must be different")
+	}
+	return opts, nil
+}
+
+// csvChar returns the character of the name like "tab" or a character like "\t" or ";".
+func csvChar(s string) (rune, bool) {
+	if c, ok := csvCharNames[strings.ToLower(s)]; ok {
+		return c, true
+	}
+	if s == `\t` {
+		return '\t', true
+	}
+	if utf8.RuneCountInString(s) != 1 {
+		return 0, false
+	}
+	c, _ := utf8.DecodeRuneInString(s)
+	return c, true
+}
+
+// textEncoding returns the encoding of the name like "shift_jis", "euc-jp" or "utf-16le", or nil for UTF-8.
+func textEncoding(name string) (encoding.Encoding, error) {
+	switch strings.ToLower(name) {
+	case "", "utf-8", "utf8":
+		return nil, nil
+	}
+	e, err := htmlindex.Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("'%s' is unknown encoding", name)
+	}
+	return e, nil
+}
+
+// decodeReader returns the reader which decodes the text of the encoding to UTF-8.
+func decodeReader(r io.Reader, e encoding.Encoding) io.Reader {
+	if e == nil {
+		return r
+	}
+	return transform.NewReader(r, e.NewDecoder())
+}
+
+// skipBOM skips the byte order mark at the beginning of the input.
+func skipBOM(r *bufio.Reader) {
+	if c, _, err := r.ReadRune(); err == nil && c != '\uFEFF' {
+		r.UnreadRune()
+	}
+}
+
+// readCSVRecord reads a record of CSV, and returns the fields and the text of the record without the newline.
+// ok is false if the input reached EOF.
+func readCSVRecord(r *bufio.Reader, delim rune, quote rune) (fields []string, text string, ok bool, err error) {
+	var record, field strings.Builder
+	quoted := false
+	started := false
+	for {
+		c, _, e := r.ReadRune()
+		if e == io.EOF {
+			if !started {
+				return nil, "", false, nil
+			}
+			if quoted {
+				return nil, "", false, fmt.Errorf("quoted field is not closed")
+			}
+			break
+		}
+		if e != nil {
+			return nil, "", false, e
+		}
+		started = true
+
+		if quoted {
+			record.WriteRune(c)
+			if c != quote {
+				field.WriteRune(c)
+				continue
+			}
+			// "" is a quote in the quoted field
+			if next, _, e := r.ReadRune(); e == nil && next == quote {
+				record.WriteRune(next)
+				field.WriteRune(quote)
+				continue
+			} else if e == nil {
+				r.UnreadRune()
+			}
+			quoted = false
+			continue
+		}
+
+		if c == '\n' {
+			break
+		}
+		if c == '\r' {
+			if next, _, e := r.ReadRune(); e == nil && next == '\n' {
+				break
+			} else if e == nil {
+				r.UnreadRune()
+			}
+		}
+		record.WriteRune(c)
+		switch {
+		case c == delim:
+			fields = append(fields, field.String())
+			field.Reset()
+		case c == quote && quote != 0 && field.Len() == 0:
+			quoted = true
+		default:
+			field.WriteRune(c)
+		}
+	}
+
+	text = record.String()
+	if text == "" {
+		return []string{}, "", true, nil
+	}
+	return append(fields, field.String()), text, true, nil
+}
+
+// writeCSVRecord writes the fields as a record of CSV.
+// The field which contains the delimiter, the quote or the newlines is quoted.
+func writeCSVRecord(w io.Writer, fields []string, opts *csvOptions) error {
+	var b strings.Builder
+	for i, f := range fields {
+		if 0 < i {
+			b.WriteRune(opts.delim)
+		}
+		if opts.quote != 0 && strings.ContainsAny(f, string([]rune{opts.delim, opts.quote, '\r', '\n'})) {
+			q := string(opts.quote)
+			b.WriteString(q + strings.ReplaceAll(f, q, q+q) + q)
+		} else {
+			b.WriteString(f)
+		}
+	}
+	if opts.crlf {
+		b.WriteString("\r\n")
+	} else {
+		b.WriteString("\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// exportCSV writes the values of the range to the path as CSV. The path "-" writes to the output.
+// The range is nil for the empty sheet. Return the number of the records.
+func (s *Spreadsheet) exportCSV(r *Range, path string, opts *csvOptions) (int, error) {
+	var w io.Writer = execContext.out
+	if path != "-" {
+		f, err := os.Create(path)
+		if err != nil {
+			return 0, err
+		}
+		defer f.Close()
+		w = f
+	}
+	bw := bufio.NewWriter(w)
+	w = bw
+	var tw *transform.Writer
+	if opts.encoding != nil {
+		tw = transform.NewWriter(bw, opts.encoding.NewEncoder())
+		w = tw
+	}
+	if opts.bom {
+		if _, err := io.WriteString(w, "\uFEFF"); err != nil {
+			return 0, err
+		}
+	}
+
+	n := 0
+	if r != nil {
+		for _, row := range s.getRangeValues(r) {
+			if err := writeCSVRecord(w, row, opts); err != nil {
+				return 0, err
+			}
+		}
+		n = r.height()
+	}
+	if tw != nil {
+		if err := tw.Close(); err != nil {
+			return 0, err
+		}
+	}
+	return n, bw.Flush()
+}
+
+// getSheetUsedRange returns the range from A1 to the last cell of the sheet, or nil if the sheet is empty.
+func (s *Spreadsheet) getSheetUsedRange(sheet string) *Range {
+	d := s.getSheetDimension(sheet)
+	if d.rows == 0 || d.cols == 0 {
+		return nil
+	}
+	return &Range{book: s, sheet: sheet, startCol: 1, startRow: 1, endCol: d.cols, endRow: d.rows}
+}
+
+// importCSV reads CSV of the path to the cells from the address. The values like numbers are set as numbers
+// unless the text option is specified. Return the number of the records.
+func (s *Spreadsheet) importCSV(a *cellAddress, path string, opts *csvOptions) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	col, row, err := excelize.CellNameToCoordinates(a.ref)
+	if err != nil {
+		return 0, err
+	}
+	r := bufio.NewReader(decodeReader(f, opts.encoding))
+	skipBOM(r)
+	n := 0
+	for {
+		fields, _, ok, err := readCSVRecord(r, opts.delim, opts.quote)
+		if err != nil {
+			return n, fmt.Errorf("record %d is invalid (%v)", n+1, err)
+		}
+		if !ok {
+			return n, nil
+		}
+		for i, v := range fields {
+			axis, err := excelize.CoordinatesToCellName(col+i, row+n)
+			if err != nil {
+				return n, err
+			}
+			if opts.text {
+				s.setSheetCellValue(a.sheet, axis, v)
+			} else {
+				s.setSheetCellValue(a.sheet, axis, typedCellValue(v))
+			}
+		}
+		n++
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/360EntSecGroup-Skylar/excelize/v2"
+)
+
+// Date and time values are represented as seconds since the Unix epoch like awk.
+// Excel dates are serial numbers of the days without time zone,
+// so they are converted through the wall clock in the local time zone.
+
+// epochToTime converts the epoch seconds to the local time.
+func epochToTime(t float64) time.Time {
+	sec, frac := math.Modf(t)
+	return time.Unix(int64(sec), int64(frac*1e9)).In(time.Local)
+}
+
+// timeToEpoch converts the time to the epoch seconds.
+func timeToEpoch(t time.Time) float64 {
+	return float64(t.UnixNano()) / 1e9
+}
+
+// epochToExcelTime converts the epoch seconds to the time which has the local wall clock in UTC for excelize.
+func epochToExcelTime(t float64) time.Time {
+	l := epochToTime(t)
+	return time.Date(l.Year(), l.Month(), l.Day(), l.Hour(), l.Minute(), l.Second(), l.Nanosecond(), time.UTC)
+}
+
+// serialToEpoch converts the Excel serial date to the epoch seconds.
+func serialToEpoch(serial float64, date1904 bool) (float64, error) {
+	u, err := excelize.ExcelDateToTime(serial, date1904)
+	if err != nil {
+		return 0, err
+	}
+	u = u.Round(time.Second)
+	l := time.Date(u.Year(), u.Month(), u.Day(), u.Hour(), u.Minute(), u.Second(), 0, time.Local)
+	return timeToEpoch(l), nil
+}
+
+// timeToSerial returns the Excel serial date of the wall clock of the time in the workbook.
+func (s *Spreadsheet) timeToSerial(t time.Time) float64 {
+	base := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+	if s.isDate1904() {
+		base = time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	u := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+	return u.Sub(base).Hours() / 24
+}
+
+// strftime formats the time like C's strftime.
+func strftime(format string, t time.Time) string {
+	var b strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || len(format) <= i+1 {
+			b.WriteByte(format[i])
+			continue
+		}
+		i++
+		switch format[i] {
+		case 'Y':
+			fmt.Fprintf(&b, "%04d", t.Year())
+		case 'y':
+			fmt.Fprintf(&b, "%02d", t.Year()%100)
+		case 'm':
+			fmt.Fprintf(&b, "%02d", int(t.Month()))
+		case 'd':
+			fmt.Fprintf(&b, "%02d", t.Day())
+		case 'e':
+			fmt.Fprintf(&b, "%2d", t.Day())
+		case 'H':
+			fmt.Fprintf(&b, "%02d", t.Hour())
+		case 'I':
+			h := t.Hour() % 12
+			if h == 0 {
+				h = 12
+			}
+			fmt.Fprintf(&b, "%02d", h)
+		case 'M':
+			fmt.Fprintf(&b, "%02d", t.Minute())
+		case 'S':
+			fmt.Fprintf(&b, "%02d", t.Second())
+		case 'p':
+			b.WriteString(t.Format("PM"))
+		case 'j':
+			fmt.Fprintf(&b, "%03d", t.YearDay())
+		case 'a':
+			b.WriteString(t.Format("Mon"))
+		case 'A':
+			b.WriteString(t.Format("Monday"))
+		case 'b', 'h':
+			b.WriteString(t.Format("Jan"))
+		case 'B':
+			b.WriteString(t.Format("January"))
+		case 'u':
+			w := int(t.Weekday())
+			if w == 0 {
+				w = 7
+			}
+			fmt.Fprintf(&b, "%d", w)
+		case 'w':
+			fmt.Fprintf(&b, "%d", int(t.Weekday()))
+		case 'z':
+			b.WriteString(t.Format("-0700"))
+		case 'Z':
+			b.WriteString(t.Format("MST"))
+		case 's':
+			fmt.Fprintf(&b, "%d", t.Unix())
+		case 'F':
+			b.WriteString(strftime("%Y-%m-%d", t))
+		case 'T':
+			b.WriteString(strftime("%H:%M:%S", t))
+		case 'D':
+			b.WriteString(strftime("%m/%d/%y", t))
+		case 'R':
+			b.WriteString(strftime("%H:%M", t))
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case '%':
+			b.WriteByte('%')
+		default:
+			b.WriteByte('%')
+			b.WriteByte(format[i])
+		}
+	}
+	return b.String()
+}
+
+// strptime parses the string in the format like C's strptime.
+// The time is interpreted in the local time zone unless the format has %z or %Z.
+func strptime(s string, format string) (time.Time, error) {
+	layouts := map[byte]string{
+		'Y': "2006",
+		'y': "06",
+		'm': "1",
+		'd': "2",
+		'e': "_2",
+		'H': "15",
+		'I': "3",
+		'M': "4",
+		'S': "5",
+		'p': "PM",
+		'j': "__2",
+		'a': "Mon",
+		'A': "Monday",
+		'b': "Jan",
+		'h': "Jan",
+		'B': "January",
+		'z': "-0700",
+		'Z': "MST",
+		'F': "2006-1-2",
+		'T': "15:4:5",
+		'D': "1/2/06",
+		'R': "15:4",
+		'%': "%",
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || len(format) <= i+1 {
+			b.WriteByte(format[i])
+			continue
+		}
+		i++
+		l, ok := layouts[format[i]]
+		if !ok {
+			return time.Time{}, fmt.Errorf("'%%%c' is not supported", format[i])
+		}
+		b.WriteString(l)
+	}
+	return time.ParseInLocation(b.String(), s, time.Local)
+}
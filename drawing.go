@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	_ "image/gif" // the image formats for excelize.AddPicture
+	_ "image/jpeg"
+	_ "image/png"
+	"strconv"
+	"strings"
+)
+
+// The chart is made from the range whose first row is the names of the series and the first column is the categories.
+// The options are specified in the syntax of the style like "title='Monthly sales';legend=right;width=640".
+
+var chartTypes = map[string]string{
+	"line":     "line",
+	"col":      "col",
+	"column":   "col",
+	"bar":      "bar",
+	"pie":      "pie",
+	"doughnut": "doughnut",
+	"area":     "area",
+	"scatter":  "scatter",
+	"radar":    "radar",
+}
+
+// chartFormat returns the format of the chart for excelize.AddChart.
+func chartFormat(r *Range, chartType string, options string) (string, error) {
+	t, ok := chartTypes[strings.ToLower(chartType)]
+	if !ok {
+		return "", fmt.Errorf("'%s' is invalid chart type", chartType)
+	}
+	format := map[string]interface{}{"type": t}
+
+	byRows := false
+	dimension := map[string]int{}
+	for _, item := range splitStyleSpec(options) {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		key, value := item, ""
+		if i := strings.IndexByte(item, '='); i >= 0 {
+			key = strings.TrimSpace(item[:i])
+			value = unquoteStyleValue(strings.TrimSpace(item[i+1:]))
+		}
+		switch strings.ToLower(key) {
+		case "title":
+			format["title"] = map[string]string{"name": value}
+		case "width", "height":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return "", fmt.Errorf("'%s' is invalid %s", value, key)
+			}
+			dimension[strings.ToLower(key)] = n
+		case "legend":
+			switch value {
+			case "none":
+				format["legend"] = map[string]bool{"none": true}
+			case "top", "bottom", "left", "right", "top_right":
+				format["legend"] = map[string]string{"position": value}
+			default:
+				return "", fmt.Errorf("'%s' is invalid legend position", value)
+			}
+		case "series":
+			switch value {
+			case "cols":
+				byRows = false
+			case "rows":
+				byRows = true
+			default:
+				return "", fmt.Errorf("'%s' is invalid series direction", value)
+			}
+		case "labels":
+			switch value {
+			case "value":
+				format["plotarea"] = map[string]bool{"show_val": true}
+			case "percent":
+				format["plotarea"] = map[string]bool{"show_percent": true}
+			case "category":
+				format["plotarea"] = map[string]bool{"show_cat_name": true}
+			case "none":
+			default:
+				return "", fmt.Errorf("'%s' is invalid labels", value)
+			}
+		default:
+			return "", fmt.Errorf("'%s' is unknown option of chart", key)
+		}
+	}
+	if 0 < len(dimension) {
+		format["dimension"] = dimension
+	}
+
+	series, err := chartSeries(r, byRows)
+	if err != nil {
+		return "", err
+	}
+	format["series"] = series
+	b, _ := json.Marshal(format)
+	return string(b), nil
+}
+
+// chartSeries returns the series of the columns(or the rows if byRows is true) of the range.
+// The first row(column) is the names, and the first column(row) is the categories.
+func chartSeries(r *Range, byRows bool) ([]map[string]string, error) {
+	ref := func(col1, row1, col2, row2 int) string {
+		f := formulaRef{
+			col:    [2]int{col1, col2},
+			row:    [2]int{row1, row2},
+			absCol: [2]bool{true, true},
+			absRow: [2]bool{true, true},
+			area:   col1 != col2 || row1 != row2,
+		}
+		return quoteSheetName(r.sheet) + "!" + f.String()
+	}
+
+	series := make([]map[string]string, 0)
+	if !byRows {
+		if r.height() < 2 {
+			return nil, fmt.Errorf("range '%s' has no values under the names", r.ref())
+		}
+		first := r.startCol
+		if 1 < r.width() {
+			first++
+		}
+		for col := first; col <= r.endCol; col++ {
+			s := map[string]string{
+				"name":   ref(col, r.startRow, col, r.startRow),
+				"values": ref(col, r.startRow+1, col, r.endRow),
+			}
+			if first != r.startCol {
+				s["categories"] = ref(r.startCol, r.startRow+1, r.startCol, r.endRow)
+			}
+			series = append(series, s)
+		}
+		return series, nil
+	}
+
+	if r.width() < 2 {
+		return nil, fmt.Errorf("range '%s' has no values right of the names", r.ref())
+	}
+	first := r.startRow
+	if 1 < r.height() {
+		first++
+	}
+	for row := first; row <= r.endRow; row++ {
+		s := map[string]string{
+			"name":   ref(r.startCol, row, r.startCol, row),
+			"values": ref(r.startCol+1, row, r.endCol, row),
+		}
+		if first != r.startRow {
+			s["categories"] = ref(r.startCol+1, r.startRow, r.endCol, r.startRow)
+		}
+		series = append(series, s)
+	}
+	return series, nil
+}
+
+// addSheetChart adds the chart of the range to the cell of the sheet.
+func (s *Spreadsheet) addSheetChart(sheet string, axis string, r *Range, chartType string, options string) error {
+	format, err := chartFormat(r, chartType, options)
+	if err != nil {
+		return err
+	}
+	return s.file.AddChart(sheet, axis, format)
+}
+
+// addSheetImage adds the image file like PNG, JPEG or GIF to the cell of the sheet with the scale.
+func (s *Spreadsheet) addSheetImage(sheet string, axis string, path string, scale float64) error {
+	if scale <= 0 {
+		return fmt.Errorf("'%v' is invalid scale", scale)
+	}
+	format := fmt.Sprintf(`{"x_scale":%v,"y_scale":%v,"print_obj":true,"lock_aspect_ratio":true}`, scale, scale)
+	return s.file.AddPicture(sheet, axis, path, format)
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/360EntSecGroup-Skylar/excelize/v2"
+)
+
+// A sheet or a range is dumped as JSON, NDJSON, a Markdown table or an HTML table.
+// The value of the merged cells is in the top left cell, and the HTML table spans the merged cells.
+// The rows of JSON are arrays without the empty trailing cells, or objects keyed by the header row.
+
+var dumpFormats = map[string]string{
+	"json":     "json",
+	"ndjson":   "ndjson",
+	"md":       "md",
+	"markdown": "md",
+	"html":     "html",
+}
+
+// dumpTable is the values of a range to dump.
+type dumpTable struct {
+	rows [][]string
+	// cols is the column names like "A"
+	cols []string
+	// span is the rows and the columns of the merged cells at the top left cell
+	span map[[2]int][2]int
+	// covered is the merged cells except the top left cell
+	covered map[[2]int]bool
+}
+
+// getDumpTable returns the values of the range. The empty trailing rows and columns are removed if trim is true.
+// The merged cells across the header row are split into the header and the others.
+func (s *Spreadsheet) getDumpTable(r *Range, trim bool, header bool) *dumpTable {
+	t := &dumpTable{span: map[[2]int][2]int{}, covered: map[[2]int]bool{}}
+	if r == nil {
+		return t
+	}
+	t.rows = s.getRangeValues(r)
+	width := r.width()
+	if trim {
+		for 0 < len(t.rows) && isEmptyRow(t.rows[len(t.rows)-1]) {
+			t.rows = t.rows[:len(t.rows)-1]
+		}
+		width = 0
+		for _, row := range t.rows {
+			for x := len(row); width < x; x-- {
+				if row[x-1] != "" {
+					width = x
+					break
+				}
+			}
+		}
+		for y := range t.rows {
+			t.rows[y] = t.rows[y][:width]
+		}
+	}
+	if len(t.rows) == 0 || width == 0 {
+		t.rows = nil
+		return t
+	}
+	for x := 0; x < width; x++ {
+		name, _ := excelize.ColumnNumberToName(r.startCol + x)
+		t.cols = append(t.cols, name)
+	}
+
+	for _, ref := range s.getSheetMergedRanges(r.sheet) {
+		sc, sr, ec, er, err := parseRangeRef(ref, 0, 0)
+		if err != nil {
+			continue
+		}
+		// the merged cells in the table
+		top, left := sr-r.startRow, sc-r.startCol
+		bottom, right := er-r.startRow, ec-r.startCol
+		if top < 0 {
+			top = 0
+		}
+		if left < 0 {
+			left = 0
+		}
+		if len(t.rows) <= bottom {
+			bottom = len(t.rows) - 1
+		}
+		if width <= right {
+			right = width - 1
+		}
+		if bottom < top || right < left {
+			continue
+		}
+		if header && top == 0 && 0 < bottom {
+			t.merge(0, left, 0, right)
+			t.merge(1, left, bottom, right)
+			t.rows[1][left] = ""
+			continue
+		}
+		t.merge(top, left, bottom, right)
+	}
+	return t
+}
+
+// merge makes the cells one merged cell whose value is in the top left cell.
+func (t *dumpTable) merge(top, left, bottom, right int) {
+	if top == bottom && left == right {
+		return
+	}
+	t.span[[2]int{top, left}] = [2]int{bottom - top + 1, right - left + 1}
+	for y := top; y <= bottom; y++ {
+		for x := left; x <= right; x++ {
+			if y == top && x == left {
+				continue
+			}
+			t.covered[[2]int{y, x}] = true
+			t.rows[y][x] = ""
+		}
+	}
+}
+
+func isEmptyRow(row []string) bool {
+	for _, v := range row {
+		if v != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// keys returns the keys of the objects from the header row.
+// The empty header is the column name, and the duplicated header is suffixed like "name_2".
+func (t *dumpTable) keys() []string {
+	keys := make([]string, len(t.cols))
+	used := map[string]bool{}
+	for x, k := range t.rows[0] {
+		if k == "" {
+			k = t.cols[x]
+		}
+		key := k
+		for i := 2; used[key]; i++ {
+			key = fmt.Sprintf("%s_%d", k, i)
+		}
+		used[key] = true
+		keys[x] = key
+	}
+	return keys
+}
+
+// dumpText returns the table in the format. The first row is the header if header is true.
+func (t *dumpTable) dumpText(format string, header bool) (string, error) {
+	f, ok := dumpFormats[strings.ToLower(format)]
+	if !ok {
+		return "", fmt.Errorf("'%s' is invalid format", format)
+	}
+	switch f {
+	case "json", "ndjson":
+		records := t.jsonRecords(header)
+		if f == "ndjson" {
+			if len(records) == 0 {
+				return "", nil
+			}
+			return strings.Join(records, "\n") + "\n", nil
+		}
+		if len(records) == 0 {
+			return "[]\n", nil
+		}
+		return "[\n  " + strings.Join(records, ",\n  ") + "\n]\n", nil
+	case "md":
+		return t.markdown(header), nil
+	}
+	return t.html(header), nil
+}
+
+// jsonRecords returns the rows as JSON arrays, or JSON objects if header is true.
+func (t *dumpTable) jsonRecords(header bool) []string {
+	if len(t.rows) == 0 {
+		return nil
+	}
+	records := make([]string, 0, len(t.rows))
+	if !header {
+		for _, row := range t.rows {
+			n := len(row)
+			for 0 < n && row[n-1] == "" {
+				n--
+			}
+			values := make([]string, n)
+			for x, v := range row[:n] {
+				values[x] = jsonValue(v)
+			}
+			records = append(records, "["+strings.Join(values, ",")+"]")
+		}
+		return records
+	}
+
+	keys := t.keys()
+	for _, row := range t.rows[1:] {
+		members := make([]string, len(row))
+		for x, v := range row {
+			members[x] = jsonString(keys[x]) + ":" + jsonValue(v)
+		}
+		records = append(records, "{"+strings.Join(members, ",")+"}")
+	}
+	return records
+}
+
+// jsonValue returns the value as a JSON number if it is a number like "12.5", otherwise a JSON string.
+func jsonValue(v string) string {
+	if _, ok := maybeNumber(v); ok && json.Valid([]byte(v)) {
+		return v
+	}
+	return jsonString(v)
+}
+
+func jsonString(s string) string {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// markdown returns the table as a Markdown table. The header is the column names unless header is true.
+func (t *dumpTable) markdown(header bool) string {
+	if len(t.rows) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	line := func(values []string) {
+		b.WriteString("|")
+		for _, v := range values {
+			v = strings.ReplaceAll(v, "|", `\|`)
+			v = strings.ReplaceAll(strings.ReplaceAll(v, "\r\n", "<br>"), "\n", "<br>")
+			b.WriteString(" " + v + " |")
+		}
+		b.WriteString("\n")
+	}
+	rows := t.rows
+	if header {
+		line(rows[0])
+		rows = rows[1:]
+	} else {
+		line(t.cols)
+	}
+	b.WriteString("|" + strings.Repeat(" --- |", len(t.cols)) + "\n")
+	for _, row := range rows {
+		line(row)
+	}
+	return b.String()
+}
+
+// html returns the table as an HTML table. The first row is in thead if header is true.
+func (t *dumpTable) html(header bool) string {
+	if len(t.rows) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	line := func(y int, tag string) {
+		b.WriteString("<tr>")
+		for x, v := range t.rows[y] {
+			if t.covered[[2]int{y, x}] {
+				continue
+			}
+			b.WriteString("<" + tag)
+			if span, ok := t.span[[2]int{y, x}]; ok {
+				if 1 < span[0] {
+					fmt.Fprintf(&b, ` rowspan="%d"`, span[0])
+				}
+				if 1 < span[1] {
+					fmt.Fprintf(&b, ` colspan="%d"`, span[1])
+				}
+			}
+			v = strings.ReplaceAll(html.EscapeString(strings.ReplaceAll(v, "\r\n", "\n")), "\n", "<br>")
+			b.WriteString(">" + v + "</" + tag + ">")
+		}
+		b.WriteString("</tr>\n")
+	}
+
+	b.WriteString("<table>\n")
+	first := 0
+	if header {
+		b.WriteString("<thead>\n")
+		line(0, "th")
+		b.WriteString("</thead>\n")
+		first = 1
+	}
+	b.WriteString("<tbody>\n")
+	for y := first; y < len(t.rows); y++ {
+		line(y, "td")
+	}
+	b.WriteString("</tbody>\n</table>\n")
+	return b.String()
+}
+
+// dumpSheet writes the used range of the active sheet in the format to the output.
+func dumpSheet(format string, header bool) {
+	book := execContext.spreadsheet
+	t := book.getDumpTable(book.getSheetUsedRange(book.activeSheet), true, header)
+	s, err := t.dumpText(format, header)
+	if err != nil {
+		fatalError("-dump: %v", err)
+	}
+	fmt.Fprint(execContext.out, s)
+}
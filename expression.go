@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"math"
 	"strconv"
+	"strings"
 )
 
 type ExprType int
@@ -40,6 +41,20 @@ const (
 	PreIncrementExpression
 	DecrementExpression
 	PreDecrementExpression
+	ArrayReferExpression
+	ArrayAssignExpression
+	AddArrayAssignExpression
+	SubArrayAssignExpression
+	MulArrayAssignExpression
+	DivArrayAssignExpression
+	ModArrayAssignExpression
+	PowArrayAssignExpression
+	ConcatArrayAssignExpression
+	IncrementArrayExpression
+	PreIncrementArrayExpression
+	DecrementArrayExpression
+	PreDecrementArrayExpression
+	ArrayInExpression
 	FuncCallExpression
 	NumberEQExpression
 	NumberNEExpression
@@ -219,6 +234,76 @@ func NewPreDecrementExpression(ident string) *Expression {
 	return e
 }
 
+func NewArrayReferExpression(ident string, subscript *ArgList) *Expression {
+	e := &Expression{exprType: ArrayReferExpression, ident: ident, args: subscript}
+	return e
+}
+
+func NewArrayAssignExpression(ident string, subscript *ArgList, expr *Expression) *Expression {
+	e := &Expression{exprType: ArrayAssignExpression, ident: ident, args: subscript, right: expr}
+	return e
+}
+
+func NewAddArrayAssignExpression(ident string, subscript *ArgList, expr *Expression) *Expression {
+	e := &Expression{exprType: AddArrayAssignExpression, ident: ident, args: subscript, right: expr}
+	return e
+}
+
+func NewSubArrayAssignExpression(ident string, subscript *ArgList, expr *Expression) *Expression {
+	e := &Expression{exprType: SubArrayAssignExpression, ident: ident, args: subscript, right: expr}
+	return e
+}
+
+func NewMulArrayAssignExpression(ident string, subscript *ArgList, expr *Expression) *Expression {
+	e := &Expression{exprType: MulArrayAssignExpression, ident: ident, args: subscript, right: expr}
+	return e
+}
+
+func NewDivArrayAssignExpression(ident string, subscript *ArgList, expr *Expression) *Expression {
+	e := &Expression{exprType: DivArrayAssignExpression, ident: ident, args: subscript, right: expr}
+	return e
+}
+
+func NewModArrayAssignExpression(ident string, subscript *ArgList, expr *Expression) *Expression {
+	e := &Expression{exprType: ModArrayAssignExpression, ident: ident, args: subscript, right: expr}
+	return e
+}
+
+func NewPowArrayAssignExpression(ident string, subscript *ArgList, expr *Expression) *Expression {
+	e := &Expression{exprType: PowArrayAssignExpression, ident: ident, args: subscript, right: expr}
+	return e
+}
+
+func NewConcatArrayAssignExpression(ident string, subscript *ArgList, expr *Expression) *Expression {
+	e := &Expression{exprType: ConcatArrayAssignExpression, ident: ident, args: subscript, right: expr}
+	return e
+}
+
+func NewIncrementArrayExpression(ident string, subscript *ArgList) *Expression {
+	e := &Expression{exprType: IncrementArrayExpression, ident: ident, args: subscript}
+	return e
+}
+
+func NewPreIncrementArrayExpression(ident string, subscript *ArgList) *Expression {
+	e := &Expression{exprType: PreIncrementArrayExpression, ident: ident, args: subscript}
+	return e
+}
+
+func NewDecrementArrayExpression(ident string, subscript *ArgList) *Expression {
+	e := &Expression{exprType: DecrementArrayExpression, ident: ident, args: subscript}
+	return e
+}
+
+func NewPreDecrementArrayExpression(ident string, subscript *ArgList) *Expression {
+	e := &Expression{exprType: PreDecrementArrayExpression, ident: ident, args: subscript}
+	return e
+}
+
+func NewArrayInExpression(subscript *ArgList, array *Expression) *Expression {
+	e := &Expression{exprType: ArrayInExpression, args: subscript, right: array}
+	return e
+}
+
 func NewFuncCallExpression(ident string, args *ArgList) *Expression {
 	e := &Expression{exprType: FuncCallExpression, ident: ident, args: args}
 	return e
@@ -354,6 +439,16 @@ func NewPlusExpression(left *Expression) *Expression {
 	return e
 }
 
+// parseCompoundCellAddress returns the address of the cell which is assigned by the operator like "+=".
+// The operator is not supported for the ranges.
+func parseCompoundCellAddress(s string, op string) *cellAddress {
+	addr := parseCellAddress(s)
+	if addr.isRange() {
+		fatalError("'%s' is not supported for range '%s'", op, s)
+	}
+	return addr
+}
+
 func (e *Expression) eval() Node {
 	switch e.exprType {
 	case NumberExpression:
@@ -361,7 +456,11 @@ func (e *Expression) eval() Node {
 	case StringExpression:
 		return e
 	case CellReferExpression:
-		v := execContext.spreadsheet.getCellValue(e.left.eval().asString())
+		addr := parseCellAddress(e.left.eval().asString())
+		if addr.isRange() {
+			return addr.getRange()
+		}
+		v := addr.getValue()
 
 		f, ok := maybeNumber(v)
 		if !ok {
@@ -370,117 +469,133 @@ func (e *Expression) eval() Node {
 		return NewNumberExpression(f)
 	case CellAssignExpression:
 		v := e.right.eval()
+		addr := parseCellAddress(e.left.eval().asString())
 
-		f, isnum := maybeNumber(v.asString())
-		if isnum {
-			execContext.spreadsheet.setCellValue(e.left.eval().asString(), f)
-		} else {
-			execContext.spreadsheet.setCellValue(e.left.eval().asString(), v.asString())
+		if r, ok := v.(*Range); ok {
+			addr.copyFrom(r)
+			return v
+		}
+		if addr.isRange() {
+			r := addr.getRange()
+			r.book.fillRange(r, cellValueOf(v))
+			return v
 		}
+		addr.setValue(cellValueOf(v))
 
 		return v
 	case AddCellAssignExpression:
-		l := execContext.spreadsheet.getCellValue(e.left.eval().asString())
+		addr := parseCompoundCellAddress(e.left.eval().asString(), "+=")
+		l := addr.getValue()
 		f, _ := maybeNumber(l)
 		r := e.right.eval().asNumber()
 		v := f + r
 
-		execContext.spreadsheet.setCellValue(e.left.eval().asString(), v)
+		addr.setValue(v)
 
 		return NewNumberExpression(v)
 	case SubCellAssignExpression:
-		l := execContext.spreadsheet.getCellValue(e.left.eval().asString())
+		addr := parseCompoundCellAddress(e.left.eval().asString(), "-=")
+		l := addr.getValue()
 		f, _ := maybeNumber(l)
 		r := e.right.eval().asNumber()
 		v := f - r
 
-		execContext.spreadsheet.setCellValue(e.left.eval().asString(), v)
+		addr.setValue(v)
 
 		return NewNumberExpression(v)
 	case MulCellAssignExpression:
-		l := execContext.spreadsheet.getCellValue(e.left.eval().asString())
+		addr := parseCompoundCellAddress(e.left.eval().asString(), "*=")
+		l := addr.getValue()
 		f, _ := maybeNumber(l)
 		r := e.right.eval().asNumber()
 		v := f * r
 
-		execContext.spreadsheet.setCellValue(e.left.eval().asString(), v)
+		addr.setValue(v)
 
 		return NewNumberExpression(v)
 	case DivCellAssignExpression:
-		l := execContext.spreadsheet.getCellValue(e.left.eval().asString())
+		addr := parseCompoundCellAddress(e.left.eval().asString(), "/=")
+		l := addr.getValue()
 		f, _ := maybeNumber(l)
 		r := e.right.eval().asNumber()
 		v := float64(int(f) / int(r))
 
-		execContext.spreadsheet.setCellValue(e.left.eval().asString(), v)
+		addr.setValue(v)
 
 		return NewNumberExpression(v)
 	case ModCellAssignExpression:
-		l := execContext.spreadsheet.getCellValue(e.left.eval().asString())
+		addr := parseCompoundCellAddress(e.left.eval().asString(), "%=")
+		l := addr.getValue()
 		f, _ := maybeNumber(l)
 		r := e.right.eval().asNumber()
 		v := float64(int(f) % int(r))
 
-		execContext.spreadsheet.setCellValue(e.left.eval().asString(), v)
+		addr.setValue(v)
 
 		return NewNumberExpression(v)
 	case PowCellAssignExpression:
-		l := execContext.spreadsheet.getCellValue(e.left.eval().asString())
+		addr := parseCompoundCellAddress(e.left.eval().asString(), "**=")
+		l := addr.getValue()
 		f, _ := maybeNumber(l)
 		r := e.right.eval().asNumber()
 		v := math.Pow(f, r)
 
-		execContext.spreadsheet.setCellValue(e.left.eval().asString(), v)
+		addr.setValue(v)
 
 		return NewNumberExpression(v)
 	case ConcatCellAssignExpression:
-		l := execContext.spreadsheet.getCellValue(e.left.eval().asString())
+		addr := parseCompoundCellAddress(e.left.eval().asString(), ".=")
+		l := addr.getValue()
 		r := e.right.eval().asString()
 		v := l + r
 
-		execContext.spreadsheet.setCellValue(e.left.eval().asString(), v)
+		addr.setValue(v)
 
 		return NewStringExpression(v)
 	case IncrementCellExpression:
-		l := execContext.spreadsheet.getCellValue(e.left.eval().asString())
+		addr := parseCompoundCellAddress(e.left.eval().asString(), "++")
+		l := addr.getValue()
 
 		if a, err := incrementColumnNumber(l); err == nil {
-			execContext.spreadsheet.setCellValue(e.left.eval().asString(), a)
+			addr.setValue(a)
 			return NewStringExpression(l)
 		}
 
 		f, _ := maybeNumber(l)
 		v := f + 1
-		execContext.spreadsheet.setCellValue(e.left.eval().asString(), v)
+		addr.setValue(v)
 		return NewNumberExpression(f)
 	case PreIncrementCellExpression:
-		l := execContext.spreadsheet.getCellValue(e.left.eval().asString())
+		addr := parseCompoundCellAddress(e.left.eval().asString(), "++")
+		l := addr.getValue()
 
 		if a, err := incrementColumnNumber(l); err == nil {
-			execContext.spreadsheet.setCellValue(e.left.eval().asString(), a)
+			addr.setValue(a)
 			return NewStringExpression(a)
 		}
 
 		f, _ := maybeNumber(l)
 		v := f + 1
 
-		execContext.spreadsheet.setCellValue(e.left.eval().asString(), v)
+		addr.setValue(v)
 
 		return NewNumberExpression(v)
 	case DecrementCellExpression:
-		l := execContext.spreadsheet.getCellValue(e.left.eval().asString())
+		addr := parseCompoundCellAddress(e.left.eval().asString(), "--")
+		l := addr.getValue()
 		f, _ := maybeNumber(l)
 		v := f - 1
 
-		execContext.spreadsheet.setCellValue(e.left.eval().asString(), v)
+		addr.setValue(v)
 
 		return NewNumberExpression(f)
 	case PreDecrementCellExpression:
-		l := execContext.spreadsheet.getCellValue(e.left.eval().asString())
+		addr := parseCompoundCellAddress(e.left.eval().asString(), "--")
+		l := addr.getValue()
 		f, _ := maybeNumber(l)
 		v := f - 1
 
-		execContext.spreadsheet.setCellValue(e.left.eval().asString(), v)
+		addr.setValue(v)
 
 		return NewNumberExpression(v)
 	case VarReferExpression:
@@ -607,6 +722,127 @@ func (e *Expression) eval() Node {
 			execContext.scope.set(e.ident, v)
 			return v
 		}
+	case ArrayReferExpression:
+		a := execContext.scope.getArray(e.ident)
+		return a.get(e.subscript())
+	case ArrayAssignExpression:
+		k := e.subscript()
+		v := e.right.eval()
+		execContext.scope.getArray(e.ident).set(k, v)
+		return v
+	case AddArrayAssignExpression:
+		k := e.subscript()
+		r := e.right.eval()
+		a := execContext.scope.getArray(e.ident)
+		v := NewNumberExpression(a.get(k).asNumber() + r.asNumber())
+		a.set(k, v)
+		return v
+	case SubArrayAssignExpression:
+		k := e.subscript()
+		r := e.right.eval()
+		a := execContext.scope.getArray(e.ident)
+		v := NewNumberExpression(a.get(k).asNumber() - r.asNumber())
+		a.set(k, v)
+		return v
+	case MulArrayAssignExpression:
+		k := e.subscript()
+		r := e.right.eval()
+		a := execContext.scope.getArray(e.ident)
+		v := NewNumberExpression(a.get(k).asNumber() * r.asNumber())
+		a.set(k, v)
+		return v
+	case DivArrayAssignExpression:
+		k := e.subscript()
+		r := e.right.eval()
+		a := execContext.scope.getArray(e.ident)
+		v := NewNumberExpression(a.get(k).asNumber() / r.asNumber())
+		a.set(k, v)
+		return v
+	case ModArrayAssignExpression:
+		k := e.subscript()
+		r := e.right.eval()
+		a := execContext.scope.getArray(e.ident)
+		v := NewNumberExpression(float64(int(a.get(k).asNumber()) % int(r.asNumber())))
+		a.set(k, v)
+		return v
+	case PowArrayAssignExpression:
+		k := e.subscript()
+		r := e.right.eval()
+		a := execContext.scope.getArray(e.ident)
+		v := NewNumberExpression(math.Pow(a.get(k).asNumber(), r.asNumber()))
+		a.set(k, v)
+		return v
+	case ConcatArrayAssignExpression:
+		k := e.subscript()
+		r := e.right.eval()
+		a := execContext.scope.getArray(e.ident)
+		v := NewStringExpression(a.get(k).asString() + r.asString())
+		a.set(k, v)
+		return v
+	case IncrementArrayExpression:
+		k := e.subscript()
+		a := execContext.scope.getArray(e.ident)
+		l := a.get(k)
+		if c, err := incrementColumnNumber(l.asString()); err == nil {
+			a.set(k, NewStringExpression(c))
+			return l
+		}
+		a.set(k, NewNumberExpression(l.asNumber()+1))
+		return l
+	case PreIncrementArrayExpression:
+		k := e.subscript()
+		a := execContext.scope.getArray(e.ident)
+		l := a.get(k)
+		if c, err := incrementColumnNumber(l.asString()); err == nil {
+			v := NewStringExpression(c)
+			a.set(k, v)
+			return v
+		}
+		v := NewNumberExpression(l.asNumber() + 1)
+		a.set(k, v)
+		return v
+	case DecrementArrayExpression:
+		k := e.subscript()
+		a := execContext.scope.getArray(e.ident)
+		l := a.get(k)
+		if c, err := decrementColumnNumber(l.asString()); err == nil {
+			a.set(k, NewStringExpression(c))
+			return l
+		}
+		a.set(k, NewNumberExpression(l.asNumber()-1))
+		return l
+	case PreDecrementArrayExpression:
+		k := e.subscript()
+		a := execContext.scope.getArray(e.ident)
+		l := a.get(k)
+		if c, err := decrementColumnNumber(l.asString()); err == nil {
+			v := NewStringExpression(c)
+			a.set(k, v)
+			return v
+		}
+		v := NewNumberExpression(l.asNumber() - 1)
+		a.set(k, v)
+		return v
+	case ArrayInExpression:
+		k := e.subscript()
+		v := e.right.eval()
+		if r, ok := v.(*Range); ok {
+			if r.contains(k) {
+				return NewNumberExpression(1)
+			}
+			return NewNumberExpression(0)
+		}
+		a, ok := v.(*Array)
+		if !ok {
+			if v.asString() != "" {
+				fatalError("right side of 'in' is not an array")
+			}
+			return NewNumberExpression(0)
+		}
+		if a.exist(k) {
+			return NewNumberExpression(1)
+		}
+		return NewNumberExpression(0)
 	case FuncCallExpression:
 		f, found := execContext.functions[e.ident]
 		if !found {
@@ -848,6 +1084,18 @@ func (e *Expression) eval() Node {
 	panic("evaluate unknown type.")
 }
 
+// subscript returns the array subscript.
+// Multiple subscripts are joined by SUBSEP.
+func (e *Expression) subscript() string {
+	sep := execContext.scope.get("SUBSEP").asString()
+	keys := make([]string, 0, len(e.args.args))
+	// ArgList holds the arguments in reverse order
+	for i := len(e.args.args) - 1; 0 <= i; i-- {
+		keys = append(keys, e.args.args[i].eval().asString())
+	}
+	return strings.Join(keys, sep)
+}
+
 func maybeNumber(val string) (float64, bool) {
 	f, err := strconv.ParseFloat(val, 64)
 	if err != nil {
@@ -875,7 +1123,7 @@ func (e *Expression) asString() string {
 		return e.str
 	}
 	if e.exprType == NumberExpression {
-		return fmt.Sprintf("%g", e.number)
+		return numberToString(e.number, convfmt())
 	}
 	return e.asString()
 }
@@ -912,3 +1160,32 @@ func (e *Expression) String() string {
 	}
 	return fmt.Sprintf("[Type: Expression] expr type: %s [%s]\n", e.exprType.String(), v)
 }
+
+// DefaultNumberFormat is the default value of CONVFMT and OFMT.
+// The empty format converts the numbers to the shortest strings which keep the values.
+const DefaultNumberFormat = ""
+
+// formattingNumber prevents the recursive conversion by a format like "%s".
+var formattingNumber bool
+
+// numberToString converts the number to string.
+// Integral numbers are converted as integers, and the others are formatted by the format if it is set.
+func numberToString(f float64, format string) string {
+	if f == math.Trunc(f) && math.Abs(f) < 1e16 {
+		return strconv.FormatInt(int64(f), 10)
+	}
+	if format == "" || formattingNumber {
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	formattingNumber = true
+	defer func() { formattingNumber = false }()
+	return formatValues(format, []Node{NewNumberExpression(f)})
+}
+
+// convfmt returns CONVFMT.
+func convfmt() string {
+	if execContext == nil {
+		return DefaultNumberFormat
+	}
+	return execContext.convfmt
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/360EntSecGroup-Skylar/excelize/v2"
+)
+
+var (
+	formulaSheetReg = regexp.MustCompile(`^[A-Za-z0-9_.]+!`)
+	formulaRefReg   = regexp.MustCompile(`^(\$?[A-Za-z]{1,3}\$?[0-9]+(:\$?[A-Za-z]{1,3}\$?[0-9]+)?|\$?[A-Za-z]{1,3}:\$?[A-Za-z]{1,3}|\$?[0-9]+:\$?[0-9]+)`)
+)
+
+var refPartReg = regexp.MustCompile(`^(\$?)([A-Za-z]{0,3})(\$?)([0-9]*)$`)
+
+// formulaRef is a reference like A1, $A$1:B2, A:B or 1:2 in a formula.
+// The columns are 0 for whole rows, and the rows are 0 for whole columns.
+type formulaRef struct {
+	col, row       [2]int
+	absCol, absRow [2]bool
+	area           bool
+}
+
+// parseFormulaRef parses the reference matched by formulaRefReg.
+func parseFormulaRef(ref string) formulaRef {
+	var r formulaRef
+	parts := strings.Split(ref, ":")
+	r.area = len(parts) == 2
+	for i := 0; i < 2; i++ {
+		m := refPartReg.FindStringSubmatch(parts[i%len(parts)])
+		r.absCol[i], r.absRow[i] = m[1] == "$", m[3] == "$"
+		if m[2] != "" {
+			r.col[i], _ = excelize.ColumnNameToNumber(m[2])
+		} else {
+			// "$1" of "$1:$3" is parsed as the absolute column
+			r.absCol[i], r.absRow[i] = false, r.absCol[i]
+		}
+		r.row[i], _ = strconv.Atoi(m[4])
+	}
+	return r
+}
+
+func (r formulaRef) String() string {
+	var b strings.Builder
+	for i := 0; i < 2; i++ {
+		if i == 1 {
+			if !r.area {
+				break
+			}
+			b.WriteByte(':')
+		}
+		if r.col[i] != 0 {
+			if r.absCol[i] {
+				b.WriteByte('$')
+			}
+			name, _ := excelize.ColumnNumberToName(r.col[i])
+			b.WriteString(name)
+		}
+		if r.row[i] != 0 {
+			if r.absRow[i] {
+				b.WriteByte('$')
+			}
+			b.WriteString(strconv.Itoa(r.row[i]))
+		}
+	}
+	return b.String()
+}
+
+// valid reports whether the reference is in the sheet.
+func (r formulaRef) valid() bool {
+	for i := 0; i < 2; i++ {
+		if r.col[i] < 0 || excelize.TotalColumns < r.col[i] || r.row[i] < 0 || excelize.TotalRows < r.row[i] {
+			return false
+		}
+		if (r.col[i] == 0 && r.row[i] == 0) || (r.area && (r.col[0] == 0) != (r.col[1] == 0)) {
+			return false
+		}
+	}
+	return true
+}
+
+// mapFormulaRefs calls fn with the sheet name(empty if not qualified) for every reference in the formula,
+// and replaces the reference with the changed one. The reference is replaced with #REF! if fn returns false
+// or the changed reference is out of the sheet.
+func mapFormulaRefs(formula string, fn func(sheet string, ref *formulaRef) bool) string {
+	var b strings.Builder
+	for i := 0; i < len(formula); {
+		c := formula[i]
+		rest := formula[i:]
+		prefix, sheet := "", ""
+
+		// string literals and quoted sheet names
+		if c == '"' || c == '\'' {
+			j := i + 1
+			for j < len(formula) {
+				if formula[j] == c {
+					if j+1 < len(formula) && formula[j+1] == c {
+						j += 2
+						continue
+					}
+					break
+				}
+				j++
+			}
+			if j < len(formula) {
+				j++
+			}
+			if c == '"' || j == len(formula) || formula[j] != '!' {
+				b.WriteString(formula[i:j])
+				i = j
+				continue
+			}
+			prefix, sheet = formula[i:j+1], unquoteSheetName(formula[i:j])
+		} else if 0 < i && isRefNameChar(formula[i-1]) {
+			b.WriteByte(c)
+			i++
+			continue
+		} else if m := formulaSheetReg.FindString(rest); m != "" {
+			prefix, sheet = m, m[:len(m)-1]
+		}
+		if m := formulaRefReg.FindString(rest[len(prefix):]); m != "" && isRefEnd(rest[len(prefix):], len(m)) {
+			ref := parseFormulaRef(m)
+			org := ref
+			b.WriteString(prefix)
+			if !fn(sheet, &ref) || !ref.valid() {
+				b.WriteString("#REF!")
+			} else if ref == org {
+				b.WriteString(m)
+			} else {
+				b.WriteString(ref.String())
+			}
+			i += len(prefix) + len(m)
+			continue
+		}
+
+		// skip the rest of the name like function names
+		j := i + len(prefix)
+		for j < len(formula) && isRefNameChar(formula[j]) {
+			j++
+		}
+		if j == i {
+			j++
+		}
+		b.WriteString(formula[i:j])
+		i = j
+	}
+	return b.String()
+}
+
+// shiftFormula moves the relative references in the formula by cols and rows like Excel's fill.
+// Absolute references like $A$1 are not moved.
+func shiftFormula(formula string, cols int, rows int) string {
+	return mapFormulaRefs(formula, func(sheet string, ref *formulaRef) bool {
+		for i := 0; i < 2; i++ {
+			if ref.col[i] != 0 && !ref.absCol[i] {
+				ref.col[i] += cols
+				if ref.col[i] < 1 {
+					return false
+				}
+			}
+			if ref.row[i] != 0 && !ref.absRow[i] {
+				ref.row[i] += rows
+				if ref.row[i] < 1 {
+					return false
+				}
+			}
+		}
+		return true
+	})
+}
+
+// adjustFormula adjusts the references to the sheet in the formula for inserting(offset > 0) or
+// deleting(offset < 0) the rows or the columns at num like Excel.
+// formulaSheet is the sheet of the formula, used for the references which are not qualified.
+func adjustFormula(formula string, formulaSheet string, sheet string, rows bool, num int, offset int) string {
+	return mapFormulaRefs(formula, func(s string, ref *formulaRef) bool {
+		if s == "" {
+			s = formulaSheet
+		}
+		if !strings.EqualFold(s, sheet) {
+			return true
+		}
+		span, limit := &ref.col, excelize.TotalColumns
+		if rows {
+			span, limit = &ref.row, excelize.TotalRows
+		}
+		if span[0] == 0 {
+			// whole rows or whole columns
+			return true
+		}
+		var ok bool
+		span[0], span[1], ok = adjustSpan(span[0], span[1], num, offset)
+		if limit < span[1] {
+			span[1] = limit
+		}
+		return ok && span[0] <= limit
+	})
+}
+
+// adjustSpan adjusts the span from a to b for inserting(offset > 0) or deleting(offset < 0) at num.
+// It returns false if the whole span is deleted.
+func adjustSpan(a int, b int, num int, offset int) (int, int, bool) {
+	if 0 < offset {
+		if num <= a {
+			a += offset
+		}
+		if num <= b {
+			b += offset
+		}
+		return a, b, true
+	}
+
+	last := num - offset - 1
+	if num <= a && b <= last {
+		return a, b, false
+	}
+	if last < a {
+		a += offset
+	} else if num <= a {
+		a = num
+	}
+	if last < b {
+		b += offset
+	} else if num <= b {
+		b = num - 1
+	}
+	return a, b, true
+}
+
+// moveFormula moves the references to the cells in the range of the sheet by cols and rows
+// like cut and paste. The references to the cells in dst are replaced with #REF!.
+func moveFormula(formula string, formulaSheet string, src *Range, dst *Range) string {
+	cols, rows := dst.startCol-src.startCol, dst.startRow-src.startRow
+	return mapFormulaRefs(formula, func(s string, ref *formulaRef) bool {
+		if s == "" {
+			s = formulaSheet
+		}
+		if !strings.EqualFold(s, src.sheet) || ref.col[0] == 0 || ref.row[0] == 0 {
+			return true
+		}
+		if src.containsRef(ref) {
+			for i := 0; i < 2; i++ {
+				ref.col[i] += cols
+				ref.row[i] += rows
+			}
+			return true
+		}
+		return !dst.containsRef(ref)
+	})
+}
+
+// containsRef reports whether the whole cells of the reference are in the range.
+func (r *Range) containsRef(ref *formulaRef) bool {
+	for i := 0; i < 2; i++ {
+		if ref.col[i] < r.startCol || r.endCol < ref.col[i] || ref.row[i] < r.startRow || r.endRow < ref.row[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func isRefNameChar(c byte) bool {
+	return ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') || c == '_' || c == '.'
+}
+
+// isRefEnd reports whether the reference ends at n, not a part of a name or a function call.
+func isRefEnd(s string, n int) bool {
+	return len(s) <= n || (!isRefNameChar(s[n]) && s[n] != '(')
+}
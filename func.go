@@ -6,8 +6,11 @@ import (
 	"math"
 	"math/rand"
 	"os"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 type ArgList struct {
@@ -68,12 +71,26 @@ type Function struct {
 	defineParams   *ParamList
 	defineStmt     *Statement
 	defineFuncName string
+	// arrayParam is the position of the builtin parameter which receives an array by reference.
+	// -1 if there is no such parameter.
+	arrayParam int
 }
 
 func NewBuiltinFunction(f func(args ...Node) Node) *Function {
 	return &Function{
-		funcType: FunctionTypeBuiltin,
-		builtin:  f,
+		funcType:   FunctionTypeBuiltin,
+		builtin:    f,
+		arrayParam: -1,
+	}
+}
+
+// NewBuiltinArrayFunction returns the builtin function which receives an array by reference at the position(start by 0).
+// An unset variable passed at the position becomes an array.
+func NewBuiltinArrayFunction(f func(args ...Node) Node, position int) *Function {
+	return &Function{
+		funcType:   FunctionTypeBuiltin,
+		builtin:    f,
+		arrayParam: position,
 	}
 }
 
@@ -84,7 +101,11 @@ func defineFunction(name string, params *ParamList, stmt *Statement) {
 		defineStmt:     stmt,
 		defineFuncName: name,
 	}
-	_, exist := execContext.functions[name]
+	g, exist := execContext.functions[name]
+	if exist && g.defineStmt == stmt {
+		// already defined by the same statement
+		return
+	}
 	if exist {
 		fatalError("function '%s' is already defined", name)
 	}
@@ -94,7 +115,19 @@ func defineFunction(name string, params *ParamList, stmt *Statement) {
 func (f *Function) call(args *ArgList) Node {
 	if f.funcType == FunctionTypeBuiltin {
 		ev := make([]Node, 0)
-		for _, v := range args.args {
+		for i, v := range args.args {
+			// arguments are stored in reverse order
+			if f.arrayParam == len(args.args)-1-i && v.exprType == VarReferExpression && !execContext.scope.isSpecialVar(v.ident) {
+				if _, ok := v.eval().(*Array); ok || execContext.scope.isUnset(v.ident) {
+					ev = append(ev, execContext.scope.getArray(v.ident))
+					continue
+				}
+			}
+			if f.arrayParam == len(args.args)-1-i && v.exprType == CellReferExpression {
+				// the cell is passed as the range of the cell instead of its value
+				ev = append(ev, parseCellAddress(v.left.eval().asString()).getCellRange())
+				continue
+			}
 			ev = append(ev, v.eval())
 		}
 		return f.builtin(ev...)
@@ -103,11 +136,28 @@ func (f *Function) call(args *ArgList) Node {
 			fatalError("invalid as number of arguments for %s", f.defineFuncName)
 		}
 
-		execContext.scope = AppendScope(execContext.scope)
+		// arguments are evaluated in the caller's scope
+		caller := execContext.scope
+		values := make([]Node, len(args.args))
+		for i, v := range args.args {
+			values[i] = v.eval()
+		}
+
+		execContext.scope = AppendScope(caller)
 
 		for i, p := range f.defineParams.params {
 			if i < len(args.args) {
-				execContext.scope.set(p, args.args[i].eval())
+				a := args.args[i]
+				if a.exprType == VarReferExpression && !caller.isSpecialVar(a.ident) && caller.isUnset(a.ident) {
+					// unset variable may be used as an array in the function.
+					// arrays are passed by reference.
+					execContext.scope.bindRef(p, caller, a.ident)
+				} else if values[i].nodeType() == NodeTypeArray {
+					// arrays are passed by reference.
+					execContext.scope.setVar(p, values[i])
+				} else {
+					execContext.scope.set(p, values[i])
+				}
 			} else {
 				execContext.scope.set(p, NewStringExpression(""))
 			}
@@ -133,22 +183,87 @@ func (f *Function) call(args *ArgList) Node {
 
 func builtinFunctions() map[string]*Function {
 	f := map[string]*Function{
-		"exit":   NewBuiltinFunction(builtinExit),
-		"abort":  NewBuiltinFunction(builtinAbort),
-		"gets":   NewBuiltinFunction(builtinGets),
-		"puts":   NewBuiltinFunction(builtinPuts),
-		"head":   NewBuiltinFunction(builtinHead),
-		"tail":   NewBuiltinFunction(builtinTail),
-		"rename": NewBuiltinFunction(builtinRename),
-		"exist":  NewBuiltinFunction(builtinExist),
-		"count":  NewBuiltinFunction(builtinCount),
-		"delete": NewBuiltinFunction(builtinDelete),
-		"copy":   NewBuiltinFunction(builtinCopy),
-		"srand":  NewBuiltinFunction(builtinSrand),
-		"rand":   NewBuiltinFunction(builtinRand),
-		"floor":  NewBuiltinFunction(builtinFloor),
-		"ceil":   NewBuiltinFunction(builtinCeil),
-		"round":  NewBuiltinFunction(builtinRound),
+		"exit":       NewBuiltinFunction(builtinExit),
+		"abort":      NewBuiltinFunction(builtinAbort),
+		"gets":       NewBuiltinFunction(builtinGets),
+		"puts":       NewBuiltinFunction(builtinPuts),
+		"head":       NewBuiltinFunction(builtinHead),
+		"tail":       NewBuiltinFunction(builtinTail),
+		"rename":     NewBuiltinFunction(builtinRename),
+		"exist":      NewBuiltinFunction(builtinExist),
+		"count":      NewBuiltinFunction(builtinCount),
+		"delete":     NewBuiltinFunction(builtinDelete),
+		"copy":       NewBuiltinFunction(builtinCopy),
+		"srand":      NewBuiltinFunction(builtinSrand),
+		"rand":       NewBuiltinFunction(builtinRand),
+		"floor":      NewBuiltinFunction(builtinFloor),
+		"ceil":       NewBuiltinFunction(builtinCeil),
+		"round":      NewBuiltinFunction(builtinRound),
+		"length":     NewBuiltinFunction(builtinLength),
+		"sum":        NewBuiltinFunction(builtinSum),
+		"avg":        NewBuiltinFunction(builtinAvg),
+		"min":        NewBuiltinFunction(builtinMin),
+		"max":        NewBuiltinFunction(builtinMax),
+		"countif":    NewBuiltinFunction(builtinCountif),
+		"open":       NewBuiltinFunction(builtinOpen),
+		"close":      NewBuiltinFunction(builtinClose),
+		"save":       NewBuiltinFunction(builtinSave),
+		"substr":     NewBuiltinFunction(builtinSubstr),
+		"index":      NewBuiltinFunction(builtinIndex),
+		"split":      NewBuiltinArrayFunction(builtinSplit, 1),
+		"sub":        NewBuiltinFunction(builtinSub),
+		"gsub":       NewBuiltinFunction(builtinGsub),
+		"toupper":    NewBuiltinFunction(builtinToupper),
+		"tolower":    NewBuiltinFunction(builtinTolower),
+		"trim":       NewBuiltinFunction(builtinTrim),
+		"sprintf":    NewBuiltinFunction(builtinSprintf),
+		"printf":     NewBuiltinFunction(builtinPrintf),
+		"now":        NewBuiltinFunction(builtinNow),
+		"date":       NewBuiltinFunction(builtinDate),
+		"strftime":   NewBuiltinFunction(builtinStrftime),
+		"strptime":   NewBuiltinFunction(builtinStrptime),
+		"getdate":    NewBuiltinFunction(builtinGetdate),
+		"setdate":    NewBuiltinFunction(builtinSetdate),
+		"formula":    NewBuiltinFunction(builtinFormula),
+		"setformula": NewBuiltinFunction(builtinSetformula),
+		"calc":       NewBuiltinFunction(builtinCalc),
+		"style":      NewBuiltinFunction(builtinStyle),
+		"getstyle":   NewBuiltinFunction(builtinGetstyle),
+		"merge":      NewBuiltinFunction(builtinMerge),
+		"unmerge":    NewBuiltinFunction(builtinUnmerge),
+		"merged":     NewBuiltinArrayFunction(builtinMerged, 0),
+		"colwidth":   NewBuiltinFunction(builtinColwidth),
+		"rowheight":  NewBuiltinFunction(builtinRowheight),
+		"hidecol":    NewBuiltinFunction(builtinHidecol),
+		"hiderow":    NewBuiltinFunction(builtinHiderow),
+		"freeze":     NewBuiltinFunction(builtinFreeze),
+		"insertrow":  NewBuiltinFunction(builtinInsertrow),
+		"deleterow":  NewBuiltinFunction(builtinDeleterow),
+		"insertcol":  NewBuiltinFunction(builtinInsertcol),
+		"deletecol":  NewBuiltinFunction(builtinDeletecol),
+		"moverange":  NewBuiltinFunction(builtinMoverange),
+		"sort":       NewBuiltinFunction(builtinSort),
+		"autofilter": NewBuiltinFunction(builtinAutofilter),
+		"table":      NewBuiltinFunction(builtinTable),
+		"tables":     NewBuiltinArrayFunction(builtinTables, 0),
+		"tablerange": NewBuiltinFunction(builtinTablerange),
+		"condformat": NewBuiltinFunction(builtinCondformat),
+		"colorscale": NewBuiltinFunction(builtinColorscale),
+		"databar":    NewBuiltinFunction(builtinDatabar),
+		"validate":   NewBuiltinFunction(builtinValidate),
+		"comment":    NewBuiltinFunction(builtinComment),
+		"comments":   NewBuiltinArrayFunction(builtinComments, 0),
+		"link":       NewBuiltinFunction(builtinLink),
+		"chart":      NewBuiltinFunction(builtinChart),
+		"image":      NewBuiltinFunction(builtinImage),
+		"names":      NewBuiltinArrayFunction(builtinNames, 0),
+		"defname":    NewBuiltinFunction(builtinDefname),
+		"undefname":  NewBuiltinFunction(builtinUndefname),
+		"exportcsv":  NewBuiltinFunction(builtinExportcsv),
+		"importcsv":  NewBuiltinFunction(builtinImportcsv),
+		"dump":       NewBuiltinFunction(builtinDump),
+		"json":       NewBuiltinArrayFunction(builtinJSON, 1),
+		"importjson": NewBuiltinFunction(builtinImportjson),
 	}
 
 	return f
@@ -183,12 +298,25 @@ func builtinGets(args ...Node) Node {
 	if len(args) != 0 {
 		fatalError("invalid as number of arguments for gets()")
 	}
+	s, _ := readRecord()
+	return NewStringExpression(s)
+}
+
+// readRecord reads a record separated by RS from the input and sets $0, $1...
+// ok is false if the input reached EOF.
+func readRecord() (string, bool) {
+	if execContext.csvInput {
+		return readCSVInput()
+	}
 	rs := execContext.scope.get("RS").asString()
 
 	s, err := execContext.in.ReadString(rs[0])
 	if err != io.EOF && err != nil {
 		fatalError("builtin function 'gets' raised error '%v'", err)
 	}
+	if err == io.EOF && s == "" {
+		return "", false
+	}
 	execContext.scope.incNR()
 
 	if rs == "\n" {
@@ -197,7 +325,36 @@ func builtinGets(args ...Node) Node {
 		s = strings.TrimRight(s, rs)
 	}
 	execContext.scope.setDollarSpecialVars(s)
-	return NewStringExpression(s)
+	return s, true
+}
+
+// readCSVInput reads a CSV record from the input and sets $0, $1...
+// The delimiter is FS, and the quote is specified by -Q.
+func readCSVInput() (string, bool) {
+	delim, ok := csvChar(execContext.scope.get("FS").asString())
+	if !ok || delim == 0 {
+		fatalError("FS '%s' is invalid delimiter of CSV", execContext.scope.get("FS").asString())
+	}
+	quote := '"'
+	if execContext.csvQuote != "" {
+		if quote, ok = csvChar(execContext.csvQuote); !ok {
+			fatalError("'%s' is invalid quote of CSV", execContext.csvQuote)
+		}
+	}
+	if execContext.scope.get("NR").asNumber() == 0 {
+		skipBOM(execContext.in)
+	}
+
+	fields, text, ok, err := readCSVRecord(execContext.in, delim, quote)
+	if err != nil {
+		fatalError("CSV record %d is invalid (%v)", int(execContext.scope.get("NR").asNumber())+1, err)
+	}
+	if !ok {
+		return "", false
+	}
+	execContext.scope.incNR()
+	execContext.scope.setDollarFields(text, fields)
+	return text, true
 }
 
 // puts(string) string
@@ -213,15 +370,37 @@ func builtinPuts(args ...Node) Node {
 		return NewStringExpression(s)
 	}
 	ofs := execContext.scope.get("OFS").asString()
-	s := args[0].asString()
+	s := outputString(args[0])
 	for i := 1; i < len(args); i++ {
 		s = ofs + s
-		s = args[i].asString() + s
+		s = outputString(args[i]) + s
 	}
 	fmt.Fprintf(execContext.out, "%s%s", s, ors)
 	return NewStringExpression(s)
 }
 
+// outputString converts the value to string for output.
+// Numbers are formatted by OFMT.
+func outputString(v Node) string {
+	if e, ok := v.(*Expression); ok && e.exprType == NumberExpression {
+		return numberToString(e.number, execContext.ofmt)
+	}
+	return v.asString()
+}
+
+// printf(format, values...) string
+// Print the string formatted like C's printf to stdout(No include ORS).
+// And return the printed string.
+func builtinPrintf(args ...Node) Node {
+	if len(args) < 1 {
+		fatalError("invalid as number of arguments for printf()")
+	}
+	a := inOrder(args)
+	s := formatValues(a[0].asString(), a[1:])
+	fmt.Fprint(execContext.out, s)
+	return NewStringExpression(s)
+}
+
 // head() string
 // Set the active sheet to the first sheet
 // And return active sheet name
@@ -390,3 +569,1658 @@ func builtinRound(args ...Node) Node {
 	v := math.Round(f)
 	return NewNumberExpression(v)
 }
+
+// length([array or string]) number
+// Return the number of elements of the array, or the number of characters of the string.
+// If no argument is provided, return the number of characters of $0.
+func builtinLength(args ...Node) Node {
+	if 1 < len(args) {
+		fatalError("invalid as number of arguments for length()")
+	}
+	if len(args) == 0 {
+		n := utf8.RuneCountInString(execContext.scope.get("$0").asString())
+		return NewNumberExpression(float64(n))
+	}
+	if a, ok := args[0].(*Array); ok {
+		return NewNumberExpression(float64(a.length()))
+	}
+	n := utf8.RuneCountInString(args[0].asString())
+	return NewNumberExpression(float64(n))
+}
+
+// numbersOf returns the numbers in the arguments.
+// Ranges and arrays are expanded, and the values which are not numbers in them are ignored.
+func numbersOf(args []Node) []float64 {
+	ret := make([]float64, 0)
+	for _, arg := range args {
+		switch v := arg.(type) {
+		case *Range:
+			for _, row := range v.values() {
+				for _, c := range row {
+					if f, ok := maybeNumber(c); ok {
+						ret = append(ret, f)
+					}
+				}
+			}
+		case *Array:
+			for _, k := range v.keyList() {
+				e := v.get(k)
+				if e.nodeType() == NodeTypeArray {
+					continue
+				}
+				if f, ok := maybeNumber(e.asString()); ok {
+					ret = append(ret, f)
+				}
+			}
+		default:
+			ret = append(ret, arg.asNumber())
+		}
+	}
+	return ret
+}
+
+// sum(range or array or number...) number
+// Return the sum of the numbers.
+func builtinSum(args ...Node) Node {
+	if len(args) < 1 {
+		fatalError("invalid as number of arguments for sum()")
+	}
+	v := 0.0
+	for _, f := range numbersOf(args) {
+		v += f
+	}
+	return NewNumberExpression(v)
+}
+
+// avg(range or array or number...) number
+// Return the average of the numbers.
+func builtinAvg(args ...Node) Node {
+	if len(args) < 1 {
+		fatalError("invalid as number of arguments for avg()")
+	}
+	a := numbersOf(args)
+	if len(a) == 0 {
+		return NewNumberExpression(0)
+	}
+	v := 0.0
+	for _, f := range a {
+		v += f
+	}
+	return NewNumberExpression(v / float64(len(a)))
+}
+
+// min(range or array or number...) number
+// Return the smallest number. If there are no numbers, return 0.
+func builtinMin(args ...Node) Node {
+	if len(args) < 1 {
+		fatalError("invalid as number of arguments for min()")
+	}
+	a := numbersOf(args)
+	if len(a) == 0 {
+		return NewNumberExpression(0)
+	}
+	v := a[0]
+	for _, f := range a {
+		v = math.Min(v, f)
+	}
+	return NewNumberExpression(v)
+}
+
+// max(range or array or number...) number
+// Return the largest number. If there are no numbers, return 0.
+func builtinMax(args ...Node) Node {
+	if len(args) < 1 {
+		fatalError("invalid as number of arguments for max()")
+	}
+	a := numbersOf(args)
+	if len(a) == 0 {
+		return NewNumberExpression(0)
+	}
+	v := a[0]
+	for _, f := range a {
+		v = math.Max(v, f)
+	}
+	return NewNumberExpression(v)
+}
+
+// countif(range or array, criteria) number
+// Count the values which meet the criteria like Excel's COUNTIF.
+// e.g. ">10", "<>done", "apple", "a*"
+func builtinCountif(args ...Node) Node {
+	if len(args) != 2 {
+		fatalError("invalid as number of arguments for countif()")
+	}
+	criteria := args[0].asString()
+	n := 0
+
+	switch v := args[1].(type) {
+	case *Range:
+		for _, row := range v.values() {
+			for _, c := range row {
+				if matchCriteria(c, criteria) {
+					n++
+				}
+			}
+		}
+	case *Array:
+		for _, k := range v.keyList() {
+			e := v.get(k)
+			if e.nodeType() != NodeTypeArray && matchCriteria(e.asString(), criteria) {
+				n++
+			}
+		}
+	default:
+		fatalError("countif(): first argument must be a range or an array")
+	}
+	return NewNumberExpression(float64(n))
+}
+
+// matchCriteria reports whether the value meets the criteria like Excel's COUNTIF.
+func matchCriteria(value string, criteria string) bool {
+	op := "="
+	for _, o := range []string{"<=", ">=", "<>", "<", ">", "="} {
+		if strings.HasPrefix(criteria, o) {
+			op = o
+			criteria = criteria[len(o):]
+			break
+		}
+	}
+
+	vf, visnum := maybeNumber(value)
+	cf, cisnum := maybeNumber(criteria)
+	if visnum && cisnum {
+		switch op {
+		case "=":
+			return vf == cf
+		case "<>":
+			return vf != cf
+		case "<":
+			return vf < cf
+		case "<=":
+			return vf <= cf
+		case ">":
+			return vf > cf
+		case ">=":
+			return vf >= cf
+		}
+	}
+
+	switch op {
+	case "=":
+		return matchWildcard(value, criteria)
+	case "<>":
+		return !matchWildcard(value, criteria)
+	}
+
+	// numbers and strings are not compared in order
+	if visnum || cisnum {
+		return false
+	}
+	c := strings.Compare(strings.ToLower(value), strings.ToLower(criteria))
+	switch op {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+	return false
+}
+
+// matchWildcard matches the value with the pattern case-insensitively.
+// '*' matches any characters, '?' matches any single character and '~' escapes them.
+func matchWildcard(value string, pattern string) bool {
+	r := "(?is)^"
+	escaped := false
+	for _, c := range pattern {
+		if escaped {
+			r += regexp.QuoteMeta(string(c))
+			escaped = false
+			continue
+		}
+		switch c {
+		case '~':
+			escaped = true
+		case '*':
+			r += ".*"
+		case '?':
+			r += "."
+		default:
+			r += regexp.QuoteMeta(string(c))
+		}
+	}
+	r += "$"
+	return regexp.MustCompile(r).MatchString(value)
+}
+
+// open(string[name], string[path]) string[name]
+// open the xlsx file as the named workbook.
+// an empty workbook is created if path is omitted.
+func builtinOpen(args ...Node) Node {
+	var name, path string
+	switch len(args) {
+	case 1:
+		name = args[0].asString()
+	case 2:
+		name = args[1].asString()
+		path = args[0].asString()
+	default:
+		fatalError("invalid as number of arguments for open()")
+	}
+
+	if err := openBook(name, path); err != nil {
+		fatalError("open(): %v", err)
+	}
+	return NewStringExpression(name)
+}
+
+// close(string[name])
+// close the named workbook without saving
+func builtinClose(args ...Node) Node {
+	if len(args) != 1 {
+		fatalError("invalid as number of arguments for close()")
+	}
+	if err := closeBook(args[0].asString()); err != nil {
+		fatalError("close(): %v", err)
+	}
+	return NewStringExpression("")
+}
+
+// save(string[name], string[path]) string[path]
+// save the named workbook to the path.
+// the main workbook is saved to the -to path if path is omitted.
+func builtinSave(args ...Node) Node {
+	var name, path string
+	switch len(args) {
+	case 1:
+		name = args[0].asString()
+	case 2:
+		name = args[1].asString()
+		path = args[0].asString()
+	default:
+		fatalError("invalid as number of arguments for save()")
+	}
+
+	s, ok := execContext.books[name]
+	if !ok {
+		fatalError("save(): workbook '%s' is not open", name)
+	}
+	if path == "" {
+		path = s.topath
+	}
+	if path == "" {
+		fatalError("save(): no specify write path for workbook '%s'", name)
+	}
+	if err := s.saveSpreadsheet(path); err != nil {
+		fatalError("save(): %v", err)
+	}
+	return NewStringExpression(path)
+}
+
+// inOrder returns the arguments in the order written in the source.
+func inOrder(args []Node) []Node {
+	ret := make([]Node, len(args))
+	for i, a := range args {
+		ret[len(args)-1-i] = a
+	}
+	return ret
+}
+
+// substr(string, m[, n]) string
+// Return the n characters substring of string starting at the m th character(start by 1).
+// If n is omitted, return the rest of string.
+func builtinSubstr(args ...Node) Node {
+	if len(args) < 2 || 3 < len(args) {
+		fatalError("invalid as number of arguments for substr()")
+	}
+	a := inOrder(args)
+	r := []rune(a[0].asString())
+
+	start := math.Round(a[1].asNumber())
+	end := float64(len(r) + 1)
+	if len(a) == 3 {
+		end = start + math.Round(a[2].asNumber())
+	}
+	if start < 1 {
+		start = 1
+	}
+	if float64(len(r)+1) < end {
+		end = float64(len(r) + 1)
+	}
+	if end <= start {
+		return NewStringExpression("")
+	}
+	return NewStringExpression(string(r[int(start)-1 : int(end)-1]))
+}
+
+// index(string, target) number
+// Return the position of target in string(start by 1), or 0 if not found.
+func builtinIndex(args ...Node) Node {
+	if len(args) != 2 {
+		fatalError("invalid as number of arguments for index()")
+	}
+	s := args[1].asString()
+	i := strings.Index(s, args[0].asString())
+	if i < 0 {
+		return NewNumberExpression(0)
+	}
+	return NewNumberExpression(float64(utf8.RuneCountInString(s[:i]) + 1))
+}
+
+// split(string, array or cell[, separator]) number
+// Split string by separator(default is FS) into array[1]...array[n], or the cells in the row from cell.
+// Return the number of the fields.
+func builtinSplit(args ...Node) Node {
+	if len(args) < 2 || 3 < len(args) {
+		fatalError("invalid as number of arguments for split()")
+	}
+	a := inOrder(args)
+	s := a[0].asString()
+
+	fs := execContext.scope.get("FS").asString()
+	if len(a) == 3 {
+		fs = a[2].asString()
+	}
+	var fields []string
+	if s != "" {
+		fields = execContext.scope.makeFSSplitReg(fs).Split(s, -1)
+	}
+
+	switch t := a[1].(type) {
+	case *Array:
+		t.clear()
+		for i, f := range fields {
+			t.set(fmt.Sprint(i+1), NewStringExpression(f))
+		}
+	case *Range:
+		addr := &cellAddress{book: t.book, sheet: t.sheet, ref: t.axis(0, 0)}
+		addr.setRowValues(fields)
+	default:
+		addr := parseCellAddress(t.asString())
+		if addr.isRange() {
+			addr.ref = addr.getRange().axis(0, 0)
+		}
+		if !addr.isCell() {
+			fatalError("split(): second argument must be an array or a cell")
+		}
+		addr.setRowValues(fields)
+	}
+	return NewNumberExpression(float64(len(fields)))
+}
+
+// sub(regexp, replacement[, string]) string
+// Replace the first match of regexp in string(default is $0), and return the replaced string.
+// $_0 in replacement is the matched string, and $_n is the n th captured string.
+// If string is omitted, $0 is updated.
+func builtinSub(args ...Node) Node {
+	return substitute("sub", args, false)
+}
+
+// gsub(regexp, replacement[, string]) string
+// Replace all matches of regexp in string(default is $0), and return the replaced string.
+// $_0 in replacement is the matched string, and $_n is the n th captured string.
+// If string is omitted, $0 is updated.
+func builtinGsub(args ...Node) Node {
+	return substitute("gsub", args, true)
+}
+
+func substitute(name string, args []Node, global bool) Node {
+	if len(args) < 2 || 3 < len(args) {
+		fatalError("invalid as number of arguments for %s()", name)
+	}
+	a := inOrder(args)
+	reg, err := regexp.Compile(a[0].asString())
+	if err != nil {
+		fatalError("%s(): '%s' is invalid regexp", name, a[0].asString())
+	}
+	repl := a[1].asString()
+
+	var target string
+	if len(a) == 3 {
+		target = a[2].asString()
+	} else {
+		target = execContext.scope.get("$0").asString()
+	}
+
+	n := -1
+	if !global {
+		n = 1
+	}
+	var b strings.Builder
+	last := 0
+	matches := reg.FindAllStringSubmatchIndex(target, n)
+	for _, m := range matches {
+		b.WriteString(target[last:m[0]])
+		b.WriteString(expandReplacement(repl, target, m))
+		last = m[1]
+	}
+	b.WriteString(target[last:])
+	ret := b.String()
+
+	if len(a) == 2 && 0 < len(matches) {
+		execContext.scope.setDollarSpecialVars(ret)
+	}
+	return NewStringExpression(ret)
+}
+
+// expandReplacement replaces $_n in the replacement with the submatch of the match m.
+func expandReplacement(repl string, target string, m []int) string {
+	var b strings.Builder
+	for i := 0; i < len(repl); i++ {
+		if !strings.HasPrefix(repl[i:], "$_") || len(repl) <= i+2 || !isDigit(rune(repl[i+2])) {
+			b.WriteByte(repl[i])
+			continue
+		}
+		j := i + 2
+		for j < len(repl) && isDigit(rune(repl[j])) {
+			j++
+		}
+		g, _ := strconv.Atoi(repl[i+2 : j])
+		if 2*g+1 < len(m) && 0 <= m[2*g] {
+			b.WriteString(target[m[2*g]:m[2*g+1]])
+		}
+		i = j - 1
+	}
+	return b.String()
+}
+
+// toupper(string) string
+func builtinToupper(args ...Node) Node {
+	if len(args) != 1 {
+		fatalError("invalid as number of arguments for toupper()")
+	}
+	return NewStringExpression(strings.ToUpper(args[0].asString()))
+}
+
+// tolower(string) string
+func builtinTolower(args ...Node) Node {
+	if len(args) != 1 {
+		fatalError("invalid as number of arguments for tolower()")
+	}
+	return NewStringExpression(strings.ToLower(args[0].asString()))
+}
+
+// trim(string[, characters]) string
+// Remove the leading and trailing characters(default is white spaces) from string.
+func builtinTrim(args ...Node) Node {
+	switch len(args) {
+	case 1:
+		return NewStringExpression(strings.TrimSpace(args[0].asString()))
+	case 2:
+		return NewStringExpression(strings.Trim(args[1].asString(), args[0].asString()))
+	}
+	fatalError("invalid as number of arguments for trim()")
+	return nil
+}
+
+// sprintf(format, values...) string
+// Return the string formatted like C's sprintf.
+func builtinSprintf(args ...Node) Node {
+	if len(args) < 1 {
+		fatalError("invalid as number of arguments for sprintf()")
+	}
+	a := inOrder(args)
+	return NewStringExpression(formatValues(a[0].asString(), a[1:]))
+}
+
+// formatValues formats the values like C's sprintf.
+// %d %i %o %x %X %u %c %s %e %E %f %F %g %G and %% are supported with flags, width and precision.
+func formatValues(format string, values []Node) string {
+	n := 0
+	next := func() Node {
+		if n < len(values) {
+			n++
+			return values[n-1]
+		}
+		return NewStringExpression("")
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			b.WriteByte(format[i])
+			continue
+		}
+
+		j := i + 1
+		spec := "%"
+		for j < len(format) && strings.IndexByte("-+ #0", format[j]) >= 0 {
+			spec += string(format[j])
+			j++
+		}
+		if j < len(format) && format[j] == '*' {
+			spec += strconv.Itoa(int(next().asNumber()))
+			j++
+		}
+		for j < len(format) && isDigit(rune(format[j])) {
+			spec += string(format[j])
+			j++
+		}
+		if j < len(format) && format[j] == '.' {
+			spec += "."
+			j++
+			if j < len(format) && format[j] == '*' {
+				spec += strconv.Itoa(int(next().asNumber()))
+				j++
+			}
+			for j < len(format) && isDigit(rune(format[j])) {
+				spec += string(format[j])
+				j++
+			}
+		}
+		if len(format) <= j {
+			b.WriteString(format[i:])
+			break
+		}
+
+		switch verb := format[j]; verb {
+		case '%':
+			b.WriteByte('%')
+		case 'd', 'i', 'u':
+			fmt.Fprintf(&b, spec+"d", int64(next().asNumber()))
+		case 'o', 'x', 'X':
+			fmt.Fprintf(&b, spec+string(verb), int64(next().asNumber()))
+		case 'e', 'E', 'f', 'F', 'g', 'G':
+			fmt.Fprintf(&b, spec+string(verb), next().asNumber())
+		case 'c':
+			v := next()
+			if e, ok := v.(*Expression); ok && e.exprType == NumberExpression {
+				fmt.Fprintf(&b, spec+"c", rune(e.number))
+			} else if r := []rune(v.asString()); 0 < len(r) {
+				fmt.Fprintf(&b, spec+"c", r[0])
+			}
+		case 's':
+			fmt.Fprintf(&b, spec+"s", next().asString())
+		default:
+			b.WriteString(format[i : j+1])
+		}
+		i = j
+	}
+	return b.String()
+}
+
+// now() number
+// Return the current time as seconds since the Unix epoch.
+func builtinNow(args ...Node) Node {
+	if len(args) != 0 {
+		fatalError("invalid as number of arguments for now()")
+	}
+	return NewNumberExpression(float64(time.Now().Unix()))
+}
+
+// date(year, month, day[, hour, min, sec]) number
+// Return the local time as seconds since the Unix epoch.
+// Out of range values are normalized like date(2024, 1, 32) is 2024-02-01.
+func builtinDate(args ...Node) Node {
+	if len(args) != 3 && len(args) != 6 {
+		fatalError("invalid as number of arguments for date()")
+	}
+	a := inOrder(args)
+	v := make([]int, 6)
+	for i := range a {
+		v[i] = int(a[i].asNumber())
+	}
+	t := time.Date(v[0], time.Month(v[1]), v[2], v[3], v[4], v[5], 0, time.Local)
+	return NewNumberExpression(timeToEpoch(t))
+}
+
+// strftime(format[, time]) string
+// Format the time(default is now) like C's strftime.
+func builtinStrftime(args ...Node) Node {
+	switch len(args) {
+	case 1:
+		return NewStringExpression(strftime(args[0].asString(), time.Now()))
+	case 2:
+		return NewStringExpression(strftime(args[1].asString(), epochToTime(args[0].asNumber())))
+	}
+	fatalError("invalid as number of arguments for strftime()")
+	return nil
+}
+
+// strptime(string, format) number
+// Parse the string in the format like C's strptime, and return the time.
+// Return -1 if the string does not match the format.
+func builtinStrptime(args ...Node) Node {
+	if len(args) != 2 {
+		fatalError("invalid as number of arguments for strptime()")
+	}
+	t, err := strptime(args[1].asString(), args[0].asString())
+	if err != nil {
+		return NewNumberExpression(-1)
+	}
+	return NewNumberExpression(timeToEpoch(t))
+}
+
+// getdate(cell) number
+// Return the date value of the cell as the time.
+// Return -1 if the cell is not a date.
+func builtinGetdate(args ...Node) Node {
+	if len(args) != 1 {
+		fatalError("invalid as number of arguments for getdate()")
+	}
+	addr := parseCellAddress(args[0].asString())
+	v := addr.book.getSheetCellRawValue(addr.sheet, addr.ref)
+
+	f, ok := maybeNumber(v)
+	if !ok {
+		return NewNumberExpression(-1)
+	}
+	t, err := serialToEpoch(f, addr.book.isDate1904())
+	if err != nil {
+		return NewNumberExpression(-1)
+	}
+	return NewNumberExpression(t)
+}
+
+// setdate(cell or range, time[, format]) number
+// Set the time to the cell as an Excel date with the number format like "yyyy/mm/dd".
+// The time must be a number like the result of strptime().
+// Return the time.
+func builtinSetdate(args ...Node) Node {
+	if len(args) < 2 || 3 < len(args) {
+		fatalError("invalid as number of arguments for setdate()")
+	}
+	a := inOrder(args)
+	t, ok := maybeNumber(a[1].asString())
+	if !ok {
+		fatalError("setdate(): second argument must be a number")
+	}
+	format := ""
+	if len(a) == 3 {
+		format = a[2].asString()
+	}
+
+	addr := parseCellAddress(a[0].asString())
+	axes := []string{addr.ref}
+	if addr.isRange() {
+		axes = addr.getRange().axes()
+	}
+	for _, axis := range axes {
+		addr.book.setSheetCellDate(addr.sheet, axis, t, format)
+	}
+	return NewNumberExpression(t)
+}
+
+// formula(cell) string
+// Return the formula of the cell like "=SUM(A1:A3)".
+// Return empty string if the cell has no formula.
+func builtinFormula(args ...Node) Node {
+	if len(args) != 1 {
+		fatalError("invalid as number of arguments for formula()")
+	}
+	addr := parseCellAddress(args[0].asString())
+	f := addr.book.getSheetCellFormula(addr.sheet, addr.ref)
+	if f == "" {
+		return NewStringExpression("")
+	}
+	return NewStringExpression("=" + f)
+}
+
+// setformula(cell or range, formula) string
+// Set the formula to the cell. The leading '=' of the formula can be omitted.
+// For a range, the formula is for the top left cell and is filled to the other cells
+// with moving the relative references like Excel's fill.
+// Return the formula.
+func builtinSetformula(args ...Node) Node {
+	if len(args) != 2 {
+		fatalError("invalid as number of arguments for setformula()")
+	}
+	a := inOrder(args)
+	formula := strings.TrimPrefix(a[1].asString(), "=")
+
+	addr := parseCellAddress(a[0].asString())
+	if !addr.isRange() {
+		addr.book.setSheetCellFormula(addr.sheet, addr.ref, formula)
+		return NewStringExpression("=" + formula)
+	}
+	r := addr.getRange()
+	for y := 0; y < r.height(); y++ {
+		for x := 0; x < r.width(); x++ {
+			addr.book.setSheetCellFormula(r.sheet, r.axis(x, y), shiftFormula(formula, x, y))
+		}
+	}
+	return NewStringExpression("=" + formula)
+}
+
+// calc([cell]) number or string
+// Calculate the formula of the cell in-process by excelize, and return the result.
+// The formula cells which are referred are calculated first, and the results are set to the cells.
+// Without the argument, calculate all formulas in the current workbook, and return the number of them.
+func builtinCalc(args ...Node) Node {
+	switch len(args) {
+	case 0:
+		return NewNumberExpression(float64(execContext.spreadsheet.calcAll()))
+	case 1:
+		addr := parseCellAddress(args[0].asString())
+		if addr.isRange() {
+			fatalError("calc(): range '%s' is not supported", addr.ref)
+		}
+		v := newFormulaCalc(addr.book).cellValue(addr.sheet, strings.ToUpper(addr.ref))
+		switch v := formulaCellValue(v).(type) {
+		case float64:
+			return NewNumberExpression(v)
+		case bool:
+			return NewNumberExpression(boolToNumber(v))
+		}
+		return NewStringExpression(v)
+	}
+	fatalError("invalid as number of arguments for calc()")
+	return nil
+}
+
+// style(cell or range, spec) string
+// Change the style of the cells by the spec like "bold;size=14;fill=#FFFF00;border=thin;numfmt=#,##0.00".
+// The settings which are not in the spec are kept.
+// Return the spec.
+func builtinStyle(args ...Node) Node {
+	if len(args) != 2 {
+		fatalError("invalid as number of arguments for style()")
+	}
+	a := inOrder(args)
+	spec, err := parseStyleSpec(a[1].asString())
+	if err != nil {
+		fatalError("style(): %v", err)
+	}
+
+	addr := parseCellAddress(a[0].asString())
+	axes := []string{addr.ref}
+	if addr.isRange() {
+		axes = addr.getRange().axes()
+	}
+	for _, axis := range axes {
+		addr.book.setSheetCellStyleSpec(addr.sheet, axis, spec)
+	}
+	return NewStringExpression(spec.text)
+}
+
+// getstyle(cell) string
+// Return the style of the cell as the spec of style().
+func builtinGetstyle(args ...Node) Node {
+	if len(args) != 1 {
+		fatalError("invalid as number of arguments for getstyle()")
+	}
+	addr := parseCellAddress(args[0].asString())
+	id := addr.book.getSheetCellStyle(addr.sheet, addr.ref)
+	return NewStringExpression(addr.book.styleSpecOf(addr.book.styleOf(id)))
+}
+
+// merge(range) string
+// Merge the cells in the range. The value of the top left cell is kept.
+// Return the range.
+func builtinMerge(args ...Node) Node {
+	if len(args) != 1 {
+		fatalError("invalid as number of arguments for merge()")
+	}
+	addr := parseCellAddress(args[0].asString())
+	if !addr.isRange() {
+		fatalError("merge(): '%s' is not a range", addr.ref)
+	}
+	r := addr.getRange()
+	addr.book.mergeSheetCells(addr.sheet, r)
+	return NewStringExpression(r.ref())
+}
+
+// unmerge(cell or range) string
+// Unmerge the merged cells which overlap the cell or the range.
+// Return the cell or the range.
+func builtinUnmerge(args ...Node) Node {
+	if len(args) != 1 {
+		fatalError("invalid as number of arguments for unmerge()")
+	}
+	addr := parseCellAddress(args[0].asString())
+	ref := addr.ref
+	if !addr.isRange() {
+		addr.ref = ref + ":" + ref
+	}
+	r := addr.getRange()
+	addr.book.unmergeSheetCells(addr.sheet, r)
+	return NewStringExpression(ref)
+}
+
+// merged(array[, sheet]) number
+// Set the merged ranges like "A1:C1" of the sheet(default is the active sheet) to the array.
+// Return the number of the merged ranges.
+func builtinMerged(args ...Node) Node {
+	if len(args) < 1 || 2 < len(args) {
+		fatalError("invalid as number of arguments for merged()")
+	}
+	a := inOrder(args)
+	arr, ok := a[0].(*Array)
+	if !ok {
+		fatalError("merged(): first argument must be an array")
+	}
+	sheet := execContext.spreadsheet.activeSheet
+	if len(a) == 2 {
+		sheet = a[1].asString()
+		if !execContext.spreadsheet.existSheetName(sheet) {
+			fatalError("sheet '%s' not exist", sheet)
+		}
+	}
+
+	arr.clear()
+	ranges := execContext.spreadsheet.getSheetMergedRanges(sheet)
+	for i, r := range ranges {
+		arr.set(fmt.Sprint(i+1), NewStringExpression(r))
+	}
+	return NewNumberExpression(float64(len(ranges)))
+}
+
+// colwidth(columns[, width]) number
+// Set the width of the columns like "B" or "B:D".
+// Return the width, or the width of the first column if width is omitted.
+func builtinColwidth(args ...Node) Node {
+	if len(args) < 1 || 2 < len(args) {
+		fatalError("invalid as number of arguments for colwidth()")
+	}
+	a := inOrder(args)
+	addr := parseCellAddress(a[0].asString())
+	from, to, err := parseColumns(addr.ref)
+	if err != nil {
+		fatalError("colwidth(): %v", err)
+	}
+	if len(a) == 1 {
+		return NewNumberExpression(addr.book.getSheetColWidth(addr.sheet, from))
+	}
+	w := a[1].asNumber()
+	addr.book.setSheetColWidth(addr.sheet, from, to, w)
+	return NewNumberExpression(w)
+}
+
+// rowheight(rows[, height]) number
+// Set the height of the rows like "3" or "3:5".
+// Return the height, or the height of the first row if height is omitted.
+func builtinRowheight(args ...Node) Node {
+	if len(args) < 1 || 2 < len(args) {
+		fatalError("invalid as number of arguments for rowheight()")
+	}
+	a := inOrder(args)
+	addr := parseCellAddress(a[0].asString())
+	from, to, err := parseRows(addr.ref)
+	if err != nil {
+		fatalError("rowheight(): %v", err)
+	}
+	if len(a) == 1 {
+		return NewNumberExpression(addr.book.getSheetRowHeight(addr.sheet, from))
+	}
+	h := a[1].asNumber()
+	addr.book.setSheetRowHeight(addr.sheet, from, to, h)
+	return NewNumberExpression(h)
+}
+
+func boolToNumber(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// hidecol(columns[, hidden]) number
+// Hide the columns like "B" or "B:D". The columns are shown if hidden is false.
+// Return hidden.
+func builtinHidecol(args ...Node) Node {
+	if len(args) < 1 || 2 < len(args) {
+		fatalError("invalid as number of arguments for hidecol()")
+	}
+	a := inOrder(args)
+	hidden := len(a) == 1 || a[1].isTruthy()
+	addr := parseCellAddress(a[0].asString())
+	from, to, err := parseColumns(addr.ref)
+	if err != nil {
+		fatalError("hidecol(): %v", err)
+	}
+	addr.book.setSheetColVisible(addr.sheet, from, to, !hidden)
+	return NewNumberExpression(boolToNumber(hidden))
+}
+
+// hiderow(rows[, hidden]) number
+// Hide the rows like "3" or "3:5". The rows are shown if hidden is false.
+// Return hidden.
+func builtinHiderow(args ...Node) Node {
+	if len(args) < 1 || 2 < len(args) {
+		fatalError("invalid as number of arguments for hiderow()")
+	}
+	a := inOrder(args)
+	hidden := len(a) == 1 || a[1].isTruthy()
+	addr := parseCellAddress(a[0].asString())
+	from, to, err := parseRows(addr.ref)
+	if err != nil {
+		fatalError("hiderow(): %v", err)
+	}
+	addr.book.setSheetRowVisible(addr.sheet, from, to, !hidden)
+	return NewNumberExpression(boolToNumber(hidden))
+}
+
+// freeze(cell) string
+// Freeze the rows above the cell and the columns left of the cell.
+// freeze("A2") freezes the first row, and freeze("A1") unfreezes.
+// Return the cell.
+func builtinFreeze(args ...Node) Node {
+	if len(args) != 1 {
+		fatalError("invalid as number of arguments for freeze()")
+	}
+	addr := parseCellAddress(args[0].asString())
+	addr.book.freezeSheetPanes(addr.sheet, strings.ToUpper(addr.ref))
+	return NewStringExpression(addr.ref)
+}
+
+// insertrow(row[, count]) number
+// Insert count rows before the row like 3. The formulas and the merged cells are adjusted.
+// "3:5" inserts 3 rows if count is omitted.
+// Return the row.
+func builtinInsertrow(args ...Node) Node {
+	addr, row, count := rowsArguments("insertrow", args)
+	addr.book.insertSheetRows(addr.sheet, row, count)
+	return NewNumberExpression(float64(row))
+}
+
+// deleterow(row[, count]) number
+// Delete count rows from the row like 3. The formulas and the merged cells are adjusted.
+// "3:5" deletes 3 rows if count is omitted.
+// Return the row.
+func builtinDeleterow(args ...Node) Node {
+	addr, row, count := rowsArguments("deleterow", args)
+	addr.book.deleteSheetRows(addr.sheet, row, count)
+	return NewNumberExpression(float64(row))
+}
+
+// rowsArguments returns the address, the first row and the number of rows of the arguments.
+func rowsArguments(name string, args []Node) (*cellAddress, int, int) {
+	if len(args) < 1 || 2 < len(args) {
+		fatalError("invalid as number of arguments for %s()", name)
+	}
+	a := inOrder(args)
+	addr := parseCellAddress(a[0].asString())
+	from, to, err := parseRows(addr.ref)
+	if err != nil {
+		fatalError("%s(): %v", name, err)
+	}
+	count := to - from + 1
+	if len(a) == 2 {
+		count = int(a[1].asNumber())
+	}
+	if count < 1 {
+		fatalError("%s(): count must be positive", name)
+	}
+	return addr, from, count
+}
+
+// insertcol(column[, count]) string
+// Insert count columns before the column like "C" or 3. The formulas and the merged cells are adjusted.
+// "C:E" inserts 3 columns if count is omitted.
+// Return the column name.
+func builtinInsertcol(args ...Node) Node {
+	addr, col, count := colsArguments("insertcol", args)
+	addr.book.insertSheetCols(addr.sheet, col, count)
+	name, _ := columnNumberToName(col)
+	return NewStringExpression(name)
+}
+
+// deletecol(column[, count]) string
+// Delete count columns from the column like "C" or 3. The formulas and the merged cells are adjusted.
+// "C:E" deletes 3 columns if count is omitted.
+// Return the column name.
+func builtinDeletecol(args ...Node) Node {
+	addr, col, count := colsArguments("deletecol", args)
+	addr.book.deleteSheetCols(addr.sheet, col, count)
+	name, _ := columnNumberToName(col)
+	return NewStringExpression(name)
+}
+
+// colsArguments returns the address, the first column number and the number of columns of the arguments.
+func colsArguments(name string, args []Node) (*cellAddress, int, int) {
+	if len(args) < 1 || 2 < len(args) {
+		fatalError("invalid as number of arguments for %s()", name)
+	}
+	a := inOrder(args)
+	addr := parseCellAddress(a[0].asString())
+	if n, err := strconv.Atoi(addr.ref); err == nil {
+		c, err := columnNumberToName(n)
+		if err != nil {
+			fatalError("%s(): '%s' is invalid column", name, addr.ref)
+		}
+		addr.ref = c
+	}
+	from, to, err := parseColumns(addr.ref)
+	if err != nil {
+		fatalError("%s(): %v", name, err)
+	}
+	fromNum, _ := columnNameToNumber(from)
+	toNum, _ := columnNameToNumber(to)
+	if toNum < fromNum {
+		fromNum, toNum = toNum, fromNum
+	}
+	count := toNum - fromNum + 1
+	if len(a) == 2 {
+		count = int(a[1].asNumber())
+	}
+	if count < 1 {
+		fatalError("%s(): count must be positive", name)
+	}
+	return addr, fromNum, count
+}
+
+// moverange(src, dst) string
+// Move the values, the formulas and the styles of the cells in src to dst like cut and paste.
+// dst is the top left cell or a range of the same sheet. The formulas which refer to src are adjusted.
+// Return the moved range.
+func builtinMoverange(args ...Node) Node {
+	if len(args) != 2 {
+		fatalError("invalid as number of arguments for moverange()")
+	}
+	a := inOrder(args)
+	src := parseCellAddress(a[0].asString())
+	dst := parseCellAddress(a[1].asString())
+	if src.book != dst.book || src.sheet != dst.sheet {
+		fatalError("moverange(): '%s' is not in the sheet of '%s'", a[1].asString(), a[0].asString())
+	}
+	if !src.isRange() {
+		src.ref = src.ref + ":" + src.ref
+	}
+	to := src.book.moveSheetRange(src.getRange(), strings.Split(dst.ref, ":")[0])
+	return NewStringExpression(to.ref())
+}
+
+// sort(range, keys...) string
+// Sort the rows of the range stably by the key columns like "C desc" or "A num".
+// The key "header" keeps the first row of the range.
+// Return the range.
+func builtinSort(args ...Node) Node {
+	if len(args) < 1 {
+		fatalError("invalid as number of arguments for sort()")
+	}
+	a := inOrder(args)
+	addr := parseCellAddress(a[0].asString())
+	if !addr.isRange() {
+		fatalError("sort(): '%s' is not a range", addr.ref)
+	}
+	texts := make([]string, len(a)-1)
+	for i, k := range a[1:] {
+		texts[i] = k.asString()
+	}
+	keys, header, err := parseSortKeys(texts)
+	if err != nil {
+		fatalError("sort(): %v", err)
+	}
+	r := addr.getRange()
+	addr.book.sortSheetRange(r, keys, header)
+	return NewStringExpression(r.ref())
+}
+
+// autofilter(range[, criteria]) number
+// Add the filter dropdowns to the range whose first row is the header.
+// The rows which do not meet the criteria like "B > 2000" or "B == East or B == West" are hidden.
+// Return the number of the shown rows.
+func builtinAutofilter(args ...Node) Node {
+	if len(args) < 1 || 2 < len(args) {
+		fatalError("invalid as number of arguments for autofilter()")
+	}
+	a := inOrder(args)
+	addr := parseCellAddress(a[0].asString())
+	if !addr.isRange() {
+		fatalError("autofilter(): '%s' is not a range", addr.ref)
+	}
+	criteria := ""
+	if len(a) == 2 {
+		criteria = a[1].asString()
+	}
+	n, err := addr.book.autoFilterSheetRange(addr.getRange(), criteria)
+	if err != nil {
+		fatalError("autofilter(): %v", err)
+	}
+	return NewNumberExpression(float64(n))
+}
+
+// table(range[, name[, style]]) string
+// Add the table whose first row is the header to the range.
+// The name is "Table1" and so on if omitted, and the style is "TableStyleMedium2" if omitted.
+// The style "none" adds the table without the style.
+// Return the name.
+func builtinTable(args ...Node) Node {
+	if len(args) < 1 || 3 < len(args) {
+		fatalError("invalid as number of arguments for table()")
+	}
+	a := inOrder(args)
+	addr := parseCellAddress(a[0].asString())
+	if !addr.isRange() {
+		fatalError("table(): '%s' is not a range", addr.ref)
+	}
+	name := ""
+	if 2 <= len(a) {
+		name = a[1].asString()
+	}
+	for i := len(addr.book.getTables()) + 1; name == ""; i++ {
+		if n := fmt.Sprintf("Table%d", i); addr.book.getTable(n) == nil {
+			name = n
+		}
+	}
+	style := DefaultTableStyle
+	if len(a) == 3 {
+		style = a[2].asString()
+		if strings.EqualFold(style, "none") {
+			style = ""
+		}
+	}
+	addr.book.addSheetTable(addr.getRange(), name, style)
+	return NewStringExpression(name)
+}
+
+// tables(array[, book]) number
+// Set the names of the tables in the workbook(default is the current workbook) to the array.
+// Return the number of the tables.
+func builtinTables(args ...Node) Node {
+	if len(args) < 1 || 2 < len(args) {
+		fatalError("invalid as number of arguments for tables()")
+	}
+	a := inOrder(args)
+	arr, ok := a[0].(*Array)
+	if !ok {
+		fatalError("tables(): first argument must be an array")
+	}
+	book := execContext.spreadsheet
+	if len(a) == 2 {
+		b, ok := execContext.books[a[1].asString()]
+		if !ok {
+			fatalError("workbook '%s' is not open", a[1].asString())
+		}
+		book = b
+	}
+
+	arr.clear()
+	tables := book.getTables()
+	for i, t := range tables {
+		arr.set(fmt.Sprint(i+1), NewStringExpression(t.name))
+	}
+	return NewNumberExpression(float64(len(tables)))
+}
+
+// tablerange(ref) string
+// Return the range qualified by the sheet like "Sheet1!A2:D10" of the table name or the structured reference
+// like "Sales[Amount]". The table name alone refers to the data rows, and "Sales[#All]" refers to the whole table.
+func builtinTablerange(args ...Node) Node {
+	if len(args) != 1 {
+		fatalError("invalid as number of arguments for tablerange()")
+	}
+	addr := parseCellAddress(args[0].asString())
+	if addr.table == "" {
+		fatalError("tablerange(): table of '%s' not exist", args[0].asString())
+	}
+	return NewStringExpression(addr.qualifier + addr.ref)
+}
+
+// cellsArgument returns the range of the cell or the range of the argument.
+func cellsArgument(arg Node) *Range {
+	addr := parseCellAddress(arg.asString())
+	if addr.isRange() {
+		return addr.getRange()
+	}
+	r := addr.book.getSheetRange(addr.sheet, addr.ref+":"+addr.ref)
+	r.qualifier = addr.qualifier
+	return r
+}
+
+// condformat(cell or range, rule, spec) string
+// Add the conditional formatting which applies the style of the spec to the cells matching the rule.
+// The rule is like "> 100", "between 1 and 10", "== East", "top 10", "bottom 5%", "above average",
+// "duplicate", "unique" or the formula like "=$C2>$B2".
+// Return the range.
+func builtinCondformat(args ...Node) Node {
+	if len(args) != 3 {
+		fatalError("invalid as number of arguments for condformat()")
+	}
+	a := inOrder(args)
+	spec, err := parseStyleSpec(a[2].asString())
+	if err != nil {
+		fatalError("condformat(): %v", err)
+	}
+	r := cellsArgument(a[0])
+	if err := r.book.addSheetCondFormat(r, a[1].asString(), spec); err != nil {
+		fatalError("condformat(): %v", err)
+	}
+	return NewStringExpression(r.sqref())
+}
+
+// colorscale(range, min_color[, mid_color], max_color) string
+// Add the color scale from the color of the minimum to the color of the maximum.
+// Return the range.
+func builtinColorscale(args ...Node) Node {
+	if len(args) < 3 || 4 < len(args) {
+		fatalError("invalid as number of arguments for colorscale()")
+	}
+	a := inOrder(args)
+	colors := make([]string, 0, 3)
+	for _, c := range a[1:] {
+		colors = append(colors, c.asString())
+	}
+	r := cellsArgument(a[0])
+	if err := r.book.addSheetColorScale(r, colors); err != nil {
+		fatalError("colorscale(): %v", err)
+	}
+	return NewStringExpression(r.sqref())
+}
+
+// databar(range[, color]) string
+// Add the data bars of the color(default is "#638EC6").
+// Return the range.
+func builtinDatabar(args ...Node) Node {
+	if len(args) < 1 || 2 < len(args) {
+		fatalError("invalid as number of arguments for databar()")
+	}
+	a := inOrder(args)
+	color := "#638EC6"
+	if len(a) == 2 {
+		color = a[1].asString()
+	}
+	r := cellsArgument(a[0])
+	if err := r.book.addSheetDataBar(r, color); err != nil {
+		fatalError("databar(): %v", err)
+	}
+	return NewStringExpression(r.sqref())
+}
+
+// validate(cell or range, rule[, options]) string
+// Add the data validation of the rule like "list Yes,No", "list =$E$1:$E$3", "whole between 1 and 100",
+// "decimal >= 0", "textlength <= 10", "date >= 2020-04-01", "time < 18:00" or "custom =COUNTIF(A:A,A2)=1".
+// The options are like "input='Enter 1 to 100';input-title=Amount;error='Out of range';error-style=warning".
+// Return the range.
+func builtinValidate(args ...Node) Node {
+	if len(args) < 2 || 3 < len(args) {
+		fatalError("invalid as number of arguments for validate()")
+	}
+	a := inOrder(args)
+	options := ""
+	if len(a) == 3 {
+		options = a[2].asString()
+	}
+	r := cellsArgument(a[0])
+	if err := r.book.addSheetValidation(r, a[1].asString(), options); err != nil {
+		fatalError("validate(): %v", err)
+	}
+	return NewStringExpression(r.sqref())
+}
+
+// comment(cell[, text[, author]]) string
+// Set the comment(note) to the cell. The author is shown as the first line of the comment like Excel.
+// The empty text removes the comment.
+// Return the text of the comment, or the current text if text is omitted.
+func builtinComment(args ...Node) Node {
+	if len(args) < 1 || 3 < len(args) {
+		fatalError("invalid as number of arguments for comment()")
+	}
+	a := inOrder(args)
+	addr := parseCellAddress(a[0].asString())
+	if addr.isRange() {
+		fatalError("comment(): '%s' is not a cell", addr.ref)
+	}
+	if len(a) == 1 {
+		if c := addr.book.getSheetComment(addr.sheet, addr.ref); c != nil {
+			return NewStringExpression(c.text)
+		}
+		return NewStringExpression("")
+	}
+	author := ""
+	if len(a) == 3 {
+		author = a[2].asString()
+	}
+	text := a[1].asString()
+	addr.book.setSheetComment(addr.sheet, addr.ref, text, author)
+	return NewStringExpression(text)
+}
+
+// comments(array[, sheet]) number
+// Set the comments of the sheet(default is the active sheet) to the array in the order of the rows.
+// array[i] is the cell like "B3", and array[i, "author"] and array[i, "text"] are the author and the text.
+// Return the number of the comments.
+func builtinComments(args ...Node) Node {
+	if len(args) < 1 || 2 < len(args) {
+		fatalError("invalid as number of arguments for comments()")
+	}
+	a := inOrder(args)
+	arr, ok := a[0].(*Array)
+	if !ok {
+		fatalError("comments(): first argument must be an array")
+	}
+	sheet := execContext.spreadsheet.activeSheet
+	if len(a) == 2 {
+		sheet = a[1].asString()
+		if !execContext.spreadsheet.existSheetName(sheet) {
+			fatalError("sheet '%s' not exist", sheet)
+		}
+	}
+
+	arr.clear()
+	sep := execContext.scope.get("SUBSEP").asString()
+	comments := execContext.spreadsheet.getSheetComments(sheet)
+	for i, c := range comments {
+		key := fmt.Sprint(i + 1)
+		arr.set(key, NewStringExpression(c.cell))
+		arr.set(key+sep+"author", NewStringExpression(c.author))
+		arr.set(key+sep+"text", NewStringExpression(c.text))
+	}
+	return NewNumberExpression(float64(len(comments)))
+}
+
+// link(cell[, target[, display]]) string
+// Set the hyperlink to the cell. The target is the URL like "https://example.com",
+// or the location in the workbook like "#Sheet2!A1". The empty target removes the hyperlink.
+// The display text is set to the cell, or the target is set if the display is omitted and the cell is empty.
+// Return the target, or the current target if target is omitted.
+func builtinLink(args ...Node) Node {
+	if len(args) < 1 || 3 < len(args) {
+		fatalError("invalid as number of arguments for link()")
+	}
+	a := inOrder(args)
+	addr := parseCellAddress(a[0].asString())
+	if addr.isRange() {
+		fatalError("link(): '%s' is not a cell", addr.ref)
+	}
+	if len(a) == 1 {
+		return NewStringExpression(addr.book.getSheetCellLink(addr.sheet, addr.ref))
+	}
+	display := ""
+	if len(a) == 3 {
+		display = a[2].asString()
+	}
+	target := a[1].asString()
+	addr.book.setSheetCellLink(addr.sheet, addr.ref, target, display)
+	return NewStringExpression(target)
+}
+
+// chart(range, type, cell[, options]) string
+// Add the chart of the type like "line", "col", "bar", "pie" or "scatter" to the cell.
+// The first row of the range is the names of the series, and the first column is the categories.
+// The options are like "title='Monthly sales';legend=right;width=640;height=320;series=rows;labels=value".
+// Return the cell.
+func builtinChart(args ...Node) Node {
+	if len(args) < 3 || 4 < len(args) {
+		fatalError("invalid as number of arguments for chart()")
+	}
+	a := inOrder(args)
+	data := parseCellAddress(a[0].asString())
+	if !data.isRange() {
+		fatalError("chart(): '%s' is not a range", data.ref)
+	}
+	addr := parseCellAddress(a[2].asString())
+	if addr.isRange() {
+		fatalError("chart(): '%s' is not a cell", addr.ref)
+	}
+	if data.book != addr.book {
+		fatalError("chart(): range '%s' is not in the workbook of the cell", a[0].asString())
+	}
+	options := ""
+	if len(a) == 4 {
+		options = a[3].asString()
+	}
+	if err := addr.book.addSheetChart(addr.sheet, addr.ref, data.getRange(), a[1].asString(), options); err != nil {
+		fatalError("chart(): %v", err)
+	}
+	return NewStringExpression(addr.ref)
+}
+
+// image(path, cell[, scale]) string
+// Add the image file like PNG, JPEG or GIF to the cell with the scale(default is 1).
+// Return the cell.
+func builtinImage(args ...Node) Node {
+	if len(args) < 2 || 3 < len(args) {
+		fatalError("invalid as number of arguments for image()")
+	}
+	a := inOrder(args)
+	addr := parseCellAddress(a[1].asString())
+	if addr.isRange() {
+		fatalError("image(): '%s' is not a cell", addr.ref)
+	}
+	scale := 1.0
+	if len(a) == 3 {
+		scale = a[2].asNumber()
+	}
+	if err := addr.book.addSheetImage(addr.sheet, addr.ref, a[0].asString(), scale); err != nil {
+		fatalError("image(): %v", err)
+	}
+	return NewStringExpression(addr.ref)
+}
+
+// names(array) number
+// Set the defined names of the workbook to the array.
+// array[i] is the name, array[i, "ref"] is the reference like "Sheet1!$F$20",
+// and array[i, "scope"] is the sheet of the name or "" if the name is scoped to the workbook.
+// Return the number of the names.
+func builtinNames(args ...Node) Node {
+	if len(args) != 1 {
+		fatalError("invalid as number of arguments for names()")
+	}
+	arr, ok := args[0].(*Array)
+	if !ok {
+		fatalError("names(): first argument must be an array")
+	}
+
+	arr.clear()
+	sep := execContext.scope.get("SUBSEP").asString()
+	names := execContext.spreadsheet.getDefinedNames()
+	for i, n := range names {
+		key := fmt.Sprint(i + 1)
+		arr.set(key, NewStringExpression(n.name))
+		arr.set(key+sep+"ref", NewStringExpression(n.refersTo))
+		arr.set(key+sep+"scope", NewStringExpression(n.scope))
+	}
+	return NewNumberExpression(float64(len(names)))
+}
+
+// defname(name, ref[, sheet]) string
+// Define the name of the cell or the range. The name is scoped to the sheet if sheet is given,
+// otherwise to the workbook. The ref without the sheet is of the sheet if it is given, otherwise of the active sheet.
+// The ref beginning with "=" like "=0.1" is defined as the formula. The existing name of the same scope is replaced.
+// Return the reference like "Sheet1!$F$20".
+func builtinDefname(args ...Node) Node {
+	if len(args) < 2 || 3 < len(args) {
+		fatalError("invalid as number of arguments for defname()")
+	}
+	a := inOrder(args)
+	book := execContext.spreadsheet
+	scope := ""
+	if len(a) == 3 {
+		scope = a[2].asString()
+	}
+	refersTo := a[1].asString()
+	if strings.HasPrefix(refersTo, "=") {
+		refersTo = refersTo[1:]
+	} else {
+		ref := refersTo
+		if scope != "" && indexUnquoted(ref, '!') < 0 {
+			// the ref without the sheet is of the sheet of the scope
+			if !book.existSheetName(scope) {
+				fatalError("defname(): sheet '%s' not exist", scope)
+			}
+			ref = quoteSheetName(scope) + "!" + ref
+		}
+		r := cellsArgument(NewStringExpression(ref))
+		if r.book != book {
+			fatalError("defname(): '%s' is not in the current workbook", a[1].asString())
+		}
+		f := formulaRef{
+			col:    [2]int{r.startCol, r.endCol},
+			row:    [2]int{r.startRow, r.endRow},
+			absCol: [2]bool{true, true},
+			absRow: [2]bool{true, true},
+			area:   r.startCol != r.endCol || r.startRow != r.endRow,
+		}
+		refersTo = quoteSheetName(r.sheet) + "!" + f.String()
+	}
+	if err := book.setDefinedName(a[0].asString(), refersTo, scope); err != nil {
+		fatalError("defname(): %v", err)
+	}
+	return NewStringExpression(refersTo)
+}
+
+// undefname(name[, sheet]) number
+// Delete the name scoped to the sheet if sheet is given, otherwise to the workbook.
+// Return 1 if the name is deleted, otherwise 0.
+func builtinUndefname(args ...Node) Node {
+	if len(args) < 1 || 2 < len(args) {
+		fatalError("invalid as number of arguments for undefname()")
+	}
+	a := inOrder(args)
+	scope := ""
+	if len(a) == 2 {
+		scope = a[1].asString()
+	}
+	if execContext.spreadsheet.deleteDefinedName(a[0].asString(), scope) {
+		return NewNumberExpression(1)
+	}
+	return NewNumberExpression(0)
+}
+
+// exportcsv(path[, range[, options]]) number
+// Write the values of the range, or the used range of the sheet, as CSV to the path ("-" is the output).
+// The range defaults to the used range of the active sheet.
+// The options are like "delim=tab;quote=single;encoding=shift_jis;crlf;bom".
+// Return the number of the records.
+func builtinExportcsv(args ...Node) Node {
+	if len(args) < 1 || 3 < len(args) {
+		fatalError("invalid as number of arguments for exportcsv()")
+	}
+	a := inOrder(args)
+	path := a[0].asString()
+	book := execContext.spreadsheet
+	r := book.getSheetUsedRange(book.activeSheet)
+	if 2 <= len(a) && a[1].asString() != "" {
+		if book.existSheetName(a[1].asString()) {
+			r = book.getSheetUsedRange(a[1].asString())
+		} else {
+			r = cellsArgument(a[1])
+			book = r.book
+		}
+	}
+	options := ""
+	if len(a) == 3 {
+		options = a[2].asString()
+	}
+	opts, err := parseCSVOptions(options, path)
+	if err != nil {
+		fatalError("exportcsv(): %v", err)
+	}
+	n, err := book.exportCSV(r, path, opts)
+	if err != nil {
+		fatalError("exportcsv(): %v", err)
+	}
+	return NewNumberExpression(float64(n))
+}
+
+// importcsv(path, cell[, options]) number
+// Read the CSV file to the cells from the cell. The values like numbers are set as numbers unless "text" is specified.
+// The options are like "delim=tab;quote=single;encoding=shift_jis;text".
+// Return the number of the records.
+func builtinImportcsv(args ...Node) Node {
+	if len(args) < 2 || 3 < len(args) {
+		fatalError("invalid as number of arguments for importcsv()")
+	}
+	a := inOrder(args)
+	path := a[0].asString()
+	addr := parseCellAddress(a[1].asString())
+	if addr.isRange() {
+		fatalError("importcsv(): '%s' is not a cell", addr.ref)
+	}
+	options := ""
+	if len(a) == 3 {
+		options = a[2].asString()
+	}
+	opts, err := parseCSVOptions(options, path)
+	if err != nil {
+		fatalError("importcsv(): %v", err)
+	}
+	n, err := addr.book.importCSV(addr, path, opts)
+	if err != nil {
+		fatalError("importcsv(): %v", err)
+	}
+	return NewNumberExpression(float64(n))
+}
+
+// dump(format[, range[, options]]) string
+// Return the values of the range, or the used range of the sheet, as json, ndjson, md(Markdown table) or html(HTML table).
+// The range defaults to the used range of the active sheet.
+// The option "header" uses the first row as the header, and the rows of json and ndjson are the objects keyed by it.
+func builtinDump(args ...Node) Node {
+	if len(args) < 1 || 3 < len(args) {
+		fatalError("invalid as number of arguments for dump()")
+	}
+	a := inOrder(args)
+	book := execContext.spreadsheet
+	r := book.getSheetUsedRange(book.activeSheet)
+	trim := true
+	if 2 <= len(a) && a[1].asString() != "" {
+		if book.existSheetName(a[1].asString()) {
+			r = book.getSheetUsedRange(a[1].asString())
+		} else {
+			r = cellsArgument(a[1])
+			book = r.book
+			trim = false
+		}
+	}
+	header := false
+	if len(a) == 3 {
+		for _, item := range splitStyleSpec(a[2].asString()) {
+			switch strings.TrimSpace(item) {
+			case "":
+			case "header":
+				header = true
+			default:
+				fatalError("dump(): '%s' is unknown option of dump", item)
+			}
+		}
+	}
+	s, err := book.getDumpTable(r, trim, header).dumpText(a[0].asString(), header)
+	if err != nil {
+		fatalError("dump(): %v", err)
+	}
+	return NewStringExpression(s)
+}
+
+// json(string, array) number
+// Parse the JSON text into the array. The nested values are array[key1, key2...], and the indexes of arrays start by 1.
+// true and false are 1 and 0, null is "", and a scalar is set to array[1].
+// Return the number of the members or the elements of the top level.
+func builtinJSON(args ...Node) Node {
+	if len(args) != 2 {
+		fatalError("invalid as number of arguments for json()")
+	}
+	a := inOrder(args)
+	arr, ok := a[1].(*Array)
+	if !ok {
+		fatalError("json(): second argument must be an array")
+	}
+	n, err := parseJSON(a[0].asString(), arr)
+	if err != nil {
+		fatalError("json(): %v", err)
+	}
+	return NewNumberExpression(float64(n))
+}
+
+// importjson(path, cell[, columns]) number
+// Read the array of objects, or the objects like NDJSON, of the JSON file("-" is the input) to the cells from the cell.
+// The first row is the keys, and the nested keys are the dotted names like "address.city".
+// columns like "id,name,address.city" specifies the columns and the order.
+// Return the number of the records.
+func builtinImportjson(args ...Node) Node {
+	if len(args) < 2 || 3 < len(args) {
+		fatalError("invalid as number of arguments for importjson()")
+	}
+	a := inOrder(args)
+	addr := parseCellAddress(a[1].asString())
+	if addr.isRange() {
+		fatalError("importjson(): '%s' is not a cell", addr.ref)
+	}
+	var columns []string
+	if len(a) == 3 {
+		for _, c := range strings.Split(a[2].asString(), ",") {
+			if c = strings.TrimSpace(c); c != "" {
+				columns = append(columns, c)
+			}
+		}
+	}
+	n, err := addr.book.importJSON(addr, a[0].asString(), columns)
+	if err != nil {
+		fatalError("importjson(): %v", err)
+	}
+	return NewNumberExpression(float64(n))
+}
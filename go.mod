@@ -3,6 +3,8 @@ module github.com/twinbird/cell
 go 1.16
 
 require (
-	github.com/360EntSecGroup-Skylar/excelize/v2 v2.3.2
+	github.com/360EntSecGroup-Skylar/excelize/v2 v2.4.0
+	github.com/xuri/efp v0.0.0-20210322160811-ab561f5b45e3
+	golang.org/x/text v0.3.6
 	golang.org/x/tools v0.1.0 // indirect
 )
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"strings"
+)
+
+// LinkStyle is the style of the cells which have the hyperlinks like Excel.
+const LinkStyle = "color=#0563C1;underline"
+
+// getSheetCellLink returns the target of the hyperlink of the cell like "https://example.com" or "#Sheet2!A1",
+// or "" if the cell has no hyperlink.
+func (s *Spreadsheet) getSheetCellLink(sheet string, axis string) string {
+	ok, target, err := s.file.GetCellHyperLink(sheet, axis)
+	if err != nil {
+		fatalError("cell '%s' refer failed", axis)
+	}
+	if !ok {
+		return ""
+	}
+	if ws := s.file.Sheet[s.getSheetParts()[sheet]]; ws != nil && ws.Hyperlinks != nil {
+		for _, link := range ws.Hyperlinks.Hyperlink {
+			if link.RID == "" && link.Location == target {
+				return "#" + target
+			}
+		}
+	}
+	return target
+}
+
+// setSheetCellLink replaces the hyperlink of the cell. The target like "#Sheet2!A1" refers to the location in the workbook,
+// and the empty target removes the hyperlink. The display text is set to the cell, or the target if the cell is empty.
+func (s *Spreadsheet) setSheetCellLink(sheet string, axis string, target string, display string) {
+	// GetCellHyperLink loads the sheet into excelize
+	if _, _, err := s.file.GetCellHyperLink(sheet, axis); err != nil {
+		fatalError("cell '%s' refer failed", axis)
+	}
+	if ws := s.file.Sheet[s.getSheetParts()[sheet]]; ws != nil && ws.Hyperlinks != nil {
+		links := ws.Hyperlinks.Hyperlink[:0]
+		for _, link := range ws.Hyperlinks.Hyperlink {
+			if !strings.EqualFold(link.Ref, axis) {
+				links = append(links, link)
+			}
+		}
+		ws.Hyperlinks.Hyperlink = links
+	}
+	if target == "" {
+		return
+	}
+
+	link, linkType := target, "External"
+	if strings.HasPrefix(target, "#") {
+		link, linkType = target[1:], "Location"
+	}
+	if err := s.file.SetCellHyperLink(sheet, axis, link, linkType); err != nil {
+		fatalError("cell '%s' set hyperlink failed (%v)", axis, err)
+	}
+	if display != "" {
+		s.setSheetCellValue(sheet, axis, display)
+	} else if s.getSheetCellValue(sheet, axis) == "" {
+		s.setSheetCellValue(sheet, axis, link)
+	}
+	spec, _ := parseStyleSpec(LinkStyle)
+	s.setSheetCellStyleSpec(sheet, axis, spec)
+}
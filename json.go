@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/360EntSecGroup-Skylar/excelize/v2"
+)
+
+// JSON is read by the tokens to keep the order of the keys of the objects.
+// The nested values are flattened into the paths of the keys and the indexes(start by 1) like ["items", "1", "name"].
+
+// walkJSON reads a JSON value from the decoder, and calls f with the path and the value of each scalar.
+// The empty objects and arrays are not visited. Return the number of the members or the elements, or 0 for a scalar.
+func walkJSON(dec *json.Decoder, path []string, f func(path []string, v interface{})) (int, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return 0, err
+	}
+	return walkJSONValue(dec, tok, path, f)
+}
+
+// walkJSONValue walks the value which starts with the token.
+func walkJSONValue(dec *json.Decoder, tok json.Token, path []string, f func(path []string, v interface{})) (int, error) {
+	d, ok := tok.(json.Delim)
+	if !ok {
+		f(path, tok)
+		return 0, nil
+	}
+	n := 0
+	for ; dec.More(); n++ {
+		key := strconv.Itoa(n + 1)
+		if d == '{' {
+			k, err := dec.Token()
+			if err != nil {
+				return n, err
+			}
+			key = k.(string)
+		}
+		// the path of the parent must not be shared by the children
+		if _, err := walkJSON(dec, append(path[:len(path):len(path)], key), f); err != nil {
+			return n, err
+		}
+	}
+	// the closing delimiter
+	if _, err := dec.Token(); err != nil {
+		return n, err
+	}
+	return n, nil
+}
+
+// jsonNode returns the JSON scalar as the value of the language. true and false are 1 and 0, and null is "".
+func jsonNode(v interface{}) Node {
+	switch v := v.(type) {
+	case json.Number:
+		if f, err := v.Float64(); err == nil {
+			return NewNumberExpression(f)
+		}
+		return NewStringExpression(v.String())
+	case string:
+		return NewStringExpression(v)
+	case bool:
+		if v {
+			return NewNumberExpression(1)
+		}
+		return NewNumberExpression(0)
+	}
+	return NewStringExpression("")
+}
+
+// jsonCellValue returns the JSON scalar as the value of a cell, or nil for null.
+func jsonCellValue(v interface{}) interface{} {
+	if n, ok := v.(json.Number); ok {
+		if f, err := n.Float64(); err == nil {
+			return f
+		}
+		return n.String()
+	}
+	return v
+}
+
+// jsonRecord is the flattened object of JSON whose keys are the dotted names like "address.city".
+type jsonRecord struct {
+	names  []string
+	values map[string]interface{}
+}
+
+// readJSONRecords reads the records from an array of objects, or the objects like NDJSON.
+// A record which is not an object is the column "value", and the elements of an array are the columns "1", "2"...
+func readJSONRecords(r io.Reader) ([]*jsonRecord, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+
+	var records []*jsonRecord
+	readRecord := func(tok json.Token) error {
+		rec := &jsonRecord{values: map[string]interface{}{}}
+		_, err := walkJSONValue(dec, tok, nil, func(path []string, v interface{}) {
+			name := strings.Join(path, ".")
+			if name == "" {
+				name = "value"
+			}
+			if _, ok := rec.values[name]; !ok {
+				rec.names = append(rec.names, name)
+			}
+			rec.values[name] = v
+		})
+		records = append(records, rec)
+		return err
+	}
+
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if tok != json.Delim('[') {
+			if err := readRecord(tok); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		for dec.More() {
+			tok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			if err := readRecord(tok); err != nil {
+				return nil, err
+			}
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// importJSON reads JSON of the path("-" is the input) to the cells from the address.
+// The first row is the columns in order of appearance, or the columns specified, and the records are the rows under it.
+// Return the number of the records.
+func (s *Spreadsheet) importJSON(a *cellAddress, path string, columns []string) (int, error) {
+	var r *bufio.Reader
+	if path == "-" {
+		r = execContext.in
+	} else {
+		f, err := os.Open(path)
+		if err != nil {
+			return 0, err
+		}
+		defer f.Close()
+		r = bufio.NewReader(f)
+	}
+	skipBOM(r)
+	records, err := readJSONRecords(r)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(columns) == 0 {
+		seen := map[string]bool{}
+		for _, rec := range records {
+			for _, name := range rec.names {
+				if !seen[name] {
+					seen[name] = true
+					columns = append(columns, name)
+				}
+			}
+		}
+	}
+
+	col, row, err := excelize.CellNameToCoordinates(a.ref)
+	if err != nil {
+		return 0, err
+	}
+	for y := 0; y <= len(records); y++ {
+		for x, name := range columns {
+			axis, err := excelize.CoordinatesToCellName(col+x, row+y)
+			if err != nil {
+				return 0, err
+			}
+			if y == 0 {
+				s.setSheetCellValue(a.sheet, axis, name)
+				continue
+			}
+			if v := jsonCellValue(records[y-1].values[name]); v != nil {
+				s.setSheetCellValue(a.sheet, axis, v)
+			}
+		}
+	}
+	return len(records), nil
+}
+
+// parseJSON parses the JSON text into the array. The keys of the nested values are joined by SUBSEP.
+// A scalar is set to array[1]. Return the number of the members or the elements of the top level.
+func parseJSON(text string, arr *Array) (int, error) {
+	sep := execContext.scope.get("SUBSEP").asString()
+	dec := json.NewDecoder(strings.NewReader(text))
+	dec.UseNumber()
+
+	arr.clear()
+	scalar := false
+	n, err := walkJSON(dec, nil, func(path []string, v interface{}) {
+		if len(path) == 0 {
+			scalar = true
+			path = []string{"1"}
+		}
+		arr.set(strings.Join(path, sep), jsonNode(v))
+	})
+	if err == io.EOF {
+		return 0, fmt.Errorf("JSON is empty")
+	}
+	if err != nil {
+		return 0, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return 0, fmt.Errorf("JSON has the values after the first value")
+	}
+	if scalar {
+		return 1, nil
+	}
+	return n, nil
+}
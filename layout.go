@@ -0,0 +1,357 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/360EntSecGroup-Skylar/excelize/v2"
+)
+
+// DefaultColWidth is the default column width of Excel in characters.
+const DefaultColWidth = 8.43
+
+// parseColumns parses the columns like "B" or "B:D".
+func parseColumns(ref string) (string, string, error) {
+	a := strings.Split(strings.ToUpper(strings.TrimSpace(ref)), ":")
+	if len(a) == 1 {
+		a = append(a, a[0])
+	}
+	if len(a) != 2 {
+		return "", "", fmt.Errorf("'%s' is invalid columns", ref)
+	}
+	for _, c := range a {
+		if _, err := excelize.ColumnNameToNumber(c); err != nil {
+			return "", "", fmt.Errorf("'%s' is invalid columns", ref)
+		}
+	}
+	return a[0], a[1], nil
+}
+
+// parseRows parses the rows like "3" or "3:5".
+func parseRows(ref string) (int, int, error) {
+	a := strings.Split(strings.TrimSpace(ref), ":")
+	if len(a) == 1 {
+		a = append(a, a[0])
+	}
+	if len(a) != 2 {
+		return 0, 0, fmt.Errorf("'%s' is invalid rows", ref)
+	}
+	from, e1 := strconv.Atoi(a[0])
+	to, e2 := strconv.Atoi(a[1])
+	if e1 != nil || e2 != nil || from < 1 || to < 1 {
+		return 0, 0, fmt.Errorf("'%s' is invalid rows", ref)
+	}
+	if to < from {
+		from, to = to, from
+	}
+	return from, to, nil
+}
+
+func (s *Spreadsheet) mergeSheetCells(sheet string, r *Range) {
+	if err := s.file.MergeCell(sheet, r.axis(0, 0), r.axis(r.width()-1, r.height()-1)); err != nil {
+		fatalError("range '%s' merge failed", r.ref())
+	}
+	s.invalidateSheet(sheet)
+}
+
+// unmergeSheetCells unmerges the merged cells which overlap the range.
+func (s *Spreadsheet) unmergeSheetCells(sheet string, r *Range) {
+	if err := s.file.UnmergeCell(sheet, r.axis(0, 0), r.axis(r.width()-1, r.height()-1)); err != nil {
+		fatalError("range '%s' unmerge failed", r.ref())
+	}
+	s.invalidateSheet(sheet)
+}
+
+// getSheetMergedRanges returns the merged ranges like "A1:C1" of the sheet.
+func (s *Spreadsheet) getSheetMergedRanges(sheet string) []string {
+	cells, err := s.file.GetMergeCells(sheet)
+	if err != nil {
+		fatalError("sheet '%s' refer failed", sheet)
+	}
+	ranges := make([]string, len(cells))
+	for i, c := range cells {
+		ranges[i] = c.GetStartAxis() + ":" + c.GetEndAxis()
+	}
+	return ranges
+}
+
+func (s *Spreadsheet) getSheetColWidth(sheet string, col string) float64 {
+	w, err := s.file.GetColWidth(sheet, col)
+	if err != nil {
+		fatalError("column '%s' refer failed", col)
+	}
+	if w == 64 {
+		// excelize returns the default width in pixels
+		return DefaultColWidth
+	}
+	return w
+}
+
+func (s *Spreadsheet) setSheetColWidth(sheet string, from string, to string, width float64) {
+	s.checkStreamNotStarted(sheet, "column width")
+	if err := s.file.SetColWidth(sheet, from, to, width); err != nil {
+		fatalError("column '%s' set width failed (%v)", from, err)
+	}
+}
+
+func (s *Spreadsheet) getSheetRowHeight(sheet string, row int) float64 {
+	h, err := s.file.GetRowHeight(sheet, row)
+	if err != nil {
+		fatalError("row '%d' refer failed", row)
+	}
+	return h
+}
+
+func (s *Spreadsheet) setSheetRowHeight(sheet string, from int, to int, height float64) {
+	s.checkNotStreaming("row height")
+	for row := from; row <= to; row++ {
+		if err := s.file.SetRowHeight(sheet, row, height); err != nil {
+			fatalError("row '%d' set height failed (%v)", row, err)
+		}
+	}
+	s.invalidateSheet(sheet)
+}
+
+func (s *Spreadsheet) setSheetColVisible(sheet string, from string, to string, visible bool) {
+	s.checkStreamNotStarted(sheet, "column visibility")
+	if err := s.file.SetColVisible(sheet, from+":"+to, visible); err != nil {
+		fatalError("column '%s' set visible failed", from)
+	}
+}
+
+func (s *Spreadsheet) setSheetRowVisible(sheet string, from int, to int, visible bool) {
+	s.checkNotStreaming("hiding rows")
+	for row := from; row <= to; row++ {
+		if err := s.file.SetRowVisible(sheet, row, visible); err != nil {
+			fatalError("row '%d' set visible failed", row)
+		}
+	}
+	s.invalidateSheet(sheet)
+}
+
+// freezeSheetPanes freezes the rows above the cell and the columns left of the cell.
+// "A1" unfreezes the panes.
+func (s *Spreadsheet) freezeSheetPanes(sheet string, axis string) {
+	s.checkStreamNotStarted(sheet, "freezing panes")
+	col, row, err := excelize.CellNameToCoordinates(axis)
+	if err != nil {
+		fatalError("cell '%s' is invalid", axis)
+	}
+
+	panes := `{"freeze":false,"split":false}`
+	if 1 < col || 1 < row {
+		pane := "bottomRight"
+		if col == 1 {
+			pane = "bottomLeft"
+		} else if row == 1 {
+			pane = "topRight"
+		}
+		panes = fmt.Sprintf(`{"freeze":true,"split":false,"x_split":%d,"y_split":%d,"top_left_cell":"%s","active_pane":"%s","panes":[{"sqref":"%s","active_cell":"%s","pane":"%s"}]}`,
+			col-1, row-1, axis, pane, axis, axis, pane)
+	}
+	if err := s.file.SetPanes(sheet, panes); err != nil {
+		fatalError("sheet '%s' freeze failed", sheet)
+	}
+}
+
+// insertSheetRows inserts count rows before the row.
+// The formulas and the merged cells which refer to the moved rows are adjusted.
+func (s *Spreadsheet) insertSheetRows(sheet string, row int, count int) {
+	s.adjustSheetStructure(sheet, true, row, count, func() error {
+		return s.file.InsertRow(sheet, row)
+	})
+}
+
+// deleteSheetRows deletes count rows from the row.
+// The formulas and the merged cells which refer to the moved rows are adjusted.
+func (s *Spreadsheet) deleteSheetRows(sheet string, row int, count int) {
+	s.adjustSheetStructure(sheet, true, row, -count, func() error {
+		return s.file.RemoveRow(sheet, row)
+	})
+}
+
+// insertSheetCols inserts count columns before the column.
+// The formulas and the merged cells which refer to the moved columns are adjusted.
+func (s *Spreadsheet) insertSheetCols(sheet string, col int, count int) {
+	name, _ := excelize.ColumnNumberToName(col)
+	s.adjustSheetStructure(sheet, false, col, count, func() error {
+		return s.file.InsertCol(sheet, name)
+	})
+}
+
+// deleteSheetCols deletes count columns from the column.
+// The formulas and the merged cells which refer to the moved columns are adjusted.
+func (s *Spreadsheet) deleteSheetCols(sheet string, col int, count int) {
+	name, _ := excelize.ColumnNumberToName(col)
+	s.adjustSheetStructure(sheet, false, col, -count, func() error {
+		return s.file.RemoveCol(sheet, name)
+	})
+}
+
+// adjustSheetStructure calls op for every row or column to insert or delete,
+// and adjusts the formulas of the workbook and the merged cells, the tables, the data validations,
+// the conditional formats and the comments of the sheet.
+func (s *Spreadsheet) adjustSheetStructure(sheet string, rows bool, num int, offset int, op func() error) {
+	s.checkNotStreaming("inserting or deleting")
+	s.checkSheetTablesKept(sheet, rows, num, offset)
+	// excelize does not adjust the merged cells like Excel
+	merged := s.getSheetMergedRanges(sheet)
+	for _, ref := range merged {
+		s.unmergeSheetCells(sheet, s.getSheetRange(sheet, ref))
+	}
+
+	count := offset
+	if count < 0 {
+		count = -count
+	}
+	for i := 0; i < count; i++ {
+		if err := op(); err != nil {
+			fatalError("sheet '%s' insert or delete failed (%v)", sheet, err)
+		}
+	}
+	s.invalidateSheet(sheet)
+
+	s.adjustFormulas(func(formulaSheet string, formula string) string {
+		return adjustFormula(formula, formulaSheet, sheet, rows, num, offset)
+	})
+	s.adjustSheetTables(sheet, rows, num, offset)
+	s.adjustSheetValidations(sheet, rows, num, offset)
+	s.adjustSheetCondFormats(sheet, rows, num, offset)
+	s.moveSheetComments(sheet, func(col int, row int) (int, int, bool) {
+		if rows {
+			row, _, ok := adjustSpan(row, row, num, offset)
+			return col, row, ok
+		}
+		col, _, ok := adjustSpan(col, col, num, offset)
+		return col, row, ok
+	})
+	for _, ref := range merged {
+		if a := adjustFormula(ref, sheet, sheet, rows, num, offset); a != "#REF!" {
+			if r := s.getSheetRange(sheet, a); 1 < r.width() || 1 < r.height() {
+				s.mergeSheetCells(sheet, r)
+			}
+		}
+	}
+}
+
+// adjustSqref adjusts the ranges separated by the spaces like "A1:B2 D4" of the sheet.
+// The deleted ranges are removed, and "" is returned if all ranges are deleted.
+func adjustSqref(sqref string, sheet string, rows bool, num int, offset int) string {
+	var refs []string
+	for _, ref := range strings.Fields(sqref) {
+		if a := adjustFormula(ref, sheet, sheet, rows, num, offset); a != "#REF!" {
+			refs = append(refs, a)
+		}
+	}
+	return strings.Join(refs, " ")
+}
+
+// moveSheetRange moves the values, the formulas and the styles of the cells in src to the cell like cut and paste.
+// The formulas which refer to the cells in src are adjusted. It returns the moved range.
+func (s *Spreadsheet) moveSheetRange(src *Range, axis string) *Range {
+	col, row, err := excelize.CellNameToCoordinates(axis)
+	if err != nil {
+		fatalError("cell '%s' is invalid", axis)
+	}
+	dst := &Range{book: s, sheet: src.sheet, startCol: col, startRow: row,
+		endCol: col + src.width() - 1, endRow: row + src.height() - 1}
+	if excelize.TotalColumns < dst.endCol || excelize.TotalRows < dst.endRow {
+		fatalError("range from '%s' is out of the sheet", axis)
+	}
+
+	var merged []*Range
+	for _, ref := range s.getSheetMergedRanges(src.sheet) {
+		r := s.getSheetRange(src.sheet, ref)
+		if src.startCol <= r.startCol && r.endCol <= src.endCol && src.startRow <= r.startRow && r.endRow <= src.endRow {
+			merged = append(merged, r)
+		}
+	}
+	// excelize returns the value of the top left cell for the merged cells
+	s.unmergeSheetCells(src.sheet, src)
+	s.unmergeSheetCells(dst.sheet, dst)
+
+	type cell struct {
+		value   string
+		formula string
+		style   int
+	}
+	cells := make([]cell, 0, src.width()*src.height())
+	for _, axis := range src.axes() {
+		cells = append(cells, cell{
+			value:   s.getSheetCellRawValue(src.sheet, axis),
+			formula: s.getSheetCellFormula(src.sheet, axis),
+			style:   s.getSheetCellStyle(src.sheet, axis),
+		})
+	}
+
+	for _, axis := range src.axes() {
+		s.clearSheetCell(src.sheet, axis)
+	}
+	for i, axis := range dst.axes() {
+		c := cells[i]
+		s.clearSheetCell(dst.sheet, axis)
+		if c.formula != "" {
+			s.setSheetCellFormula(dst.sheet, axis, c.formula)
+		}
+		if c.value != "" {
+			s.setSheetCellRawValue(dst.sheet, axis, c.value)
+		}
+		s.setSheetCellStyle(dst.sheet, axis, c.style)
+	}
+
+	s.adjustFormulas(func(formulaSheet string, formula string) string {
+		return moveFormula(formula, formulaSheet, src, dst)
+	})
+	s.moveSheetTables(src, dst)
+	// the comments in dst are overwritten like Excel
+	in := func(r *Range, col int, row int) bool {
+		return r.startCol <= col && col <= r.endCol && r.startRow <= row && row <= r.endRow
+	}
+	s.moveSheetComments(src.sheet, func(col int, row int) (int, int, bool) {
+		if in(src, col, row) {
+			return col + dst.startCol - src.startCol, row + dst.startRow - src.startRow, true
+		}
+		return col, row, !in(dst, col, row)
+	})
+	for _, r := range merged {
+		r.startCol += dst.startCol - src.startCol
+		r.endCol += dst.startCol - src.startCol
+		r.startRow += dst.startRow - src.startRow
+		r.endRow += dst.startRow - src.startRow
+		s.mergeSheetCells(dst.sheet, r)
+	}
+	return dst
+}
+
+// clearSheetCell removes the value, the formula and the style of the cell.
+func (s *Spreadsheet) clearSheetCell(sheet string, axis string) {
+	s.setSheetCellFormula(sheet, axis, "")
+	if s.isStreaming() {
+		*s.streamCell(sheet, axis) = excelize.Cell{}
+		return
+	}
+	if err := s.file.SetCellDefault(sheet, axis, ""); err != nil {
+		fatalError("cell '%s' set value failed", axis)
+	}
+	s.touchSheetCell(sheet, axis)
+	s.setSheetCellStyle(sheet, axis, 0)
+}
+
+// setSheetCellRawValue sets the value returned by getSheetCellRawValue.
+// The value is set as a number if it is written as a number, so "001" is kept as a string.
+func (s *Spreadsheet) setSheetCellRawValue(sheet string, axis string, v string) {
+	if f, ok := maybeNumber(v); ok && !math.IsNaN(f) && (strconv.FormatFloat(f, 'f', -1, 64) == v || strings.ContainsAny(v, "Ee")) {
+		if s.isStreaming() {
+			s.streamCell(sheet, axis).Value = f
+			return
+		}
+		if err := s.file.SetCellDefault(sheet, axis, v); err != nil {
+			fatalError("cell '%s' set value failed", axis)
+		}
+		s.touchSheetCell(sheet, axis)
+		return
+	}
+	s.setSheetCellValue(sheet, axis, v)
+}
@@ -10,7 +10,7 @@ import (
 type Lexer struct {
 	src     []rune
 	current int
-	ast     Node
+	ast     *Program
 }
 
 func NewLexer(code string) *Lexer {
@@ -308,6 +308,30 @@ func (l *Lexer) word(lval *yySymType) int {
 		return RETURN
 	}
 
+	if s == "in" {
+		return IN
+	}
+
+	if s == "delete" {
+		return DELETE
+	}
+
+	if s == "BEGIN" {
+		return BEGIN
+	}
+
+	if s == "END" {
+		return END
+	}
+
+	if s == "BEGINFILE" {
+		return BEGINFILE
+	}
+
+	if s == "ENDFILE" {
+		return ENDFILE
+	}
+
 	lval.ident = s
 	return IDENT
 }
@@ -9,6 +9,8 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 )
 
 const CELL_VERSION = "0.1.0"
@@ -17,7 +19,11 @@ type ExecContext struct {
 	code           string
 	topath         string
 	frompath       string
+	frompaths      []string
+	fromBooks      []namedBook
 	spreadsheet    *Spreadsheet
+	books          map[string]*Spreadsheet
+	bookName       string
 	exitCode       int
 	scope          *Scope
 	ndollars       uint16
@@ -32,7 +38,15 @@ type ExecContext struct {
 	errout         io.Writer
 	doTextRowLoop  bool
 	doExcelRowLoop bool
+	stream         bool
+	csvInput       bool
+	csvQuote       string
+	inputEncoding  string
+	dump           string
+	dumpHeader     bool
 	initSheet      string
+	convfmt        string
+	ofmt           string
 }
 
 var execContext *ExecContext
@@ -50,6 +64,11 @@ func NewExecContext() *ExecContext {
 	con.scope.set("ORS", NewStringExpression("\n"))
 	con.scope.set("NR", NewNumberExpression(0))
 	con.scope.set("SER", NewNumberExpression(1))
+	con.scope.set("SUBSEP", NewStringExpression("\034"))
+	con.convfmt = DefaultNumberFormat
+	con.ofmt = DefaultNumberFormat
+	con.scope.setVar("CONVFMT", NewStringExpression(con.convfmt))
+	con.scope.setVar("OFMT", NewStringExpression(con.ofmt))
 
 	return con
 }
@@ -60,18 +79,25 @@ func main() {
 	con := NewExecContext()
 
 	var pgpath string
+	var froms fromFlags
 	var showVer bool
 	var fs string
 	var ser int
 	flag.StringVar(&con.topath, "to", "", "output xlsx filepath")
-	flag.StringVar(&con.frompath, "from", "", "input xlsx filepath")
+	flag.Var(&froms, "from", "input xlsx filepath(name=path for a named workbook)")
 	flag.StringVar(&pgpath, "f", "", "program filepath")
 	flag.StringVar(&fs, "F", "", "specify field separator")
 	flag.BoolVar(&showVer, "V", false, "show version")
-	flag.BoolVar(&con.doTextRowLoop, "n", false, "wrap your script inside while(gets()){... ;} loop")
-	flag.BoolVar(&con.doExcelRowLoop, "N", false, "wrap your script inside for(NER = SER; NER <= LR; NER++){... ;} loop")
+	flag.BoolVar(&con.doTextRowLoop, "n", false, "run the main rules for each line of the input like while(gets()){... ;} loop")
+	flag.BoolVar(&con.doExcelRowLoop, "N", false, "run the main rules for each Excel row like for(NER = SER; NER <= LR; NER++){... ;} loop")
 	flag.IntVar(&ser, "s", 1, "specify special var SER(start excel row)")
 	flag.StringVar(&con.initSheet, "S", "", "specify active sheet by name")
+	flag.BoolVar(&con.stream, "stream", false, "write the rows of the output workbook in ascending order by streaming")
+	flag.BoolVar(&con.csvInput, "csv", false, "read the input as CSV whose delimiter is FS(default ',')")
+	flag.StringVar(&con.csvQuote, "Q", "", "specify the quote character of CSV input(default '\"')")
+	flag.StringVar(&con.inputEncoding, "E", "", "specify the encoding of the input like shift_jis(default utf-8)")
+	flag.StringVar(&con.dump, "dump", "", "write the active sheet as json, ndjson, md or html")
+	flag.BoolVar(&con.dumpHeader, "header", false, "use the first row as the header of -dump")
 
 	flag.Parse()
 
@@ -80,8 +106,18 @@ func main() {
 		showVersion()
 	}
 
+	// -from option
+	for _, v := range froms {
+		name, path, ok := splitNamedPath(v)
+		if ok && name != MainBookName {
+			con.fromBooks = append(con.fromBooks, namedBook{name: name, path: path})
+			continue
+		}
+		con.frompaths = append(con.frompaths, expandGlob(path)...)
+	}
+
 	args := flag.Args()
-	if len(args) < 1 && pgpath == "" {
+	if len(args) < 1 && pgpath == "" && con.dump == "" {
 		flag.Usage()
 		os.Exit(1)
 	}
@@ -89,7 +125,7 @@ func main() {
 	// -f option
 	if pgpath != "" {
 		con.code = readProg(pgpath)
-	} else {
+	} else if 0 < len(args) {
 		con.code = args[0]
 	}
 
@@ -116,6 +152,28 @@ func main() {
 	os.Exit(con.exitCode)
 }
 
+// fromFlags is the list of -from option values
+type fromFlags []string
+
+func (f *fromFlags) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *fromFlags) Set(v string) error {
+	*f = append(*f, v)
+	return nil
+}
+
+// expandGlob returns the file paths matched by the pattern.
+// If nothing matches, the pattern itself is returned.
+func expandGlob(pattern string) []string {
+	matches, err := filepath.Glob(pattern)
+	if err != nil || len(matches) == 0 {
+		return []string{pattern}
+	}
+	return matches
+}
+
 func switchStdin(con *ExecContext, files []string) {
 	rary := make([]io.Reader, len(files))
 
@@ -146,18 +204,35 @@ Options:
       Specify the path of the processed Excel file that will be saved
   -from input-xlsx-file-path
       Specify the Excel file to be processed. No overwriting will be done. The default is an empty book containing only Sheet1.
+      It can be specified more than once, and accepts a glob like '*.xlsx'. The workbooks are processed in turn.
+  -from name=input-xlsx-file-path
+      Open an additional Excel file as the named workbook. It can be specified more than once.
   -f program-file
       Read the Cell program source from the file program-file, instead of from the first command line argument.
   -F fs
       Use fs for the input field separator (the value of the FS predefined variable).
   -n
-      Wrap your script inside while(gets()){... ;} loop
+      Run the main rules for each line of the input, like while(gets()){... ;} loop
   -N
-      Wrap your script inside for(NER = SER; NER <= LR; NER++){... ;} loop (NER and SER, LR are predefined variables)
+      Run the main rules for each Excel row, like for(NER = SER; NER <= LR; NER++){... ;} loop (NER and SER, LR are predefined variables)
   -s row-no
       Specify the special variable SER(Start Excel Row) (default 1)
   -S
       Specify default active sheet by name
+  -csv
+      Read the input as CSV(RFC 4180) records. The delimiter is FS(default ','), and the quoted fields can contain the delimiters and the newlines.
+  -Q quote
+      Specify the quote character of CSV input (default '"'). 'none' disables the quotes.
+  -E encoding
+      Specify the encoding of the input like shift_jis, euc-jp or utf-16le (default utf-8).
+  -dump format
+      Write the active sheet as json, ndjson, md(Markdown table) or html(HTML table) after the main rules for each workbook.
+      The program can be omitted like 'cell -from sales.xlsx -dump json -S Sales'.
+  -header
+      Use the first row as the header of -dump. The rows of json and ndjson are the objects keyed by the header.
+  -stream
+      Write the rows of the new output workbook in ascending order by streaming, instead of holding the whole workbook in memory.
+      The rows before the current row can not be read or written any more. It can not be used with -from.
   -V
       Print version information.
   -h
@@ -188,36 +263,60 @@ func fileExist(filename string) bool {
 	return err == nil
 }
 
-func beforeRun() {
-	// setup spreadsheet
-	sheet, err := NewSpreadsheet(execContext.frompath, execContext.topath)
-	if err != nil {
-		fatalError("on error occured loading xlsx file")
+// inputFiles returns the workbooks which are processed in turn as the main workbook.
+func (con *ExecContext) inputFiles() []string {
+	if len(con.frompaths) == 0 {
+		return []string{con.frompath}
 	}
-	execContext.spreadsheet = sheet
+	return con.frompaths
+}
 
-	// -S option
-	if execContext.initSheet != "" {
-		execContext.scope.set("@", NewStringExpression(execContext.initSheet))
+func beforeRun() {
+	if 1 < len(execContext.inputFiles()) && execContext.topath != "" {
+		fatalError("-to can not be used with multiple -from workbooks")
+	}
+	if execContext.stream {
+		if execContext.topath == "" {
+			fatalError("-stream needs -to")
+		}
+		if execContext.frompath != "" || 0 < len(execContext.frompaths) {
+			fatalError("-stream can not be used with -from")
+		}
+	}
+	if execContext.dump != "" {
+		if _, ok := dumpFormats[strings.ToLower(execContext.dump)]; !ok {
+			fatalError("-dump format '%s' is invalid", execContext.dump)
+		}
+		if execContext.stream {
+			fatalError("-dump can not be used with -stream")
+		}
 	}
+	execContext.books = map[string]*Spreadsheet{}
 
-	// -N option
-	if execContext.doExcelRowLoop {
-		execContext.code = "for(NER = SER; NER <= LR; NER++){ " + execContext.code + "; }"
+	// -E option
+	if execContext.inputEncoding != "" {
+		e, err := textEncoding(execContext.inputEncoding)
+		if err != nil {
+			fatalError("%v", err)
+		}
+		execContext.in = bufio.NewReader(decodeReader(execContext.in, e))
+	}
+	// -csv option
+	if execContext.csvInput && execContext.scope.get("FS").asString() == " " {
+		execContext.scope.set("FS", NewStringExpression(","))
 	}
 
-	// -n option
-	if execContext.doTextRowLoop {
-		execContext.code = "while(gets()){ " + execContext.code + "; }"
+	// -from name=path option
+	for _, b := range execContext.fromBooks {
+		if err := openBook(b.name, b.path); err != nil {
+			fatalError("on error occured loading xlsx file. %v", err)
+		}
 	}
 }
 
 func afterRun() {
-	// request full calculate to excel
-	execContext.spreadsheet.file.WorkBook.CalcPr.FullCalcOnLoad = true
-
 	if execContext.topath != "" {
-		if err := execContext.spreadsheet.writeSpreadsheet(); err != nil {
+		if err := execContext.books[MainBookName].writeSpreadsheet(); err != nil {
 			fatalError("on error occured writting xlsx file")
 		}
 	}
@@ -238,16 +337,31 @@ func execScript() int {
 	lexer := NewLexer(execContext.code)
 	yyParse(lexer)
 
-	lexer.ast.eval()
+	lexer.ast.run()
 
-	if execContext.doBreak {
-		fatalError("'break' is not allowed outside a loop")
+	return 0
+}
+
+// loadFile loads the workbook as the main workbook.
+func loadFile(path string, num int) {
+	sheet, err := NewSpreadsheet(path, execContext.topath)
+	if err != nil {
+		fatalError("on error occured loading xlsx file")
 	}
-	if execContext.doContinue {
-		fatalError("'continue' is not allowed outside a loop")
+	if execContext.stream {
+		sheet.startStream()
 	}
+	execContext.books[MainBookName] = sheet
+	switchBook(MainBookName)
 
-	return 0
+	execContext.scope.set("FILENAME", NewStringExpression(path))
+	execContext.scope.set("FILENUM", NewNumberExpression(float64(num)))
+	execContext.scope.set("FNR", NewNumberExpression(0))
+
+	// -S option
+	if execContext.initSheet != "" {
+		execContext.scope.set("@", NewStringExpression(execContext.initSheet))
+	}
 }
 
 func fatalError(format string, a ...interface{}) {
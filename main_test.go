@@ -3,6 +3,8 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"os"
+	"strings"
 	"testing"
 
 	"github.com/360EntSecGroup-Skylar/excelize/v2"
@@ -1594,6 +1596,20 @@ func TestRecursiveFunction(t *testing.T) {
 	}
 }
 
+func TestCallFunctionBeforeDefinition(t *testing.T) {
+	con := NewExecContext()
+	out := new(bytes.Buffer)
+	con.out = out
+	con.code = `BEGIN { puts(f(2)); } function f(x) { return x * 2; }`
+	run(con)
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+	if out.String() != "4\n" {
+		t.Fatalf("want stdout '4\n', but got '%s'", out)
+	}
+}
+
 func TestEvalStringAsNumber(t *testing.T) {
 	con := NewExecContext()
 	con.topath = "TestEvalStringAsNumber.xlsx"
@@ -2348,6 +2364,108 @@ func TestOptionExcelRowLoop(t *testing.T) {
 	}
 }
 
+func TestOptionExcelRowLoopWithWrite(t *testing.T) {
+	out := new(bytes.Buffer)
+
+	con := NewExecContext()
+	con.out = out
+	con.frompath = "test/list.xlsx"
+	con.doExcelRowLoop = true
+
+	// the written cells of the current row and the appended rows are read in the loop
+	con.code = `["B".NER]=["A".NER] . NER;if(NER==7){["A8"]="H";};puts(["B".NER], LR)`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	want := "A1 7\nB2 7\nC3 7\nD4 7\nE5 7\nF6 7\nG7 8\nH8 8\n"
+	if out.String() != want {
+		t.Fatalf("want stdout '%s', but got '%s'", want, out)
+	}
+}
+
+func TestOptionCSV(t *testing.T) {
+	in := bufio.NewReader(bytes.NewBufferString("\uFEFFname,note\r\n\"Smith, J\",\"said \"\"hi\"\"\nthen left\"\r\nplain,\n"))
+	out := new(bytes.Buffer)
+
+	con := NewExecContext()
+	con.in = in
+	con.out = out
+	con.doTextRowLoop = true
+	con.csvInput = true
+
+	con.code = `puts(NR, NF, $1 . "|" . $2)`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	want := "1 2 name|note\n2 2 Smith, J|said \"hi\"\nthen left\n3 2 plain|\n"
+	if out.String() != want {
+		t.Fatalf("want stdout '%s', but got '%s'", want, out)
+	}
+}
+
+func TestOptionDump(t *testing.T) {
+	out := new(bytes.Buffer)
+
+	con := NewExecContext()
+	con.out = out
+	con.dump = "ndjson"
+	con.dumpHeader = true
+	con.code = `["A1"]="name";["B1"]="qty";["A2"]="apple";["B2"]=3;["A3"]="orange"`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	want := "{\"name\":\"apple\",\"qty\":3}\n{\"name\":\"orange\",\"qty\":\"\"}\n"
+	if out.String() != want {
+		t.Fatalf("want stdout '%s', but got '%s'", want, out)
+	}
+}
+
+func TestOptionStream(t *testing.T) {
+	out := new(bytes.Buffer)
+
+	con := NewExecContext()
+	con.out = out
+	con.topath = "TestOptionStream.xlsx"
+	con.stream = true
+	con.code = `colwidth("A", 20);for(i=1;i<=3;i++){["A" . i]=i*10;["B" . i]=["A" . i] . "!";style("B" . i,"bold");};puts(["B3"], LR, LC)`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	want := "30! 3 2\n"
+	if out.String() != want {
+		t.Fatalf("want stdout '%s', but got '%s'", want, out)
+	}
+
+	// read the streamed rows from the file
+	out.Reset()
+	con = NewExecContext()
+	con.out = out
+	con.frompath = "TestOptionStream.xlsx"
+	con.code = `puts(["A1"], ["B2"], colwidth("A"), LR)`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	want = "10 20! 20 3\n"
+	if out.String() != want {
+		t.Fatalf("want stdout '%s', but got '%s'", want, out)
+	}
+}
+
 func TestSingleQuoteString(t *testing.T) {
 	out := new(bytes.Buffer)
 
@@ -2383,3 +2501,1404 @@ func TestEscapeQuoteString(t *testing.T) {
 		t.Fatalf("want stdout '\"\"'\n', but got '%s'", out)
 	}
 }
+
+func TestArrayAssignAndRefer(t *testing.T) {
+	out := new(bytes.Buffer)
+
+	con := NewExecContext()
+	con.out = out
+
+	con.code = `a["x"] = 1;a["y"] = "str";a[1] = 10;puts(a["x"], a["y"], a[1], a["none"]);`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	if out.String() != "1 str 10 \n" {
+		t.Fatalf("want stdout '1 str 10 \n', but got '%s'", out)
+	}
+}
+
+func TestArrayCompoundAssign(t *testing.T) {
+	out := new(bytes.Buffer)
+
+	con := NewExecContext()
+	con.out = out
+
+	con.code = `a["n"] += 5;a["n"] -= 1;a["n"] *= 3;a["n"] /= 2;a["n"] **= 2;a["m"] = 7;a["m"] %= 4;a["s"] .= "ab";a["s"] .= "c";a["n"]++;++a["m"];a["c"] = "A";a["c"]++;puts(a["n"], a["m"], a["s"], a["c"]);`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	if out.String() != "37 4 abc B\n" {
+		t.Fatalf("want stdout '37 4 abc B\n', but got '%s'", out)
+	}
+}
+
+func TestArrayMultiSubscript(t *testing.T) {
+	out := new(bytes.Buffer)
+
+	con := NewExecContext()
+	con.out = out
+
+	con.code = `a[1, 2] = "x";SUBSEP = ":";a[3, 4] = "y";for (k in a) puts(k);`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	if out.String() != "1\0342\n3:4\n" {
+		t.Fatalf("want stdout '1\\0342\n3:4\n', but got '%s'", out)
+	}
+}
+
+func TestArrayInExpression(t *testing.T) {
+	out := new(bytes.Buffer)
+
+	con := NewExecContext()
+	con.out = out
+
+	con.code = `a["x"] = 1;a[1, 2] = 1;puts(("x" in a), ("y" in a), ((1, 2) in a), ((2, 1) in a), ("x" in b));`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	if out.String() != "1 0 1 0 0\n" {
+		t.Fatalf("want stdout '1 0 1 0 0\n', but got '%s'", out)
+	}
+}
+
+func TestDeleteArrayStatement(t *testing.T) {
+	out := new(bytes.Buffer)
+
+	con := NewExecContext()
+	con.out = out
+
+	con.code = `a["x"] = 1;a["y"] = 2;a["z"] = 3;delete a["y"];puts(length(a), ("y" in a));delete a;puts(length(a));`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	if out.String() != "2 0\n0\n" {
+		t.Fatalf("want stdout '2 0\n0\n', but got '%s'", out)
+	}
+}
+
+func TestDeleteArrayKeepsOrder(t *testing.T) {
+	out := new(bytes.Buffer)
+
+	con := NewExecContext()
+	con.out = out
+
+	con.code = `for(i=1;i<=100000;i++) a[i]=i;for(i=1;i<=100000;i++) if(i%25000!=0) delete a[i];a[3]=3;a[25000]="x";s="";for(k in a) s=s . k . ":" . a[k] . " ";puts(length(a), s);`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	want := "5 25000:x 50000:50000 75000:75000 100000:100000 3:3 \n"
+	if out.String() != want {
+		t.Fatalf("want stdout '%s', but got '%s'", want, out)
+	}
+}
+
+func TestForInStatement(t *testing.T) {
+	out := new(bytes.Buffer)
+
+	con := NewExecContext()
+	con.out = out
+
+	con.code = `a["x"] = 1;a["y"] = 2;a["z"] = 3;a["w"] = 4;for (k in a) { if (k eq "y") continue; if (k eq "w") break; puts(k . "=" . a[k]); }`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	if out.String() != "x=1\nz=3\n" {
+		t.Fatalf("want stdout 'x=1\nz=3\n', but got '%s'", out)
+	}
+}
+
+func TestLengthFunc(t *testing.T) {
+	in := bufio.NewReader(bytes.NewBufferString("あいう えお"))
+	out := new(bytes.Buffer)
+
+	con := NewExecContext()
+	con.in = in
+	con.out = out
+
+	con.code = `a[1] = 1;a[2] = 2;puts(length(a), length("abc"), length("日本語"), length(b));gets();puts(length());`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	if out.String() != "2 3 3 0\n6\n" {
+		t.Fatalf("want stdout '2 3 3 0\n6\n', but got '%s'", out)
+	}
+}
+
+func TestArrayPassedByReference(t *testing.T) {
+	out := new(bytes.Buffer)
+
+	con := NewExecContext()
+	con.out = out
+
+	con.code = `function fill(arr, n) { for (i = 1; i <= n; i++) arr[i] = i * 10; } function total(arr) { s = 0; for (k in arr) s += arr[k]; return s; } a["x"] = 1;fill(a, 2);fill(b, 3);puts(length(a), length(b), total(b));`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	if out.String() != "3 3 60\n" {
+		t.Fatalf("want stdout '3 3 60\n', but got '%s'", out)
+	}
+}
+
+func TestFunctionArgsEvaluatedInCallerScope(t *testing.T) {
+	out := new(bytes.Buffer)
+
+	con := NewExecContext()
+	con.out = out
+
+	con.code = `function f(x, y) { return x . y; } x = "a";y = "b";puts(f(y, x));`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	if out.String() != "ba\n" {
+		t.Fatalf("want stdout 'ba\n', but got '%s'", out)
+	}
+}
+
+func TestRangeAssignFromScalar(t *testing.T) {
+	con := NewExecContext()
+	con.topath = "TestRangeAssignFromScalar.xlsx"
+	con.code = `["A1:B2"] = 7;`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	for _, axis := range []string{"A1", "B1", "A2", "B2"} {
+		v := getCellValue(t, con.topath, "Sheet1", axis)
+		if v != "7" {
+			t.Fatalf("want cell %s value '7', but got %s", axis, v)
+		}
+	}
+	v := getCellValue(t, con.topath, "Sheet1", "C3")
+	if v != "" {
+		t.Fatalf("want cell C3 value '', but got %s", v)
+	}
+}
+
+func TestRangeAssignFromRange(t *testing.T) {
+	con := NewExecContext()
+	con.topath = "TestRangeAssignFromRange.xlsx"
+	con.code = `["A1"] = 1;["B1"] = "x";["A2"] = 3;["B2"] = 4;["D1"] = ["A1:B2"];["A5:B6"] = ["A1:B2"];`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	want := map[string]string{"D1": "1", "E1": "x", "D2": "3", "E2": "4", "A5": "1", "B5": "x", "A6": "3", "B6": "4"}
+	for axis, w := range want {
+		v := getCellValue(t, con.topath, "Sheet1", axis)
+		if v != w {
+			t.Fatalf("want cell %s value '%s', but got %s", axis, w, v)
+		}
+	}
+}
+
+func TestForInRangeStatement(t *testing.T) {
+	out := new(bytes.Buffer)
+
+	con := NewExecContext()
+	con.out = out
+	con.frompath = "test/list.xlsx"
+
+	con.code = `for (c in ["A2:A3"]) puts(c, [c]);for (c in ["A:A"]) n++;puts(n, ("A4" in ["A1:A7"]), ("B1" in ["A1:A7"]));`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	if out.String() != "A2 B\nA3 C\n7 1 0\n" {
+		t.Fatalf("want stdout 'A2 B\nA3 C\n7 1 0\n', but got '%s'", out)
+	}
+}
+
+func TestCrossSheetCellAddress(t *testing.T) {
+	out := new(bytes.Buffer)
+
+	con := NewExecContext()
+	con.out = out
+	con.topath = "TestCrossSheetCellAddress.xlsx"
+	con.code = `@="My Sheet";@="Sheet1";["My Sheet!A1"]=1;["'My Sheet'!B1"]="x";["'My Sheet'!A1"]+=2;["Sheet1!A2:B2"]=["'My Sheet'!A1:B1"];for (c in ["'My Sheet'!A1:B1"]) puts(c, [c]);puts(@, ("'My Sheet'!B1" in ["'My Sheet'!A1:B1"]), ("B1" in ["'My Sheet'!A1:B1"]));`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	if out.String() != "'My Sheet'!A1 3\n'My Sheet'!B1 x\nSheet1 1 0\n" {
+		t.Fatalf("want stdout ''My Sheet'!A1 3\n'My Sheet'!B1 x\nSheet1 1 0\n', but got '%s'", out)
+	}
+
+	want := map[string]string{"A2": "3", "B2": "x"}
+	for axis, w := range want {
+		v := getCellValue(t, con.topath, "Sheet1", axis)
+		if v != w {
+			t.Fatalf("want cell %s value '%s', but got %s", axis, w, v)
+		}
+	}
+}
+
+func TestWorkbookCellAddress(t *testing.T) {
+	out := new(bytes.Buffer)
+
+	con := NewExecContext()
+	con.out = out
+	con.frompath = "test/list.xlsx"
+	con.code = `puts(["main:A2"], ["main:Sheet1!A3"], sum(["main:Sheet1!A1:A2"]));`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	if out.String() != "B C 0\n" {
+		t.Fatalf("want stdout 'B C 0\n', but got '%s'", out)
+	}
+}
+
+func TestNamedWorkbook(t *testing.T) {
+	out := new(bytes.Buffer)
+
+	con := NewExecContext()
+	con.out = out
+	con.topath = "TestNamedWorkbook.xlsx"
+	con.fromBooks = []namedBook{{name: "list", path: "test/list.xlsx"}}
+	con.code = `puts(@@);["A1"]=["list:A2"];@@="list";puts(@@, ["A3"], ["main:A1"]);["A1"]="changed";`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	if out.String() != "main\nlist C B\n" {
+		t.Fatalf("want stdout 'main\nlist C B\n', but got '%s'", out)
+	}
+
+	v := getCellValue(t, con.topath, "Sheet1", "A1")
+	if v != "B" {
+		t.Fatalf("want cell A1 value '%s', but got %s", "B", v)
+	}
+}
+
+func TestColumnLikeWorkbookName(t *testing.T) {
+	out := new(bytes.Buffer)
+
+	con := NewExecContext()
+	con.out = out
+	con.fromBooks = []namedBook{{name: "B", path: "test/list.xlsx"}, {name: "A", path: "test/list.xlsx"}}
+	con.code = `["A1"]=1;["B1"]=3;["B2"]=4;puts(sum(["B:B"]), sum(["A:B"]), ["B:A2"], ["A:A3"]);`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	want := "7 8 B C\n"
+	if out.String() != want {
+		t.Fatalf("want stdout '%s', but got '%s'", want, out)
+	}
+}
+
+func TestOpenSaveCloseFunc(t *testing.T) {
+	out := new(bytes.Buffer)
+
+	con := NewExecContext()
+	con.out = out
+	con.code = `puts(open("list", "test/list.xlsx"), open("new"));["new:A1"]=["list:A2"];puts(save("new", "TestOpenSaveCloseFunc.xlsx"));@@="new";close("new");puts(@@);`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	if out.String() != "list new\nTestOpenSaveCloseFunc.xlsx\nmain\n" {
+		t.Fatalf("want stdout 'list new\nTestOpenSaveCloseFunc.xlsx\nmain\n', but got '%s'", out)
+	}
+
+	v := getCellValue(t, "TestOpenSaveCloseFunc.xlsx", "Sheet1", "A1")
+	if v != "B" {
+		t.Fatalf("want cell A1 value '%s', but got %s", "B", v)
+	}
+}
+
+func TestMultipleInputWorkbooks(t *testing.T) {
+	out := new(bytes.Buffer)
+
+	con := NewExecContext()
+	con.out = out
+	con.frompaths = []string{"test/list.xlsx", "test/sheet.xlsx"}
+	con.doExcelRowLoop = true
+
+	// the functions named like the blocks are not called
+	con.code = `BEGINFILE { puts("begin", FILENUM, FILENAME) ; } ENDFILE { puts("end", FNR, n) ; } function endfile() { puts("called") ; } n++`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	want := "begin 1 test/list.xlsx\nend 7 7\nbegin 2 test/sheet.xlsx\nend 1 8\n"
+	if out.String() != want {
+		t.Fatalf("want stdout '%s', but got '%s'", want, out)
+	}
+}
+
+func TestMultipleInputWorkbooksExit(t *testing.T) {
+	out := new(bytes.Buffer)
+
+	con := NewExecContext()
+	con.out = out
+	con.frompaths = []string{"test/list.xlsx", "test/list.xlsx"}
+
+	con.code = `puts(FILENUM);exit(0)`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	if out.String() != "1\n" {
+		t.Fatalf("want stdout '1\n', but got '%s'", out)
+	}
+}
+
+func TestBeginEndRules(t *testing.T) {
+	in := bufio.NewReader(bytes.NewBufferString("1 a\n\n3 c\n4 d"))
+	out := new(bytes.Buffer)
+
+	con := NewExecContext()
+	con.in = in
+	con.out = out
+	con.doTextRowLoop = true
+
+	con.code = `BEGIN { puts("start") ; } $1 > 2 { s += $1 ; } { n++ ; } END { puts(n, s, NR) ; }`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	if out.String() != "start\n4 7 4\n" {
+		t.Fatalf("want stdout 'start\n4 7 4\n', but got '%s'", out)
+	}
+}
+
+func TestPatternActionRulesWithExcelRowLoop(t *testing.T) {
+	out := new(bytes.Buffer)
+
+	con := NewExecContext()
+	con.out = out
+	con.frompath = "test/list.xlsx"
+	con.doExcelRowLoop = true
+
+	con.code = `BEGIN { puts(LR) ; } ["A".NER] eq "C" { exit(3) ; } { puts(["A".NER]) ; } END { puts("end", FNR) ; }`
+	run(con)
+
+	if con.exitCode != 3 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 3, con.exitCode)
+	}
+
+	if out.String() != "7\nA\nB\nend 3\n" {
+		t.Fatalf("want stdout '7\nA\nB\nend 3\n', but got '%s'", out)
+	}
+}
+
+func TestBreakInExcelRowLoop(t *testing.T) {
+	out := new(bytes.Buffer)
+
+	con := NewExecContext()
+	con.out = out
+	con.frompath = "test/list.xlsx"
+	con.doExcelRowLoop = true
+
+	con.code = `if (NER > 2) break;if (NER == 1) continue;puts(NER)`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	if out.String() != "2\n" {
+		t.Fatalf("want stdout '2\n', but got '%s'", out)
+	}
+}
+
+func TestSubstrIndexFunc(t *testing.T) {
+	out := new(bytes.Buffer)
+
+	con := NewExecContext()
+	con.out = out
+	con.code = `puts(substr("こんにちは世界", 3, 2), substr("hello", 0), substr("hello", 2, 100), substr("hello", 4, -1), index("日本語テキスト", "テキ"), index("abc", "z"))`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	if out.String() != "にち hello ello  4 0\n" {
+		t.Fatalf("want stdout 'にち hello ello  4 0\n', but got '%s'", out)
+	}
+}
+
+func TestSplitFunc(t *testing.T) {
+	out := new(bytes.Buffer)
+
+	con := NewExecContext()
+	con.out = out
+	con.code = `n = split("a,b,,c", parts, ",");puts(n, parts[1], parts[4], length(parts));n = split("1 2 3", "B2");puts(n, ["B2"] + ["D2"]);split("x y", ["A5:A5"]);puts(["B5"]);split("p q", ["C7"]);puts(["D7"]);`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	if out.String() != "4 a c 4\n3 4\ny\nq\n" {
+		t.Fatalf("want stdout '4 a c 4\n3 4\ny\nq\n', but got '%s'", out)
+	}
+}
+
+func TestSubGsubFunc(t *testing.T) {
+	out := new(bytes.Buffer)
+
+	con := NewExecContext()
+	con.out = out
+	con.code = `puts(gsub("([a-z]+)@([a-z]+)", "$_2 at $_1", "bob@example joe@test"), sub("o", "0", "foo"));$0 = "a b c";gsub("b", "B");puts($0, $2);`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	if out.String() != "example at bob test at joe f0o\na B c B\n" {
+		t.Fatalf("want stdout 'example at bob test at joe f0o\na B c B\n', but got '%s'", out)
+	}
+}
+
+func TestCaseAndTrimFunc(t *testing.T) {
+	out := new(bytes.Buffer)
+
+	con := NewExecContext()
+	con.out = out
+	con.code = `puts(toupper("abc"), tolower("ABC"), "[" . trim("　 全角 ") . "]", trim("--x--", "-"))`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	if out.String() != "ABC abc [全角] x\n" {
+		t.Fatalf("want stdout 'ABC abc [全角] x\n', but got '%s'", out)
+	}
+}
+
+func TestSprintfFunc(t *testing.T) {
+	out := new(bytes.Buffer)
+
+	con := NewExecContext()
+	con.out = out
+	con.code = `puts(sprintf("%5.2f|%-4d|%03d|%x|%s|%c|%c|%%|%*d", 3.14159, 7, 5, 255, "文字", 65, "あい", 4, 9))`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	if out.String() != " 3.14|7   |005|ff|文字|A|あ|%|   9\n" {
+		t.Fatalf("want stdout '%s', but got '%s'", " 3.14|7   |005|ff|文字|A|あ|%|   9\n", out)
+	}
+}
+
+func TestPrintfFunc(t *testing.T) {
+	out := new(bytes.Buffer)
+
+	con := NewExecContext()
+	con.out = out
+	con.code = `printf("%05.1f|%-3s|%d\n", 2.5, "ab", 42);printf("no newline")`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	if out.String() != "002.5|ab |42\nno newline" {
+		t.Fatalf("want stdout '002.5|ab |42\nno newline', but got '%s'", out)
+	}
+}
+
+func TestNumberToStringConversion(t *testing.T) {
+	out := new(bytes.Buffer)
+
+	con := NewExecContext()
+	con.out = out
+	con.code = `puts(1234567, 0.1 + 0.2, "x" . 2 / 3);OFMT = "%.2f";puts(3.14159, 3.14159 . "");CONVFMT = "%.3f";puts("v=" . 2 / 3, OFMT, CONVFMT);`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	want := "1234567 0.30000000000000004 x0.6666666666666666\n3.14 3.14159\nv=0.667 %.2f %.3f\n"
+	if out.String() != want {
+		t.Fatalf("want stdout '%s', but got '%s'", want, out)
+	}
+}
+
+func TestNumberToStringKeepsPrecision(t *testing.T) {
+	out := new(bytes.Buffer)
+
+	con := NewExecContext()
+	con.out = out
+	con.topath = "TestNumberToStringKeepsPrecision.xlsx"
+	con.code = `x = 12345.67;["A1"] = x;["B2"] = ["A1"] . "";puts(x, x . "", ["A1"], "[" . OFMT . CONVFMT . "]")`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	want := "12345.67 12345.67 12345.67 []\n"
+	if out.String() != want {
+		t.Fatalf("want stdout '%s', but got '%s'", want, out)
+	}
+
+	f, err := excelize.OpenFile("TestNumberToStringKeepsPrecision.xlsx")
+	if err != nil {
+		t.Fatalf("open failed (%v)", err)
+	}
+	if v, _ := f.GetCellValue("Sheet1", "B2"); v != "12345.67" {
+		t.Fatalf("want B2 '12345.67', but got '%s'", v)
+	}
+}
+
+func TestCellAssignKeepsNumberPrecision(t *testing.T) {
+	con := NewExecContext()
+	con.topath = "TestCellAssignKeepsNumberPrecision.xlsx"
+	con.code = `["A1"] = 3.14159265358;["A2:A3"] = 1234567.5`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	want := map[string]string{"A1": "3.14159265358", "A3": "1234567.5"}
+	for axis, w := range want {
+		v := getCellValue(t, con.topath, "Sheet1", axis)
+		if v != w {
+			t.Fatalf("want cell %s value '%s', but got %s", axis, w, v)
+		}
+	}
+}
+
+func TestDateFunc(t *testing.T) {
+	out := new(bytes.Buffer)
+
+	con := NewExecContext()
+	con.out = out
+	con.code = `t = date(2024, 2, 29, 13, 5, 9);puts(strftime("%Y-%m-%d %H:%M:%S %a %b %j %I%p", t), strftime("%F", date(2024, 1, 32)));puts(strptime("2024/3/5 7:08", "%Y/%m/%d %H:%M") == date(2024, 3, 5, 7, 8, 0), strptime("xx", "%Y"), now() > 0);`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	want := "2024-02-29 13:05:09 Thu Feb 060 01PM 2024-02-01\n1 -1 1\n"
+	if out.String() != want {
+		t.Fatalf("want stdout '%s', but got '%s'", want, out)
+	}
+}
+
+func TestDateCellFunc(t *testing.T) {
+	out := new(bytes.Buffer)
+
+	con := NewExecContext()
+	con.out = out
+	con.topath = "TestDateCellFunc.xlsx"
+	con.code = `t = date(2024, 2, 29, 13, 5, 9);setdate("A1", t);setdate("A2:A3", date(2024, 1, 31), "yyyy/mm/dd");["B1"] = "text";puts(["A2"], strftime("%F", getdate("A3")), getdate("A1") == t, getdate("B1"), getdate("C1"));`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	want := "2024/01/31 2024-01-31 1 -1 -1\n"
+	if out.String() != want {
+		t.Fatalf("want stdout '%s', but got '%s'", want, out)
+	}
+
+	v := getCellValue(t, con.topath, "Sheet1", "A3")
+	if v != "2024/01/31" {
+		t.Fatalf("want cell A3 value '%s', but got %s", "2024/01/31", v)
+	}
+}
+
+func TestFormulaFunc(t *testing.T) {
+	out := new(bytes.Buffer)
+
+	con := NewExecContext()
+	con.out = out
+	con.topath = "TestFormulaFunc.xlsx"
+	con.code = `["A1:B3"] = 1;setformula("C1", "=SUM(A1:B1)");setformula("C2:C3", "A2+B2*$A$1");puts(formula("C1"), formula("C3"), formula("A1") == "");`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	want := "=SUM(A1:B1) =A3+B3*$A$1 1\n"
+	if out.String() != want {
+		t.Fatalf("want stdout '%s', but got '%s'", want, out)
+	}
+
+	f, err := excelize.OpenFile(con.topath)
+	if err != nil {
+		t.Fatalf("on error occured open '%s'.", con.topath)
+	}
+	v, _ := f.GetCellFormula("Sheet1", "C2")
+	if v != "A2+B2*$A$1" {
+		t.Fatalf("want cell C2 formula '%s', but got %s", "A2+B2*$A$1", v)
+	}
+}
+
+func TestCalcFunc(t *testing.T) {
+	out := new(bytes.Buffer)
+
+	con := NewExecContext()
+	con.out = out
+	con.topath = "TestCalcFunc.xlsx"
+	con.code = `["A1:A2"] = 1;setformula("B1:B2", "A1*2");setformula("C1", "SUM(B1:B2)");puts(calc("C1"));["A2"] = 5;puts(["C1"]);puts(calc("C1"));puts(["B2"], calc());`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	want := "4\n4\n12\n10 3\n"
+	if out.String() != want {
+		t.Fatalf("want stdout '%s', but got '%s'", want, out)
+	}
+
+	v := getCellValue(t, con.topath, "Sheet1", "C1")
+	if v != "12" {
+		t.Fatalf("want cell C1 value '%s', but got %s", "12", v)
+	}
+}
+
+func TestCalcDefinedName(t *testing.T) {
+	out := new(bytes.Buffer)
+
+	con := NewExecContext()
+	con.out = out
+	con.code = `["A1"]=0.5;defname("Rate","A1");defname("TaxRate","=0.1");setformula("B1","Rate*2");setformula("B2","TaxRate*Rate*100");@="Sheet2";defname("Local_n","=3","Sheet2");setformula("Sheet1!B3","Sheet2!Local_n+1");setformula("B3","Local_n*2");setformula("Sheet1!B4","Unknown");puts(calc("Sheet1!B1"));puts(calc("Sheet1!B2"));puts(calc("Sheet1!B3"));puts(calc("B3"));puts(calc("Sheet1!B4"))`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	want := "1\n5\n4\n6\n#NAME?\n"
+	if out.String() != want {
+		t.Fatalf("want stdout '%s', but got '%s'", want, out)
+	}
+}
+
+func TestStyleFunc(t *testing.T) {
+	out := new(bytes.Buffer)
+
+	con := NewExecContext()
+	con.out = out
+	con.topath = "TestStyleFunc.xlsx"
+	con.code = `style("A1:C10", "bold;fill=yellow;border=thin");style("A1", "size=14;numfmt='#,##0;[Red]-#,##0'");for(i=1;i<=10;i++) style("B" . i, "align=center");puts(getstyle("A1"));puts(getstyle("B2"));puts(getstyle("D1") == "");`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	want := "bold;size=14;fill=#FFFF00;border=thin;numfmt='#,##0;[Red]-#,##0'\nbold;fill=#FFFF00;border=thin;align=center\n1\n"
+	if out.String() != want {
+		t.Fatalf("want stdout '%s', but got '%s'", want, out)
+	}
+
+	f, err := excelize.OpenFile(con.topath)
+	if err != nil {
+		t.Fatalf("on error occured open '%s'.", con.topath)
+	}
+	// the default style and the three styles created
+	if n := len(f.Styles.CellXfs.Xf); n != 4 {
+		t.Fatalf("want styles count '%d', but got '%d'", 4, n)
+	}
+}
+
+func TestLayoutFunc(t *testing.T) {
+	out := new(bytes.Buffer)
+
+	con := NewExecContext()
+	con.out = out
+	con.topath = "TestLayoutFunc.xlsx"
+	con.code = `merge("A1:C1");merge("A3:B4");unmerge("B4");n = merged(m);puts(n, m[1]);colwidth("B:C", 20);rowheight("2:3", 30);puts(colwidth("C"), rowheight(3));hidecol("E");hiderow("5");freeze("A2");`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	want := "1 A1:C1\n20 30\n"
+	if out.String() != want {
+		t.Fatalf("want stdout '%s', but got '%s'", want, out)
+	}
+
+	f, err := excelize.OpenFile(con.topath)
+	if err != nil {
+		t.Fatalf("on error occured open '%s'.", con.topath)
+	}
+	if cells, _ := f.GetMergeCells("Sheet1"); len(cells) != 1 || cells[0].GetStartAxis() != "A1" || cells[0].GetEndAxis() != "C1" {
+		t.Fatalf("want merged cells '%s', but got %v", "A1:C1", cells)
+	}
+	if w, _ := f.GetColWidth("Sheet1", "B"); w != 20 {
+		t.Fatalf("want column B width '%d', but got %v", 20, w)
+	}
+	if v, _ := f.GetColVisible("Sheet1", "E"); v {
+		t.Fatal("want column E hidden, but visible")
+	}
+	if v, _ := f.GetRowVisible("Sheet1", 5); v {
+		t.Fatal("want row 5 hidden, but visible")
+	}
+}
+
+func TestInsertDeleteFunc(t *testing.T) {
+	out := new(bytes.Buffer)
+
+	con := NewExecContext()
+	con.out = out
+	con.topath = "TestInsertDeleteFunc.xlsx"
+	con.code = `for(i=1;i<=5;i++){["A" . i]=i;};setformula("B1","SUM(A1:A5)");setformula("B2","A3*2");merge("C2:D3");insertrow(2, 2);deleterow("6:7");insertcol("A", 2);deletecol(1);puts(formula("C1"), formula("C4"));merged(m);puts(m[1]);`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	want := "=SUM(B1:B5) =B5*2\nD4:E5\n"
+	if out.String() != want {
+		t.Fatalf("want stdout '%s', but got '%s'", want, out)
+	}
+
+	f, err := excelize.OpenFile(con.topath)
+	if err != nil {
+		t.Fatalf("on error occured open '%s'.", con.topath)
+	}
+	for axis, want := range map[string]string{"B1": "1", "B2": "", "B4": "2", "B5": "3", "B6": ""} {
+		if v, _ := f.GetCellValue("Sheet1", axis); v != want {
+			t.Fatalf("want cell %s '%s', but got '%s'", axis, want, v)
+		}
+	}
+}
+
+func TestMoverangeFunc(t *testing.T) {
+	out := new(bytes.Buffer)
+
+	con := NewExecContext()
+	con.out = out
+	con.topath = "TestMoverangeFunc.xlsx"
+	con.code = `["A1"]=1;["A2"]="two";style("A1","bold");setformula("A3","A1+1");setformula("C1","SUM(A1:A3)");setformula("C2","B1");puts(moverange("A1:A3","B1"));puts(formula("C1"), formula("C2"), formula("B3"), getstyle("B1"));`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	want := "B1:B3\n=SUM(B1:B3) =#REF! =B1+1 bold\n"
+	if out.String() != want {
+		t.Fatalf("want stdout '%s', but got '%s'", want, out)
+	}
+
+	f, err := excelize.OpenFile(con.topath)
+	if err != nil {
+		t.Fatalf("on error occured open '%s'.", con.topath)
+	}
+	for axis, want := range map[string]string{"A1": "", "A2": "", "B1": "1", "B2": "two"} {
+		if v, _ := f.GetCellValue("Sheet1", axis); v != want {
+			t.Fatalf("want cell %s '%s', but got '%s'", axis, want, v)
+		}
+	}
+}
+
+func TestSortFunc(t *testing.T) {
+	out := new(bytes.Buffer)
+
+	con := NewExecContext()
+	con.out = out
+	con.topath = "TestSortFunc.xlsx"
+	con.code = `["A1"]="name";["B1"]="score";["A2"]="bob";["B2"]=70;["A3"]="alice";["B3"]=90;["A4"]="carol";["B4"]=70;["A5"]="dave";["A6"]="eve";["B6"]="n/a";setformula("C2","B2*2");puts(sort("A1:C6","B desc","A","header"));`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	want := "A1:C6\n"
+	if out.String() != want {
+		t.Fatalf("want stdout '%s', but got '%s'", want, out)
+	}
+
+	f, err := excelize.OpenFile(con.topath)
+	if err != nil {
+		t.Fatalf("on error occured open '%s'.", con.topath)
+	}
+	names := map[string]string{"A1": "name", "A2": "eve", "A3": "alice", "A4": "bob", "A5": "carol", "A6": "dave"}
+	for axis, want := range names {
+		if v, _ := f.GetCellValue("Sheet1", axis); v != want {
+			t.Fatalf("want cell %s '%s', but got '%s'", axis, want, v)
+		}
+	}
+	if v, _ := f.GetCellValue("Sheet1", "B3"); v != "90" {
+		t.Fatalf("want cell B3 '%s', but got '%s'", "90", v)
+	}
+	if v, _ := f.GetCellFormula("Sheet1", "C4"); v != "B4*2" {
+		t.Fatalf("want formula C4 '%s', but got '%s'", "B4*2", v)
+	}
+}
+
+func TestTableFunc(t *testing.T) {
+	out := new(bytes.Buffer)
+
+	con := NewExecContext()
+	con.out = out
+	con.topath = "TestTableFunc.xlsx"
+	con.code = `["A1"]="Region";["B1"]="Amount";["A2"]="East";["B2"]=1000;["A3"]="West";["B3"]=3000;["A4"]="East";["B4"]=2500;puts(table("A1:B4","Sales"));puts(tablerange("Sales"), tablerange("Sales[#All]"), sum(["Sales[Amount]"]));`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	want := "Sales\nSheet1!A2:B4 Sheet1!A1:B4 6500\n"
+	if out.String() != want {
+		t.Fatalf("want stdout '%s', but got '%s'", want, out)
+	}
+
+	// read the table from the file
+	out.Reset()
+	con = NewExecContext()
+	con.out = out
+	con.frompath = "TestTableFunc.xlsx"
+	con.code = `n=tables(t);puts(n, t[1], tablerange("sales[region]"));`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	want = "1 Sales Sheet1!A2:A4\n"
+	if out.String() != want {
+		t.Fatalf("want stdout '%s', but got '%s'", want, out)
+	}
+}
+
+func TestTableFollowsStructure(t *testing.T) {
+	out := new(bytes.Buffer)
+
+	con := NewExecContext()
+	con.out = out
+	con.topath = "TestTableFollowsStructure.xlsx"
+	con.code = `["A1"]="Item";["B1"]="Amount";["A2"]="x";["B2"]=3;["A3"]="y";["B3"]=4;table("A1:B3","Sales");insertrow(1);puts(sum(["Sales[Amount]"]));insertcol("B");puts(["B2"]);puts(sum(["Sales[Amount]"]));deleterow(3);puts(sum(["Sales[Amount]"]));moverange("A2:C3","E10");puts(["E10"], sum(["Sales[Amount]"]))`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	want := "7\nColumn1\n7\n4\nItem 4\n"
+	if out.String() != want {
+		t.Fatalf("want stdout '%s', but got '%s'", want, out)
+	}
+
+	// read the table from the file
+	out.Reset()
+	con = NewExecContext()
+	con.out = out
+	con.frompath = "TestTableFollowsStructure.xlsx"
+	con.code = `deletecol("F");puts(sum(["Sales[Amount]"]), ["F10"])`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	want = "4 Amount\n"
+	if out.String() != want {
+		t.Fatalf("want stdout '%s', but got '%s'", want, out)
+	}
+}
+
+func TestAutofilterFunc(t *testing.T) {
+	out := new(bytes.Buffer)
+
+	con := NewExecContext()
+	con.out = out
+	con.topath = "TestAutofilterFunc.xlsx"
+	con.code = `["A1"]="Region";["B1"]="Amount";["A2"]="East";["B2"]=1000;["A3"]="West";["B3"]=3000;["A4"]="East";["B4"]=2500;puts(autofilter("A1:B4", "A == East"));`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	want := "2\n"
+	if out.String() != want {
+		t.Fatalf("want stdout '%s', but got '%s'", want, out)
+	}
+
+	f, err := excelize.OpenFile(con.topath)
+	if err != nil {
+		t.Fatalf("on error occured open '%s'.", con.topath)
+	}
+	if v, _ := f.GetRowVisible("Sheet1", 3); v {
+		t.Fatal("want row 3 hidden, but visible")
+	}
+	if v, _ := f.GetRowVisible("Sheet1", 4); !v {
+		t.Fatal("want row 4 visible, but hidden")
+	}
+}
+
+func TestCondformatFunc(t *testing.T) {
+	out := new(bytes.Buffer)
+
+	con := NewExecContext()
+	con.out = out
+	con.topath = "TestCondformatFunc.xlsx"
+	con.code = `for(i=1;i<=5;i++){["A" . i]=i*50;};puts(condformat("A1:A5","> 100","fill=#FFC7CE;color=#9C0006"), condformat("B1","=$A1>$A2","bold"), colorscale("A1:A5","red","green"), databar("A1:A5","blue"));`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	want := "A1:A5 B1 A1:A5 A1:A5\n"
+	if out.String() != want {
+		t.Fatalf("want stdout '%s', but got '%s'", want, out)
+	}
+}
+
+func TestValidateFunc(t *testing.T) {
+	out := new(bytes.Buffer)
+
+	con := NewExecContext()
+	con.out = out
+	con.topath = "TestValidateFunc.xlsx"
+	con.code = `puts(validate("A2:A10","list Yes,No","input='Choose one'"), validate("B2:B10","whole between 1 and 100","error='1 to 100';error-style=warning"), validate("C2","date >= 2020-04-01"));`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	want := "A2:A10 B2:B10 C2\n"
+	if out.String() != want {
+		t.Fatalf("want stdout '%s', but got '%s'", want, out)
+	}
+}
+
+func TestValidateFollowsStructure(t *testing.T) {
+	con := NewExecContext()
+	con.topath = "TestValidateFollowsStructure.xlsx"
+	con.code = `validate("B2:B5","whole between 1 and 100");validate("D2:D3","list =$E$1:$E$3");condformat("C2:C5","=$C2>$A3","bold");condformat("A1","> 1","bold");insertrow(1);deleterow(2);insertcol("A")`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	f, err := excelize.OpenFile(con.topath)
+	if err != nil {
+		t.Fatalf("on error occured open '%s'.", con.topath)
+	}
+	sheet := string(f.XLSX["xl/worksheets/sheet1.xml"])
+	for _, want := range []string{`sqref="C2:C5"`, `sqref="E2:E3"`, `<formula1>$F$2:$F$3</formula1>`,
+		`<conditionalFormatting sqref="D2:D5">`, `<formula>$D2&gt;$B3</formula>`} {
+		if !strings.Contains(sheet, want) {
+			t.Fatalf("want '%s' in the sheet, but got '%s'", want, sheet)
+		}
+	}
+	if strings.Count(sheet, "<conditionalFormatting") != 1 {
+		t.Fatalf("want the conditional format of the deleted cell removed, but got '%s'", sheet)
+	}
+}
+
+func TestCommentFunc(t *testing.T) {
+	out := new(bytes.Buffer)
+
+	con := NewExecContext()
+	con.out = out
+	con.topath = "TestCommentFunc.xlsx"
+	con.code = `comment("B3","Check this","Alice");comment("A1","plain");comment("B3","Fixed","Bob");puts(comment("B3"), comment("C1"));`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	want := "Fixed \n"
+	if out.String() != want {
+		t.Fatalf("want stdout '%s', but got '%s'", want, out)
+	}
+
+	// read the comments from the file
+	out.Reset()
+	con = NewExecContext()
+	con.out = out
+	con.frompath = "TestCommentFunc.xlsx"
+	con.code = `comment("A1","");n=comments(c);puts(n);for(i=1;i<=n;i++) puts(c[i], c[i,"author"], c[i,"text"]);`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	want = "1\nB3 Bob Fixed\n"
+	if out.String() != want {
+		t.Fatalf("want stdout '%s', but got '%s'", want, out)
+	}
+}
+
+func TestCommentFollowsCells(t *testing.T) {
+	out := new(bytes.Buffer)
+
+	con := NewExecContext()
+	con.out = out
+	con.topath = "TestCommentFollowsCells.xlsx"
+	con.code = `comment("A10","x","Alice");comment("A11","y");comment("C3","z");insertrow(1);deleterow(4);insertcol("A");moverange("B11","D1");["B1"]=3;["B2"]=1;comment("B1","s");sort("B1:B2");n=comments(c);for(i=1;i<=n;i++) puts(c[i], c[i,"author"], c[i,"text"]);`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	want := "D1  y\nB2  s\nB10 Alice x\n"
+	if out.String() != want {
+		t.Fatalf("want stdout '%s', but got '%s'", want, out)
+	}
+
+	// read the moved comments from the file
+	out.Reset()
+	con = NewExecContext()
+	con.out = out
+	con.frompath = "TestCommentFollowsCells.xlsx"
+	con.code = `puts(comment("A10"), comment("B10"), comment("D1"));`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	want = " x y\n"
+	if out.String() != want {
+		t.Fatalf("want stdout '%s', but got '%s'", want, out)
+	}
+}
+
+func TestLinkFunc(t *testing.T) {
+	out := new(bytes.Buffer)
+
+	con := NewExecContext()
+	con.out = out
+	con.topath = "TestLinkFunc.xlsx"
+	con.code = `link("A1","https://example.com");link("A2","#Sheet1!B3","Detail");link("A3","https://example.com");link("A3","");puts(["A1"], ["A2"]);`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	want := "https://example.com Detail\n"
+	if out.String() != want {
+		t.Fatalf("want stdout '%s', but got '%s'", want, out)
+	}
+
+	// read the hyperlinks from the file
+	out.Reset()
+	con = NewExecContext()
+	con.out = out
+	con.frompath = "TestLinkFunc.xlsx"
+	con.code = `link("A1","https://example.org");puts(link("A1"), link("A2"), link("A3"));`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	want = "https://example.org #Sheet1!B3 \n"
+	if out.String() != want {
+		t.Fatalf("want stdout '%s', but got '%s'", want, out)
+	}
+}
+
+func TestChartFunc(t *testing.T) {
+	out := new(bytes.Buffer)
+
+	con := NewExecContext()
+	con.out = out
+	con.topath = "TestChartFunc.xlsx"
+	con.code = `["A1"]="Month";["B1"]="Sales";for(i=2;i<=4;i++){["A" . i]="M" . i;["B" . i]=i*100;};puts(chart("A1:B4","line","D2","title='Monthly sales'"), chart("A1:B4","pie","D20"));`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	want := "D2 D20\n"
+	if out.String() != want {
+		t.Fatalf("want stdout '%s', but got '%s'", want, out)
+	}
+}
+
+func TestImageFunc(t *testing.T) {
+	out := new(bytes.Buffer)
+
+	con := NewExecContext()
+	con.out = out
+	con.topath = "TestImageFunc.xlsx"
+	con.code = `puts(image("test/image.png","B2",0.5));`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	want := "B2\n"
+	if out.String() != want {
+		t.Fatalf("want stdout '%s', but got '%s'", want, out)
+	}
+}
+
+func TestNamesFunc(t *testing.T) {
+	out := new(bytes.Buffer)
+
+	con := NewExecContext()
+	con.out = out
+	con.topath = "TestNamesFunc.xlsx"
+	con.code = `["B2"]=10;["C3"]=20;defname("Total","B2");defname("Total","C3","Sheet1");defname("Data","A1:C3");puts(["Total"], sum(["Data"]));`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	want := "20 30\n"
+	if out.String() != want {
+		t.Fatalf("want stdout '%s', but got '%s'", want, out)
+	}
+
+	// read the names from the file
+	out.Reset()
+	con = NewExecContext()
+	con.out = out
+	con.frompath = "TestNamesFunc.xlsx"
+	con.code = `undefname("Total","Sheet1");["Total"]=5;n=names(a);for(i=1;i<=n;i++){puts(a[i], a[i,"ref"], ["B2"]);};`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	want = "Total Sheet1!$B$2 5\nData Sheet1!$A$1:$C$3 5\n"
+	if out.String() != want {
+		t.Fatalf("want stdout '%s', but got '%s'", want, out)
+	}
+}
+
+func TestDefnameScopedRef(t *testing.T) {
+	out := new(bytes.Buffer)
+
+	con := NewExecContext()
+	con.out = out
+	con.code = `@="Sheet2";["B1"]=3;["B2"]=4;@="Sheet1";["B1"]=1;puts(defname("Local","B1:B2","Sheet2"));puts(defname("Other","Sheet1!B1","Sheet2"));puts(sum(["Sheet2!Local"]), ["Sheet2!Other"])`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	want := "Sheet2!$B$1:$B$2\nSheet1!$B$1\n7 1\n"
+	if out.String() != want {
+		t.Fatalf("want stdout '%s', but got '%s'", want, out)
+	}
+}
+
+func TestNamesFollowCells(t *testing.T) {
+	out := new(bytes.Buffer)
+
+	con := NewExecContext()
+	con.out = out
+	con.code = `defname("Total","A2");["A2"]=7;insertrow(1);puts(["Total"]);names(a);puts(a[1,"ref"]);deletecol(1);names(a);puts(a[1,"ref"]);defname("Pick","B3");["B3"]="c";["B4"]="a";["B5"]="b";sort("B3:B5");names(a);puts(a[2,"ref"]);moverange("B5","D1");names(a);puts(a[2,"ref"], ["Pick"])`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	want := "7\nSheet1!$A$3\nSheet1!#REF!\nSheet1!$B$5\nSheet1!$D$1 c\n"
+	if out.String() != want {
+		t.Fatalf("want stdout '%s', but got '%s'", want, out)
+	}
+}
+
+func TestCSVFunc(t *testing.T) {
+	out := new(bytes.Buffer)
+
+	con := NewExecContext()
+	con.out = out
+	con.topath = "TestCSVFunc.xlsx"
+	con.code = `["A1"]="name";["B1"]="qty";["A2"]="Smith, J";["B2"]=3;puts(exportcsv("TestCSVFunc.tsv","","encoding=shift_jis;crlf"));puts(importcsv("TestCSVFunc.tsv","D2","encoding=shift_jis"));puts(["D3"], ["E3"] + 1);exportcsv("-","A1:B2","delim=semicolon")`
+	run(con)
+	defer os.Remove("TestCSVFunc.tsv")
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	want := "2\n2\nSmith, J 4\nname;qty\nSmith, J;3\n"
+	if out.String() != want {
+		t.Fatalf("want stdout '%s', but got '%s'", want, out)
+	}
+}
+
+func TestDumpFunc(t *testing.T) {
+	out := new(bytes.Buffer)
+
+	con := NewExecContext()
+	con.out = out
+	con.code = `["A1"]="name";["B1"]="note";["A2"]="a|b";["B2"]=1.5;["A3"]="merged";merge("A3:B3");printf("%s", dump("json"));printf("%s", dump("md","A1:B2","header"));printf("%s", dump("html","A3:B3"))`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	want := `[
+  ["name","note"],
+  ["a|b",1.5],
+  ["merged"]
+]
+| name | note |
+| --- | --- |
+| a\|b | 1.5 |
+<table>
+<tbody>
+<tr><td colspan="2">merged</td></tr>
+</tbody>
+</table>
+`
+	if out.String() != want {
+		t.Fatalf("want stdout '%s', but got '%s'", want, out)
+	}
+}
+
+func TestJSONFunc(t *testing.T) {
+	out := new(bytes.Buffer)
+
+	con := NewExecContext()
+	con.out = out
+	con.code = `n = json("{\"items\":[{\"name\":\"x\",\"qty\":2},{\"name\":\"y\"}],\"ok\":true,\"none\":null}", a);puts(n, a["items",1,"name"], a["items",1,"qty"]*2, a["items",2,"name"], a["ok"], "[" . a["none"] . "]")`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	want := "3 x 4 y 1 []\n"
+	if out.String() != want {
+		t.Fatalf("want stdout '%s', but got '%s'", want, out)
+	}
+}
+
+func TestImportjsonFunc(t *testing.T) {
+	in := bufio.NewReader(bytes.NewBufferString(`{"id":1,"name":"Smith","address":{"city":"Tokyo","zip":"100-0001"}}
+{"id":2,"name":"Lee","email":"lee@example.com","address":{"city":"Osaka"}}
+`))
+	out := new(bytes.Buffer)
+
+	con := NewExecContext()
+	con.in = in
+	con.out = out
+	con.code = `puts(importjson("-","B2"));puts(["C2"], ["E2"], ["E3"], ["F4"] . "|" . ["G4"], ["B4"] + 1);puts(importjson("test/users.json","A10","name,address.city"));puts(["A10"], ["B11"], ["C11"] . "|")`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	want := "2\nname address.zip 100-0001 lee@example.com| 3\n2\nname Tokyo |\n"
+	if out.String() != want {
+		t.Fatalf("want stdout '%s', but got '%s'", want, out)
+	}
+}
+
+func TestAggregateFunc(t *testing.T) {
+	out := new(bytes.Buffer)
+
+	con := NewExecContext()
+	con.out = out
+
+	con.code = `["A1"] = 1;["A2"] = 2;["A3"] = "text";["A4"] = 6;puts(sum(["A1:A4"]), avg(["A:A"]), min(["A1:A4"]), max(["A1:A4"], 10), sum(1, 2));`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	if out.String() != "9 3 1 10 3\n" {
+		t.Fatalf("want stdout '9 3 1 10 3\n', but got '%s'", out)
+	}
+}
+
+func TestCountifFunc(t *testing.T) {
+	out := new(bytes.Buffer)
+
+	con := NewExecContext()
+	con.out = out
+
+	con.code = `["A1"] = 1;["A2"] = 5;["A3"] = "apple";["A4"] = "Apricot";["A5"] = 10;puts(countif(["A1:A5"], ">=5"), countif(["A1:A5"], "ap*"), countif(["A1:A5"], "<>apple"), countif(["A1:A6"], ""), countif(["A1:A5"], 5));`
+	run(con)
+
+	if con.exitCode != 0 {
+		t.Fatalf("exit code '%s'. want '%d' but got '%d'", con.code, 0, con.exitCode)
+	}
+
+	if out.String() != "2 2 4 1 1\n" {
+		t.Fatalf("want stdout '2 2 4 1 1\n', but got '%s'", out)
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/360EntSecGroup-Skylar/excelize/v2"
+)
+
+// The defined names refer to the cells or the ranges like "InvoiceTotal" of "=Sheet1!$F$20".
+// A name is scoped to the workbook or a sheet, and the name scoped to the sheet is preferred like Excel.
+
+var definedNameReg = regexp.MustCompile(`^[A-Za-z_\\][A-Za-z0-9_.\\]*$`)
+
+// definedName is a defined name of the workbook.
+type definedName struct {
+	name string
+	// scope is the sheet name, or "" if the name is scoped to the workbook
+	scope string
+	// refersTo is the reference or the formula without "=" like "Sheet1!$F$20"
+	refersTo string
+}
+
+// getDefinedNames returns the defined names of the workbook except the hidden names like "_xlnm._FilterDatabase".
+func (s *Spreadsheet) getDefinedNames() []*definedName {
+	// GetSheetList loads the workbook into excelize
+	s.file.GetSheetList()
+	wb := s.file.WorkBook
+	if wb == nil || wb.DefinedNames == nil {
+		return nil
+	}
+	names := make([]*definedName, 0, len(wb.DefinedNames.DefinedName))
+	for _, d := range wb.DefinedNames.DefinedName {
+		if d.Hidden {
+			continue
+		}
+		n := &definedName{name: d.Name, refersTo: d.Data}
+		if d.LocalSheetID != nil {
+			// localSheetId is the index of the sheet in the workbook
+			id := *d.LocalSheetID
+			if id < 0 || len(wb.Sheets.Sheet) <= id {
+				continue
+			}
+			n.scope = wb.Sheets.Sheet[id].Name
+		}
+		names = append(names, n)
+	}
+	return names
+}
+
+// getDefinedName returns the defined name ignoring case, or nil.
+// The name scoped to the sheet is preferred to the name scoped to the workbook.
+func (s *Spreadsheet) getDefinedName(name string, sheet string) *definedName {
+	var found *definedName
+	for _, n := range s.getDefinedNames() {
+		if !strings.EqualFold(n.name, name) {
+			continue
+		}
+		if n.scope == sheet {
+			return n
+		}
+		if n.scope == "" {
+			found = n
+		}
+	}
+	return found
+}
+
+// isDefinedNameRef reports whether the reference may be a defined name like "InvoiceTotal".
+func isDefinedNameRef(ref string) bool {
+	if !definedNameReg.MatchString(ref) {
+		return false
+	}
+	_, _, err := excelize.CellNameToCoordinates(ref)
+	return err != nil
+}
+
+// resolveDefinedName resolves the defined name seen from the sheet to the sheet and the cell or the range.
+// ok is false if the name is not found.
+func (s *Spreadsheet) resolveDefinedName(name string, sheet string) (refSheet string, ref string, ok bool, err error) {
+	n := s.getDefinedName(name, sheet)
+	if n == nil {
+		return "", "", false, nil
+	}
+	refSheet, ref, err = s.parseNameRef(n.refersTo)
+	if err != nil {
+		return "", "", true, fmt.Errorf("name '%s' does not refer to cells (%v)", n.name, err)
+	}
+	return refSheet, ref, true, nil
+}
+
+// parseNameRef parses the reference like "Sheet1!$A$1:$C$4" or "'My Sheet'!$B$2" of the defined name.
+func (s *Spreadsheet) parseNameRef(refersTo string) (sheet string, ref string, err error) {
+	refersTo = strings.TrimPrefix(strings.TrimSpace(refersTo), "=")
+	i := indexUnquoted(refersTo, '!')
+	if i < 0 {
+		return "", "", fmt.Errorf("'%s' is not a reference", refersTo)
+	}
+	sheet = unquoteSheetName(refersTo[:i])
+	ref = strings.ReplaceAll(refersTo[i+1:], "$", "")
+	if !s.existSheetName(sheet) {
+		return "", "", fmt.Errorf("'%s' is not a reference", refersTo)
+	}
+	if isRangeRef(ref) {
+		_, _, _, _, err = parseRangeRef(ref, 0, 0)
+	} else {
+		_, _, err = excelize.CellNameToCoordinates(ref)
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("'%s' is not a reference", refersTo)
+	}
+	return sheet, ref, nil
+}
+
+// setDefinedName creates or replaces the defined name scoped to the sheet, or the workbook if scope is "".
+// refersTo is the reference or the formula without "=".
+func (s *Spreadsheet) setDefinedName(name string, refersTo string, scope string) error {
+	if !definedNameReg.MatchString(name) || isCellLikeName(name) || strings.EqualFold(name, "R") || strings.EqualFold(name, "C") {
+		return fmt.Errorf("'%s' is invalid name", name)
+	}
+	if s.getTable(name) != nil {
+		return fmt.Errorf("'%s' is already used by the table", name)
+	}
+	id := -1
+	if scope != "" {
+		id = s.sheetIndex(scope)
+		if id < 0 {
+			return fmt.Errorf("sheet '%s' not exist", scope)
+		}
+	}
+
+	s.file.GetSheetList()
+	if wb := s.file.WorkBook; wb != nil && wb.DefinedNames != nil {
+		for i, d := range wb.DefinedNames.DefinedName {
+			if strings.EqualFold(d.Name, name) && sameLocalSheetID(d.LocalSheetID, id) {
+				wb.DefinedNames.DefinedName[i].Data = refersTo
+				return nil
+			}
+		}
+	}
+	if err := s.file.SetDefinedName(&excelize.DefinedName{Name: name, RefersTo: refersTo, Scope: scope}); err != nil {
+		return err
+	}
+	// excelize sets the sheet ID to localSheetId, but it must be the index of the sheet
+	if id >= 0 {
+		names := s.file.WorkBook.DefinedNames.DefinedName
+		names[len(names)-1].LocalSheetID = &id
+	}
+	return nil
+}
+
+// deleteDefinedName deletes the defined name scoped to the sheet, or the workbook if scope is "".
+// Return false if the name is not found.
+func (s *Spreadsheet) deleteDefinedName(name string, scope string) bool {
+	s.file.GetSheetList()
+	wb := s.file.WorkBook
+	if wb == nil || wb.DefinedNames == nil {
+		return false
+	}
+	id := -1
+	if scope != "" {
+		if id = s.sheetIndex(scope); id < 0 {
+			return false
+		}
+	}
+	deleted := false
+	names := wb.DefinedNames.DefinedName[:0]
+	for _, d := range wb.DefinedNames.DefinedName {
+		if strings.EqualFold(d.Name, name) && sameLocalSheetID(d.LocalSheetID, id) {
+			deleted = true
+			continue
+		}
+		names = append(names, d)
+	}
+	wb.DefinedNames.DefinedName = names
+	return deleted
+}
+
+// adjustDefinedNames replaces the reference of every defined name with the result of fn.
+// sheet is the scope of the name, or "" if the name is scoped to the workbook.
+func (s *Spreadsheet) adjustDefinedNames(fn func(sheet string, refersTo string) string) {
+	sheets := s.file.GetSheetList()
+	wb := s.file.WorkBook
+	if wb == nil || wb.DefinedNames == nil {
+		return
+	}
+	for i, d := range wb.DefinedNames.DefinedName {
+		scope := ""
+		if d.LocalSheetID != nil && 0 <= *d.LocalSheetID && *d.LocalSheetID < len(sheets) {
+			scope = sheets[*d.LocalSheetID]
+		}
+		if data := fn(scope, d.Data); data != d.Data {
+			wb.DefinedNames.DefinedName[i].Data = data
+		}
+	}
+}
+
+// sheetIndex returns the index of the sheet in the workbook, or -1.
+func (s *Spreadsheet) sheetIndex(sheet string) int {
+	for i, name := range s.file.GetSheetList() {
+		if name == sheet {
+			return i
+		}
+	}
+	return -1
+}
+
+func sameLocalSheetID(id *int, index int) bool {
+	if id == nil {
+		return index < 0
+	}
+	return *id == index
+}
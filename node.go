@@ -8,6 +8,8 @@ const (
 	NodeTypeStatements
 	NodeTypeNumberValue
 	NodeTypeStringValue
+	NodeTypeArray
+	NodeTypeRange
 )
 
 type Node interface {
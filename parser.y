@@ -11,18 +11,23 @@ package main
   ident string
   args *ArgList
   params *ParamList
+  prog  *Program
+  rule  *Rule
 }
-%type<stmts>  program stmts
+%type<prog>   program items
+%type<rule>   item
+%type<stmts>  stmts
 %type<stmt>   stmt
 %type<expr>   expr funcCall 
 %type<args>   argList
 %type<params> paramList
 %token<num>   NUMBER 
 %token<str>   STRING
-%token<token> LF '[' ']' '(' ')' ',' '=' NUMEQ NUMNE '<' NUMLE '>' NUMGE STREQ STRNE COLLT COLLE COLGT COLGE '.' '+' '-' '/' '*' '%' POW AND OR '!' ADD_ASSIGN SUB_ASSIGN MUL_ASSIGN DIV_ASSIGN MOD_ASSIGN POW_ASSIGN '~' NOT_MATCH IF ELSE '{' '}' WHILE CONCAT_ASSIGN BREAK CONTINUE INC DEC DO FOR FUNCTION RETURN
+%token<token> LF '[' ']' '(' ')' ',' '=' NUMEQ NUMNE '<' NUMLE '>' NUMGE STREQ STRNE COLLT COLLE COLGT COLGE '.' '+' '-' '/' '*' '%' POW AND OR '!' ADD_ASSIGN SUB_ASSIGN MUL_ASSIGN DIV_ASSIGN MOD_ASSIGN POW_ASSIGN '~' NOT_MATCH IF ELSE '{' '}' WHILE CONCAT_ASSIGN BREAK CONTINUE INC DEC DO FOR FUNCTION RETURN IN DELETE BEGIN END BEGINFILE ENDFILE
 %token<ident> IDENT
 %left '=' ADD_ASSIGN SUB_ASSIGN MUL_ASSIGN DIV_ASSIGN MOD_ASSIGN POW_ASSIGN CONCAT_ASSIGN
 %left AND OR '!'
+%nonassoc IN
 %left NUMEQ NUMNE '<' NUMLE '>' NUMGE STREQ STRNE COLLT COLLE COLGT COLGE
 %left '.' '+' '-'
 %left '/' '*' '%'
@@ -37,7 +42,19 @@ package main
 
 %%
 program
-  : stmts { yylex.(*Lexer).ast = $$ }
+  : items { yylex.(*Lexer).ast = $$ }
+
+items
+  : item { $$ = NewProgram($1) }
+  | items item { $$ = $1.appendRule($2) }
+
+item
+  : stmt { $$ = NewRule(MainRule, nil, $1) }
+  | BEGIN '{' stmts '}' { $$ = NewRule(BeginRule, nil, NewBlockStatement($3)) }
+  | END '{' stmts '}' { $$ = NewRule(EndRule, nil, NewBlockStatement($3)) }
+  | BEGINFILE '{' stmts '}' { $$ = NewRule(BeginFileRule, nil, NewBlockStatement($3)) }
+  | ENDFILE '{' stmts '}' { $$ = NewRule(EndFileRule, nil, NewBlockStatement($3)) }
+  | expr '{' stmts '}' { $$ = NewRule(MainRule, $1, NewBlockStatement($3)) }
 
 stmts
   : stmt { $$ = NewStatements($1) }
@@ -52,11 +69,14 @@ stmt
   | WHILE '(' expr ')' stmt { $$ = NewWhileStatement($3, $5) }
   | DO stmt WHILE '(' expr ')' LF { $$ = NewDoWhileStatement($2, $5) }
   | FOR '(' expr LF expr LF expr ')' stmt { $$ = NewForStatement($3, $5, $7, $9) }
+  | FOR '(' expr ')' stmt { $$ = NewForInStatement($3, $5) }
   | BREAK LF { $$ = NewBreakStatement() }
   | CONTINUE LF { $$ = NewContinueStatement() }
   | FUNCTION IDENT '(' paramList ')' stmt { $$ = NewFunctionDefineStatement($2, $4, $6) }
   | RETURN LF { $$ = NewReturnStatement(NewStringExpression("")) }
   | RETURN expr LF { $$ = NewReturnStatement($2) }
+  | DELETE IDENT '[' argList ']' LF { $$ = NewDeleteStatement(NewArrayReferExpression($2, $4)) }
+  | DELETE IDENT LF { $$ = NewDeleteStatement(NewVarReferExpression($2)) }
 
 expr
   : NUMBER { $$ = NewNumberExpression($1) }
@@ -87,6 +107,21 @@ expr
   | INC IDENT %prec PREINC { $$ = NewPreIncrementExpression($2) }
   | IDENT DEC { $$ = NewDecrementExpression($1) }
   | DEC IDENT %prec PREDEC { $$ = NewPreDecrementExpression($2) }
+  | IDENT '[' argList ']' { $$ = NewArrayReferExpression($1, $3) }
+  | IDENT '[' argList ']' '=' expr { $$ = NewArrayAssignExpression($1, $3, $6) }
+  | IDENT '[' argList ']' ADD_ASSIGN expr { $$ = NewAddArrayAssignExpression($1, $3, $6) }
+  | IDENT '[' argList ']' SUB_ASSIGN expr { $$ = NewSubArrayAssignExpression($1, $3, $6) }
+  | IDENT '[' argList ']' MUL_ASSIGN expr { $$ = NewMulArrayAssignExpression($1, $3, $6) }
+  | IDENT '[' argList ']' DIV_ASSIGN expr { $$ = NewDivArrayAssignExpression($1, $3, $6) }
+  | IDENT '[' argList ']' MOD_ASSIGN expr { $$ = NewModArrayAssignExpression($1, $3, $6) }
+  | IDENT '[' argList ']' POW_ASSIGN expr { $$ = NewPowArrayAssignExpression($1, $3, $6) }
+  | IDENT '[' argList ']' CONCAT_ASSIGN expr { $$ = NewConcatArrayAssignExpression($1, $3, $6) }
+  | IDENT '[' argList ']' INC { $$ = NewIncrementArrayExpression($1, $3) }
+  | INC IDENT '[' argList ']' %prec PREINC { $$ = NewPreIncrementArrayExpression($2, $4) }
+  | IDENT '[' argList ']' DEC { $$ = NewDecrementArrayExpression($1, $3) }
+  | DEC IDENT '[' argList ']' %prec PREDEC { $$ = NewPreDecrementArrayExpression($2, $4) }
+  | expr IN expr { $$ = NewArrayInExpression(NewArgList($1), $3) }
+  | '(' expr ',' argList ')' IN expr { $$ = NewArrayInExpression($4.appendArg($2), $7) }
   | funcCall
   | expr NUMEQ expr { $$ = NewNumberEQExpression($1, $3) }
   | expr NUMNE expr { $$ = NewNumberNEExpression($1, $3) }
@@ -119,6 +154,7 @@ expr
 funcCall
   : IDENT '(' ')' { $$ = NewFuncCallExpression($1, NewEmptyArgList()) }
   | IDENT '(' argList ')' { $$ = NewFuncCallExpression($1, $3) }
+  | DELETE '(' argList ')' { $$ = NewFuncCallExpression("delete", $3) }
 
 argList
   : expr { $$ = NewArgList($1) }
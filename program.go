@@ -0,0 +1,153 @@
+package main
+
+const (
+	MainRule = iota
+	BeginRule
+	EndRule
+	BeginFileRule
+	EndFileRule
+)
+
+// Rule is a 'pattern { action }' of the program.
+// Plain statements are main rules without pattern.
+type Rule struct {
+	ruleType int
+	pattern  *Expression
+	action   *Statement
+}
+
+func NewRule(ruleType int, pattern *Expression, action *Statement) *Rule {
+	return &Rule{ruleType: ruleType, pattern: pattern, action: action}
+}
+
+type Program struct {
+	rules []*Rule
+}
+
+func NewProgram(rule *Rule) *Program {
+	return &Program{rules: []*Rule{rule}}
+}
+
+func (p *Program) appendRule(rule *Rule) *Program {
+	p.rules = append(p.rules, rule)
+	return p
+}
+
+// run runs the BEGIN rules, the BEGINFILE rules, the main rules and the ENDFILE rules for each input workbook, and the END rules.
+// The active sheet of each workbook is dumped after the main rules if -dump is specified.
+func (p *Program) run() {
+	// the functions defined at the top level can be called before their definitions
+	for _, r := range p.rules {
+		if r.ruleType == MainRule && r.pattern == nil && r.action.stmtType == FunctionStatement {
+			r.action.eval()
+		}
+	}
+
+	files := execContext.inputFiles()
+	loadFile(files[0], 1)
+
+	p.runRules(BeginRule)
+	checkLoopControl()
+
+	for i := 0; i < len(files) && !execContext.doExit; i++ {
+		if 0 < i {
+			loadFile(files[i], i+1)
+		}
+		p.runRules(BeginFileRule)
+		checkLoopControl()
+		if !execContext.doExit {
+			p.runMain()
+		}
+		if !execContext.doExit {
+			p.runRules(EndFileRule)
+			checkLoopControl()
+		}
+		// -dump option
+		if execContext.dump != "" {
+			dumpSheet(execContext.dump, execContext.dumpHeader)
+		}
+	}
+
+	// exit in BEGIN or main rules still runs END rules
+	execContext.doExit = false
+	p.runRules(EndRule)
+	checkLoopControl()
+}
+
+// runMain runs the main rules for each line(-n) or each Excel row(-N).
+func (p *Program) runMain() {
+	if !execContext.doTextRowLoop {
+		p.runRows()
+		checkLoopControl()
+		return
+	}
+
+	for !execContext.doExit {
+		if _, ok := readRecord(); !ok {
+			break
+		}
+		p.runRows()
+		if endOfLoop() {
+			break
+		}
+	}
+}
+
+// runRows runs the main rules for each Excel row from SER to LR if -N is specified.
+// The rows of the active sheet are read by the cursor.
+func (p *Program) runRows() {
+	if !execContext.doExcelRowLoop {
+		p.runRules(MainRule)
+		return
+	}
+
+	scope := execContext.scope
+	scope.set("NER", scope.get("SER"))
+	for scope.get("NER").asNumber() <= scope.get("LR").asNumber() {
+		scope.set("FNR", NewNumberExpression(scope.get("FNR").asNumber()+1))
+		book := execContext.spreadsheet
+		if ner := int(scope.get("NER").asNumber()); 1 <= ner {
+			book.seekSheetRow(book.activeSheet, ner)
+		}
+		p.runRules(MainRule)
+		if endOfLoop() {
+			break
+		}
+		scope.set("NER", NewNumberExpression(scope.get("NER").asNumber()+1))
+	}
+	execContext.spreadsheet.closeRowCursor()
+}
+
+func (p *Program) runRules(ruleType int) {
+	for _, r := range p.rules {
+		if r.ruleType != ruleType {
+			continue
+		}
+		if r.pattern != nil && !r.pattern.eval().isTruthy() {
+			continue
+		}
+		r.action.eval()
+		if execContext.doExit || execContext.doBreak || execContext.doContinue {
+			break
+		}
+	}
+}
+
+// endOfLoop consumes break and continue, and reports whether the loop should be finished.
+func endOfLoop() bool {
+	execContext.doContinue = false
+	if execContext.doBreak {
+		execContext.doBreak = false
+		return true
+	}
+	return execContext.doExit
+}
+
+func checkLoopControl() {
+	if execContext.doBreak {
+		fatalError("'break' is not allowed outside a loop")
+	}
+	if execContext.doContinue {
+		fatalError("'continue' is not allowed outside a loop")
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/360EntSecGroup-Skylar/excelize/v2"
+)
+
+// Range is a rectangular area of cells like "A1:C10".
+// Columns and rows are start by 1.
+type Range struct {
+	book  *Spreadsheet
+	sheet string
+	// qualifier is the "book:sheet!" part of the reference, empty for the active sheet
+	qualifier string
+	startCol  int
+	startRow  int
+	endCol    int
+	endRow    int
+}
+
+// isRangeRef reports whether the ref refers to a range.
+func isRangeRef(ref string) bool {
+	return strings.Contains(ref, ":")
+}
+
+// parseRangeRef parses the range reference.
+// "A1:C10", whole columns "B:B" and whole rows "3:3" are supported.
+// For whole columns and rows, lastRow and lastCol are used as the end of range.
+func parseRangeRef(ref string, lastCol int, lastRow int) (startCol, startRow, endCol, endRow int, err error) {
+	a := strings.Split(ref, ":")
+	if len(a) != 2 {
+		return 0, 0, 0, 0, fmt.Errorf("'%s' is invalid range", ref)
+	}
+	from := strings.ToUpper(strings.TrimSpace(a[0]))
+	to := strings.ToUpper(strings.TrimSpace(a[1]))
+
+	if sc, e1 := strconv.Atoi(from); e1 == nil {
+		// whole rows like "3:3"
+		ec, e2 := strconv.Atoi(to)
+		if e2 != nil || sc < 1 || ec < 1 {
+			return 0, 0, 0, 0, fmt.Errorf("'%s' is invalid range", ref)
+		}
+		startCol, startRow, endCol, endRow = 1, sc, lastCol, ec
+	} else if sc, e1 := excelize.ColumnNameToNumber(from); e1 == nil {
+		// whole columns like "B:B"
+		ec, e2 := excelize.ColumnNameToNumber(to)
+		if e2 != nil {
+			return 0, 0, 0, 0, fmt.Errorf("'%s' is invalid range", ref)
+		}
+		startCol, startRow, endCol, endRow = sc, 1, ec, lastRow
+	} else {
+		sc, sr, e1 := excelize.CellNameToCoordinates(from)
+		ec, er, e2 := excelize.CellNameToCoordinates(to)
+		if e1 != nil || e2 != nil {
+			return 0, 0, 0, 0, fmt.Errorf("'%s' is invalid range", ref)
+		}
+		startCol, startRow, endCol, endRow = sc, sr, ec, er
+	}
+
+	if endCol < startCol && 0 < endCol {
+		startCol, endCol = endCol, startCol
+	}
+	if endRow < startRow && 0 < endRow {
+		startRow, endRow = endRow, startRow
+	}
+	return startCol, startRow, endCol, endRow, nil
+}
+
+func (r *Range) width() int {
+	if r.endCol < r.startCol {
+		return 0
+	}
+	return r.endCol - r.startCol + 1
+}
+
+func (r *Range) height() int {
+	if r.endRow < r.startRow {
+		return 0
+	}
+	return r.endRow - r.startRow + 1
+}
+
+// axis returns the cell name at the offset from the top left cell.
+func (r *Range) axis(colOffset int, rowOffset int) string {
+	name, err := excelize.CoordinatesToCellName(r.startCol+colOffset, r.startRow+rowOffset)
+	if err != nil {
+		fatalError("range '%s' is out of sheet", r.ref())
+	}
+	return name
+}
+
+// axes returns the cell names in the range in row-major order.
+func (r *Range) axes() []string {
+	ret := make([]string, 0, r.width()*r.height())
+	for y := 0; y < r.height(); y++ {
+		for x := 0; x < r.width(); x++ {
+			ret = append(ret, r.axis(x, y))
+		}
+	}
+	return ret
+}
+
+// names returns the qualified cell names in the range in row-major order.
+func (r *Range) names() []string {
+	ret := r.axes()
+	for i := range ret {
+		ret[i] = r.qualifier + ret[i]
+	}
+	return ret
+}
+
+// values returns the cell values in the range as rows.
+func (r *Range) values() [][]string {
+	return r.book.getRangeValues(r)
+}
+
+// contains reports whether the cell is in the range.
+// The cell name may be qualified by a workbook and a sheet.
+func (r *Range) contains(name string) bool {
+	addr := parseCellAddress(name)
+	if addr.book != r.book || addr.sheet != r.sheet {
+		return false
+	}
+	c, row, err := excelize.CellNameToCoordinates(addr.ref)
+	if err != nil {
+		return false
+	}
+	return r.startCol <= c && c <= r.endCol && r.startRow <= row && row <= r.endRow
+}
+
+// ref returns the range reference like "A1:C10".
+func (r *Range) ref() string {
+	from, _ := excelize.CoordinatesToCellName(r.startCol, r.startRow)
+	to, _ := excelize.CoordinatesToCellName(r.endCol, r.endRow)
+	return from + ":" + to
+}
+
+// sqref returns the reference like "A1" for a single cell, and like "A1:B2" for the others.
+func (r *Range) sqref() string {
+	if r.width() == 1 && r.height() == 1 {
+		return r.axis(0, 0)
+	}
+	return r.ref()
+}
+
+func (r *Range) eval() Node {
+	return r
+}
+
+func (r *Range) asNumber() float64 {
+	fatalError("range '%s' can not evaluate as a number", r.ref())
+	return 0
+}
+
+func (r *Range) asString() string {
+	fatalError("range '%s' can not evaluate as a string", r.ref())
+	return ""
+}
+
+func (r *Range) isTruthy() bool {
+	fatalError("range '%s' can not evaluate as a truthy", r.ref())
+	return false
+}
+
+func (r *Range) nodeType() int {
+	return NodeTypeRange
+}
+
+func (r *Range) String() string {
+	return fmt.Sprintf("[Type: Range] %s!%s", r.sheet, r.ref())
+}
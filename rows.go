@@ -0,0 +1,144 @@
+package main
+
+import (
+	"github.com/360EntSecGroup-Skylar/excelize/v2"
+)
+
+// excelize reads the whole sheet to count the rows and the columns, so the dimension of a sheet is cached.
+// The cache grows with the cells written, and is dropped when the structure of the sheet is changed.
+// The -N loop reads the rows by the cursor which streams the rows of the sheet instead of searching every cell.
+
+// sheetDimension is the number of the rows and the columns of a sheet.
+type sheetDimension struct {
+	rows int
+	cols int
+}
+
+// rowCursor streams the rows of a sheet and holds the values of the current row.
+type rowCursor struct {
+	sheet  string
+	rows   *excelize.Rows
+	row    int
+	values []string
+	// stale is true if the current row is written after it was read
+	stale bool
+	// merged is true if the sheet has the merged cells, which are read from the top left cell
+	merged bool
+}
+
+// getSheetDimension returns the dimension of the sheet, which is read only if it is not cached.
+func (s *Spreadsheet) getSheetDimension(sheet string) *sheetDimension {
+	if st := s.streams[sheet]; st != nil {
+		return &sheetDimension{rows: st.row, cols: st.cols}
+	}
+	if d, ok := s.dims[sheet]; ok {
+		return d
+	}
+	rows, err := s.file.Rows(sheet)
+	if err != nil {
+		fatalError("rows count error. %v", err)
+	}
+	d := &sheetDimension{}
+	for rows.Next() {
+		values, err := rows.Columns()
+		if err != nil {
+			break
+		}
+		d.rows++
+		if d.cols < len(values) {
+			d.cols = len(values)
+		}
+	}
+	if s.dims == nil {
+		s.dims = map[string]*sheetDimension{}
+	}
+	s.dims[sheet] = d
+	return d
+}
+
+// touchSheetCell updates the cache of the sheet for the cell written.
+func (s *Spreadsheet) touchSheetCell(sheet string, axis string) {
+	col, row, err := excelize.CellNameToCoordinates(axis)
+	if err != nil {
+		s.invalidateSheet(sheet)
+		return
+	}
+	if d, ok := s.dims[sheet]; ok {
+		if d.rows < row {
+			d.rows = row
+		}
+		if d.cols < col {
+			d.cols = col
+		}
+	}
+	if c := s.cursor; c != nil && c.sheet == sheet {
+		if c.row < row {
+			// the rows after the current row are not read yet
+			s.cursor = nil
+		} else if c.row == row {
+			c.stale = true
+		}
+	}
+}
+
+// invalidateSheet drops the cache of the sheet whose structure is changed.
+func (s *Spreadsheet) invalidateSheet(sheet string) {
+	delete(s.dims, sheet)
+	if s.cursor != nil && s.cursor.sheet == sheet {
+		s.cursor = nil
+	}
+}
+
+// invalidateSheets drops the cache of all sheets when the sheets are renamed, added or deleted.
+func (s *Spreadsheet) invalidateSheets() {
+	s.dims = nil
+	s.cursor = nil
+}
+
+// seekSheetRow moves the cursor of the sheet forward to the row.
+// The cursor is recreated if it is of the other sheet or after the row.
+func (s *Spreadsheet) seekSheetRow(sheet string, row int) {
+	c := s.cursor
+	if c == nil || c.sheet != sheet || row < c.row {
+		rows, err := s.file.Rows(sheet)
+		if err != nil {
+			fatalError("sheet '%s' refer failed", sheet)
+		}
+		c = &rowCursor{sheet: sheet, rows: rows, merged: 0 < len(s.getSheetMergedRanges(sheet))}
+		s.cursor = c
+	}
+	for c.row < row {
+		c.row++
+		c.values = nil
+		c.stale = false
+		if !c.rows.Next() {
+			continue
+		}
+		values, err := c.rows.Columns()
+		if err != nil {
+			c.stale = true
+		}
+		c.values = values
+	}
+}
+
+// closeRowCursor releases the cursor.
+func (s *Spreadsheet) closeRowCursor() {
+	s.cursor = nil
+}
+
+// getCursorValue returns the value of the cell if the cell is in the current row of the cursor.
+func (s *Spreadsheet) getCursorValue(sheet string, axis string) (string, bool) {
+	c := s.cursor
+	if c == nil || c.sheet != sheet || c.stale || c.merged {
+		return "", false
+	}
+	col, row, err := excelize.CellNameToCoordinates(axis)
+	if err != nil || row != c.row {
+		return "", false
+	}
+	if col <= len(c.values) {
+		return c.values[col-1], true
+	}
+	return "", true
+}
@@ -8,22 +8,40 @@ import (
 
 type Scope struct {
 	vars   map[string]Node
+	refs   map[string]*varRef
 	parent *Scope
 }
 
+// varRef points to a variable of the caller.
+// It is used to create the caller's array when an unset variable is passed to a function.
+type varRef struct {
+	scope *Scope
+	name  string
+}
+
 func NewScope() *Scope {
 	s := &Scope{}
 	s.vars = make(map[string]Node)
+	s.refs = make(map[string]*varRef)
 	return s
 }
 
 func AppendScope(s *Scope) *Scope {
 	ns := &Scope{parent: s}
 	ns.vars = make(map[string]Node)
+	ns.refs = make(map[string]*varRef)
 	return ns
 }
 
 func (s *Scope) set(name string, value Node) Node {
+	// arrays are neither copied nor replaced by assignment
+	if value.nodeType() == NodeTypeArray {
+		fatalError("array can not be assigned to '%s'", name)
+	}
+	if v, ok := s.vars[name]; ok && v.nodeType() == NodeTypeArray {
+		fatalError("array '%s' can not be assigned a scalar", name)
+	}
+
 	if s.isSpecialVar(name) {
 		return s.setSpecialVar(name, value)
 	}
@@ -55,10 +73,70 @@ func (s *Scope) getVar(name string) Node {
 	return v
 }
 
+// findVar returns the variable value and the scope which has it.
+// If the variable is not found, the returned scope is nil.
+func (s *Scope) findVar(name string) (Node, *Scope) {
+	for sc := s; sc != nil; sc = sc.parent {
+		if v, ok := sc.vars[name]; ok {
+			return v, sc
+		}
+	}
+	return nil, nil
+}
+
+// isUnset reports whether the variable is never assigned or has an empty string.
+func (s *Scope) isUnset(name string) bool {
+	v, sc := s.findVar(name)
+	if sc == nil {
+		return true
+	}
+	if v.nodeType() == NodeTypeArray {
+		return false
+	}
+	return v.asString() == ""
+}
+
+// bindRef binds the variable name to the caller's variable refName in scope caller.
+func (s *Scope) bindRef(name string, caller *Scope, refName string) {
+	s.refs[name] = &varRef{scope: caller, name: refName}
+	s.setVar(name, NewStringExpression(""))
+}
+
+// getArray returns the array named name.
+// If the variable is unset, a new array is created.
+func (s *Scope) getArray(name string) *Array {
+	if s.isSpecialVar(name) {
+		fatalError("special var '%s' can not be used as an array", name)
+	}
+
+	v, sc := s.findVar(name)
+	if sc != nil {
+		if a, ok := v.(*Array); ok {
+			return a
+		}
+		if v.asString() != "" {
+			fatalError("'%s' is not an array", name)
+		}
+	} else {
+		sc = s
+	}
+
+	var a *Array
+	if ref, ok := sc.refs[name]; ok {
+		a = ref.scope.getArray(ref.name)
+	} else {
+		a = NewArray()
+	}
+	sc.setVar(name, a)
+	return a
+}
+
 func (s *Scope) isSpecialVar(name string) bool {
 	switch name {
 	case "@":
 		return true
+	case "@@":
+		return true
 	case "LR":
 		return true
 	case "LC":
@@ -77,6 +155,16 @@ func (s *Scope) isSpecialVar(name string) bool {
 		return true
 	case "NR":
 		return true
+	case "FNR":
+		return true
+	case "FILENAME":
+		return true
+	case "FILENUM":
+		return true
+	case "CONVFMT":
+		return true
+	case "OFMT":
+		return true
 	}
 	if name[0] == '$' {
 		return true
@@ -89,6 +177,8 @@ func (s *Scope) getSpecialVar(name string) Node {
 	case "@":
 		s := execContext.spreadsheet.getActiveSheetName()
 		return NewStringExpression(s)
+	case "@@":
+		return NewStringExpression(execContext.bookName)
 	case "LR":
 		// return active sheet Last Row index(start by 1)
 		n := execContext.spreadsheet.getRowsCount()
@@ -131,6 +221,18 @@ func (s *Scope) setSpecialVar(name string, value Node) Node {
 			}
 		}
 		return NewStringExpression(s)
+	case "@@":
+		s := value.eval().asString()
+		if err := switchBook(s); err != nil {
+			fatalError("%v", err)
+		}
+		return NewStringExpression(s)
+	case "CONVFMT":
+		execContext.convfmt = value.eval().asString()
+		return s.setGlobalVar(name, value)
+	case "OFMT":
+		execContext.ofmt = value.eval().asString()
+		return s.setGlobalVar(name, value)
 	case "LR":
 		fallthrough
 	case "LC":
@@ -138,20 +240,27 @@ func (s *Scope) setSpecialVar(name string, value Node) Node {
 	case "LCC":
 		fatalError("special vars 'LR, LC, LCC' are readonly")
 	default:
-		if s.parent != nil {
-			s.parent.set(name, value)
-		} else {
-			s.setVar(name, value)
-		}
-		return value
+		return s.setGlobalVar(name, value)
 	}
 	panic("assign to unknown special var")
 }
 
+// setGlobalVar sets the variable in the top level scope.
+func (s *Scope) setGlobalVar(name string, value Node) Node {
+	if s.parent != nil {
+		return s.parent.setGlobalVar(name, value)
+	}
+	return s.setVar(name, value)
+}
+
 func (s *Scope) setDollarSpecialVars(input string) {
 	fs := execContext.scope.get("FS").asString()
 	reg := s.makeFSSplitReg(fs)
-	a := reg.Split(input, -1)
+	s.setDollarFields(input, reg.Split(input, -1))
+}
+
+// setDollarFields sets the record to $0 and the fields to $1, $2... and NF.
+func (s *Scope) setDollarFields(input string, a []string) {
 	execContext.scope.set("$0", NewStringExpression(input))
 
 	if len(a) > math.MaxUint16 {
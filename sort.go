@@ -0,0 +1,233 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/360EntSecGroup-Skylar/excelize/v2"
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+// sortKey is a key column to sort the rows of a range like "C desc num".
+type sortKey struct {
+	col  int
+	desc bool
+	// compare is "auto", "num", "str" or "locale"
+	compare  string
+	collator *collate.Collator
+}
+
+// parseSortKeys parses the keys like "C", "C desc", "A num" and "B locale=ja".
+// The key "header" is reported as header instead of a key.
+func parseSortKeys(texts []string) (keys []*sortKey, header bool, err error) {
+	for _, text := range texts {
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			return nil, false, fmt.Errorf("empty sort key")
+		}
+		if len(fields) == 1 && strings.EqualFold(fields[0], "header") {
+			header = true
+			continue
+		}
+
+		col, err := excelize.ColumnNameToNumber(strings.ToUpper(fields[0]))
+		if err != nil {
+			return nil, false, fmt.Errorf("'%s' is invalid column of sort key", fields[0])
+		}
+		key := &sortKey{col: col, compare: "auto"}
+		for _, f := range fields[1:] {
+			name, value := strings.ToLower(f), ""
+			if i := strings.Index(f, "="); 0 <= i {
+				name, value = strings.ToLower(f[:i]), f[i+1:]
+			}
+			switch name {
+			case "asc":
+				key.desc = false
+			case "desc":
+				key.desc = true
+			case "auto", "num", "str":
+				key.compare = name
+			case "locale":
+				key.compare = name
+				key.collator = collate.New(sortLocale(value), collate.IgnoreCase)
+			default:
+				return nil, false, fmt.Errorf("'%s' is invalid option of sort key '%s'", f, text)
+			}
+		}
+		keys = append(keys, key)
+	}
+	return keys, header, nil
+}
+
+// sortLocale returns the language of the locale name like "ja" or "ja_JP.UTF-8".
+// The locale of the environment is used if the name is empty.
+func sortLocale(name string) language.Tag {
+	if name == "" {
+		for _, env := range []string{"LC_ALL", "LC_COLLATE", "LANG"} {
+			if name = os.Getenv(env); name != "" {
+				break
+			}
+		}
+	}
+	if i := strings.IndexAny(name, ".@"); 0 <= i {
+		name = name[:i]
+	}
+	tag, err := language.Parse(strings.ReplaceAll(name, "_", "-"))
+	if err != nil {
+		return language.Und
+	}
+	return tag
+}
+
+// compareSortValues compares the cell values a and b by the key.
+// Empty cells are always placed at the end like Excel.
+func (key *sortKey) compareSortValues(a string, b string) int {
+	if a == "" || b == "" {
+		switch {
+		case a == b:
+			return 0
+		case a == "":
+			return 1
+		default:
+			return -1
+		}
+	}
+
+	c := 0
+	switch key.compare {
+	case "num":
+		x, _ := maybeNumber(a)
+		y, _ := maybeNumber(b)
+		c = compareNumbers(x, y)
+	case "str":
+		c = strings.Compare(a, b)
+	case "locale":
+		c = key.collator.CompareString(a, b)
+	default:
+		// numbers are placed before strings, and strings are compared ignoring case like Excel
+		x, xok := maybeNumber(a)
+		y, yok := maybeNumber(b)
+		switch {
+		case xok && yok:
+			c = compareNumbers(x, y)
+		case xok:
+			c = -1
+		case yok:
+			c = 1
+		default:
+			c = strings.Compare(strings.ToLower(a), strings.ToLower(b))
+		}
+	}
+	if key.desc {
+		return -c
+	}
+	return c
+}
+
+func compareNumbers(x float64, y float64) int {
+	switch {
+	case x < y:
+		return -1
+	case y < x:
+		return 1
+	}
+	return 0
+}
+
+// sortSheetRange sorts the rows of the range by the keys stably.
+// The values, the formulas and the styles are moved with the rows. The first row stays if header is true.
+func (s *Spreadsheet) sortSheetRange(r *Range, keys []*sortKey, header bool) {
+	for _, key := range keys {
+		if key.col < r.startCol || r.endCol < key.col {
+			name, _ := excelize.ColumnNumberToName(key.col)
+			fatalError("sort key '%s' is out of range '%s'", name, r.ref())
+		}
+	}
+	if len(keys) == 0 {
+		keys = []*sortKey{{col: r.startCol, compare: "auto"}}
+	}
+
+	type cell struct {
+		value   string
+		formula string
+		style   int
+	}
+	type row struct {
+		y     int
+		cells []cell
+	}
+	first := 0
+	if header {
+		first = 1
+	}
+	rows := make([]row, 0, r.height())
+	for y := first; y < r.height(); y++ {
+		cells := make([]cell, r.width())
+		for x := range cells {
+			axis := r.axis(x, y)
+			cells[x] = cell{
+				value:   s.getSheetCellRawValue(r.sheet, axis),
+				formula: s.getSheetCellFormula(r.sheet, axis),
+				style:   s.getSheetCellStyle(r.sheet, axis),
+			}
+		}
+		rows = append(rows, row{y: y, cells: cells})
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		for _, key := range keys {
+			x := key.col - r.startCol
+			if c := key.compareSortValues(rows[i].cells[x].value, rows[j].cells[x].value); c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+
+	for i, row := range rows {
+		y := first + i
+		if row.y == y {
+			continue
+		}
+		for x, c := range row.cells {
+			axis := r.axis(x, y)
+			s.clearSheetCell(r.sheet, axis)
+			if c.formula != "" {
+				// the relative references are moved with the row like Excel
+				s.setSheetCellFormula(r.sheet, axis, shiftFormula(c.formula, 0, y-row.y))
+			}
+			if c.value != "" {
+				s.setSheetCellRawValue(r.sheet, axis, c.value)
+			}
+			s.setSheetCellStyle(r.sheet, axis, c.style)
+		}
+	}
+
+	// the comments and the defined names of the cells in a row are moved with the row
+	moved := map[int]int{}
+	for i, row := range rows {
+		if row.y != first+i {
+			moved[r.startRow+row.y] = r.startRow + first + i
+		}
+	}
+	s.moveSheetComments(r.sheet, func(col int, row int) (int, int, bool) {
+		if to, ok := moved[row]; ok && r.startCol <= col && col <= r.endCol {
+			return col, to, true
+		}
+		return col, row, true
+	})
+	s.adjustDefinedNames(func(_ string, refersTo string) string {
+		return mapFormulaRefs(refersTo, func(sheet string, ref *formulaRef) bool {
+			if !strings.EqualFold(sheet, r.sheet) || ref.row[0] != ref.row[1] || ref.col[0] < r.startCol || r.endCol < ref.col[1] {
+				return true
+			}
+			if to, ok := moved[ref.row[0]]; ok {
+				ref.row[0], ref.row[1] = to, to
+			}
+			return true
+		})
+	})
+}
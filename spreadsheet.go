@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/360EntSecGroup-Skylar/excelize/v2"
@@ -11,6 +12,16 @@ type Spreadsheet struct {
 	file        *excelize.File
 	topath      string
 	activeSheet string
+	// styles caches the style IDs created from the other styles
+	styles map[string]int
+	// tables caches the tables in the workbook, nil if not read yet
+	tables []*tableInfo
+	// dims caches the dimensions of the sheets
+	dims map[string]*sheetDimension
+	// cursor streams the rows of the sheet in the -N loop, nil if not used
+	cursor *rowCursor
+	// streams writes the rows of the sheets in the stream mode, nil if not streaming
+	streams map[string]*sheetStream
 }
 
 func NewSpreadsheet(frompath string, topath string) (*Spreadsheet, error) {
@@ -55,7 +66,18 @@ func (s *Spreadsheet) writeSpreadsheet() error {
 		return fmt.Errorf("on error spreadsheet writing: no specify write path.")
 	}
 
-	err := s.file.SaveAs(s.topath)
+	return s.saveSpreadsheet(s.topath)
+}
+
+// saveSpreadsheet saves the spreadsheet to the path.
+func (s *Spreadsheet) saveSpreadsheet(path string) error {
+	s.closeStreams()
+	// request full calculate to excel
+	if s.file.WorkBook.CalcPr != nil {
+		s.file.WorkBook.CalcPr.FullCalcOnLoad = true
+	}
+
+	err := s.file.SaveAs(path)
 	if err != nil {
 		return fmt.Errorf("on error spreadsheet writing. '%v'", err)
 	}
@@ -64,18 +86,201 @@ func (s *Spreadsheet) writeSpreadsheet() error {
 }
 
 func (s *Spreadsheet) getCellValue(axis string) string {
-	v, err := s.file.GetCellValue(s.activeSheet, axis)
+	return s.getSheetCellValue(s.activeSheet, axis)
+}
+
+func (s *Spreadsheet) setCellValue(axis string, v interface{}) {
+	s.setSheetCellValue(s.activeSheet, axis, v)
+}
+
+func (s *Spreadsheet) getSheetCellValue(sheet string, axis string) string {
+	if v, ok := s.getCursorValue(sheet, axis); ok {
+		return v
+	}
+	if c, ok := s.streamedCell(sheet, axis); ok {
+		if c == nil {
+			return ""
+		}
+		return streamValueString(c.Value)
+	}
+	v, err := s.file.GetCellValue(sheet, axis)
 	if err != nil {
 		fatalError("cell '%s' refer failed", axis)
 	}
 	return v
 }
 
-func (s *Spreadsheet) setCellValue(axis string, v interface{}) {
-	err := s.file.SetCellValue(s.activeSheet, axis, v)
+func (s *Spreadsheet) setSheetCellValue(sheet string, axis string, v interface{}) {
+	if s.isStreaming() {
+		s.streamCell(sheet, axis).Value = v
+		return
+	}
+	err := s.file.SetCellValue(sheet, axis, v)
 	if err != nil {
 		fatalError("cell '%s' set value failed", axis)
 	}
+	s.touchSheetCell(sheet, axis)
+}
+
+// getSheetCellRawValue returns the cell value which is not formatted by the number format.
+func (s *Spreadsheet) getSheetCellRawValue(sheet string, axis string) string {
+	if _, ok := s.streamedCell(sheet, axis); ok {
+		return s.getSheetCellValue(sheet, axis)
+	}
+	style, err := s.file.GetCellStyle(sheet, axis)
+	if err != nil {
+		fatalError("cell '%s' refer failed", axis)
+	}
+	if style == 0 {
+		return s.getSheetCellValue(sheet, axis)
+	}
+
+	// GetCellValue always applies the number format of the style
+	s.setSheetCellStyle(sheet, axis, 0)
+	v := s.getSheetCellValue(sheet, axis)
+	s.setSheetCellStyle(sheet, axis, style)
+	return v
+}
+
+func (s *Spreadsheet) setSheetCellStyle(sheet string, axis string, style int) {
+	if s.isStreaming() {
+		s.streamCell(sheet, axis).StyleID = style
+		return
+	}
+	if err := s.file.SetCellStyle(sheet, axis, axis, style); err != nil {
+		fatalError("cell '%s' set style failed", axis)
+	}
+	s.touchSheetCell(sheet, axis)
+}
+
+// setSheetCellDate sets the epoch seconds to the cell as an Excel date with the number format.
+// If format is empty, the short date format or the date time format is used.
+func (s *Spreadsheet) setSheetCellDate(sheet string, axis string, t float64, format string) {
+	v := epochToExcelTime(t)
+	s.setSheetCellValue(sheet, axis, v)
+
+	numFmt := 22
+	if v.Hour() == 0 && v.Minute() == 0 && v.Second() == 0 {
+		numFmt = 14
+	}
+	key := fmt.Sprintf("#%d", numFmt)
+	if format != "" {
+		key = "numfmt=" + quoteStyleValue(format)
+	}
+	base := s.getSheetCellStyle(sheet, axis)
+	id := s.derivedStyle(base, key, func(st *excelize.Style) {
+		if format != "" {
+			st.NumFmt, st.CustomNumFmt = 0, &format
+		} else {
+			st.NumFmt, st.CustomNumFmt = numFmt, nil
+		}
+	})
+	s.setSheetCellStyle(sheet, axis, id)
+}
+
+// getSheetCellFormula returns the formula of the cell without the leading '='.
+func (s *Spreadsheet) getSheetCellFormula(sheet string, axis string) string {
+	f, err := s.file.GetCellFormula(sheet, axis)
+	if err != nil {
+		fatalError("cell '%s' refer failed", axis)
+	}
+	return f
+}
+
+func (s *Spreadsheet) setSheetCellFormula(sheet string, axis string, formula string) {
+	if s.isStreaming() {
+		if formula != "" {
+			fatalError("formula is not supported in stream mode")
+		}
+		return
+	}
+	if err := s.file.SetCellFormula(sheet, axis, formula); err != nil {
+		fatalError("cell '%s' set formula failed", axis)
+	}
+	s.touchSheetCell(sheet, axis)
+}
+
+// getSheetFormulaAxes returns the cells which have a formula in the sheet.
+func (s *Spreadsheet) getSheetFormulaAxes(sheet string) []string {
+	rows := s.getSheetRowsCount(sheet)
+	if rows == 0 {
+		return nil
+	}
+	var axes []string
+	for _, axis := range s.getSheetRange(sheet, "1:"+strconv.Itoa(rows)).axes() {
+		if s.getSheetCellFormula(sheet, axis) != "" {
+			axes = append(axes, axis)
+		}
+	}
+	return axes
+}
+
+// adjustFormulas replaces every formula and the reference of every defined name in the workbook with the result of fn.
+func (s *Spreadsheet) adjustFormulas(fn func(sheet string, formula string) string) {
+	for _, sheet := range s.getSheetList() {
+		for _, axis := range s.getSheetFormulaAxes(sheet) {
+			f := s.getSheetCellFormula(sheet, axis)
+			if g := fn(sheet, f); g != f {
+				s.setSheetCellFormula(sheet, axis, g)
+			}
+		}
+	}
+	s.adjustDefinedNames(fn)
+}
+
+// isDate1904 reports whether the workbook uses the 1904 date system.
+func (s *Spreadsheet) isDate1904() bool {
+	return s.file.WorkBook.WorkbookPr != nil && s.file.WorkBook.WorkbookPr.Date1904
+}
+
+// getRange returns the range of the active sheet.
+func (s *Spreadsheet) getRange(ref string) *Range {
+	return s.getSheetRange(s.activeSheet, ref)
+}
+
+// getSheetRange returns the range of the sheet.
+func (s *Spreadsheet) getSheetRange(sheet string, ref string) *Range {
+	sc, sr, ec, er, err := parseRangeRef(ref, s.getSheetColsCount(sheet), s.getSheetRowsCount(sheet))
+	if err != nil {
+		fatalError("%v", err)
+	}
+	return &Range{book: s, sheet: sheet, startCol: sc, startRow: sr, endCol: ec, endRow: er}
+}
+
+// getRangeValues returns the cell values in the range as rows.
+func (s *Spreadsheet) getRangeValues(r *Range) [][]string {
+	rows := make([][]string, r.height())
+	for y := 0; y < r.height(); y++ {
+		rows[y] = make([]string, r.width())
+		for x := 0; x < r.width(); x++ {
+			rows[y][x] = s.getSheetCellValue(r.sheet, r.axis(x, y))
+		}
+	}
+	return rows
+}
+
+// fillRange sets the value to every cell in the range.
+func (s *Spreadsheet) fillRange(r *Range, v interface{}) {
+	for _, axis := range r.axes() {
+		s.setSheetCellValue(r.sheet, axis, v)
+	}
+}
+
+// typedCellValue returns the value as a number if it can be interpreted as a number.
+func typedCellValue(v string) interface{} {
+	if f, ok := maybeNumber(v); ok {
+		return f
+	}
+	return v
+}
+
+// cellValueOf returns the value to set to a cell.
+// Numbers are set as they are to keep the precision.
+func cellValueOf(v Node) interface{} {
+	if e, ok := v.(*Expression); ok && e.exprType == NumberExpression {
+		return e.number
+	}
+	return typedCellValue(v.asString())
 }
 
 func (s *Spreadsheet) getActiveSheetName() string {
@@ -101,6 +306,7 @@ func (s *Spreadsheet) getSheetList() []string {
 
 func (s *Spreadsheet) addSheet(name string) error {
 	s.file.NewSheet(name)
+	s.invalidateSheets()
 	return s.setActiveSheetByName(name)
 }
 
@@ -168,15 +374,15 @@ func (s *Spreadsheet) setTailSheet() string {
 }
 
 func (s *Spreadsheet) getColsCount() int {
-	current := s.file.GetSheetIndex(s.activeSheet)
+	return s.getSheetColsCount(s.activeSheet)
+}
+
+func (s *Spreadsheet) getSheetColsCount(sheet string) int {
+	current := s.file.GetSheetIndex(sheet)
 	if current < 0 {
 		fatalError("current worksheet not found in getColsCount()")
 	}
-	cols, err := s.file.GetCols(s.activeSheet)
-	if err != nil {
-		fatalError("cols count error. %v", err)
-	}
-	return len(cols)
+	return s.getSheetDimension(sheet).cols
 }
 
 func (s *Spreadsheet) getAlphaColsCount() string {
@@ -189,15 +395,15 @@ func (s *Spreadsheet) getAlphaColsCount() string {
 }
 
 func (s *Spreadsheet) getRowsCount() int {
-	current := s.file.GetSheetIndex(s.activeSheet)
+	return s.getSheetRowsCount(s.activeSheet)
+}
+
+func (s *Spreadsheet) getSheetRowsCount(sheet string) int {
+	current := s.file.GetSheetIndex(sheet)
 	if current < 0 {
 		fatalError("current worksheet not found in getRowsCount()")
 	}
-	rows, err := s.file.GetRows(s.activeSheet)
-	if err != nil {
-		fatalError("rows count error. %v", err)
-	}
-	return len(rows)
+	return s.getSheetDimension(sheet).rows
 }
 
 func columnNameToNumber(name string) (int, error) {
@@ -244,7 +450,13 @@ func (s *Spreadsheet) setSheetName(oldName string, newName string) string {
 		return ""
 	}
 
+	if st, ok := s.streams[oldName]; ok {
+		delete(s.streams, oldName)
+		s.streams[newName] = st
+	}
 	s.file.SetSheetName(oldName, newName)
+	s.tables = nil
+	s.invalidateSheets()
 	return newName
 }
 
@@ -259,7 +471,10 @@ func (s *Spreadsheet) deleteSheet(name string) bool {
 	if s.countSheet() <= 1 {
 		return false
 	}
+	s.checkNotStreaming("deleting sheet")
 	s.file.DeleteSheet(name)
+	s.tables = nil
+	s.invalidateSheets()
 	return true
 }
 
@@ -272,11 +487,14 @@ func (s *Spreadsheet) copySheet(from string, to string) bool {
 		return false
 	}
 
+	s.checkNotStreaming("copying sheet")
 	fromidx := s.file.GetSheetIndex(from)
 	toidx := s.file.NewSheet(to)
 	if err := s.file.CopySheet(fromidx, toidx); err != nil {
 		return false
 	}
+	s.tables = nil
+	s.invalidateSheets()
 	return true
 }
 
@@ -1,6 +1,12 @@
 package main
 
-import "testing"
+import (
+	"bufio"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
 
 func TestOpenFileSpecifiedFromPath(t *testing.T) {
 	sheet, err := NewSpreadsheet("test/empty.xlsx", "")
@@ -453,3 +459,626 @@ func TestCopySheet(t *testing.T) {
 		t.Fatalf("copySheet() could not copy value in sheet")
 	}
 }
+
+func TestParseRangeRef(t *testing.T) {
+	tests := []struct {
+		ref  string
+		want [4]int
+	}{
+		{"A1:C10", [4]int{1, 1, 3, 10}},
+		{"C10:A1", [4]int{1, 1, 3, 10}},
+		{"B:B", [4]int{2, 1, 2, 20}},
+		{"3:4", [4]int{1, 3, 5, 4}},
+	}
+	for _, tt := range tests {
+		sc, sr, ec, er, err := parseRangeRef(tt.ref, 5, 20)
+		if err != nil {
+			t.Fatalf("parse range '%s' error: %v", tt.ref, err)
+		}
+		got := [4]int{sc, sr, ec, er}
+		if got != tt.want {
+			t.Fatalf("range '%s' want %v, but got %v", tt.ref, tt.want, got)
+		}
+	}
+
+	if _, _, _, _, err := parseRangeRef("A1:foo", 5, 20); err == nil {
+		t.Fatal("No error occurred even though the range is invalid.")
+	}
+}
+
+func TestGetRangeValues(t *testing.T) {
+	sheet, _ := NewSpreadsheet("test/list.xlsx", "")
+	r := sheet.getRange("A1:A3")
+	v := sheet.getRangeValues(r)
+
+	if len(v) != 3 || v[0][0] != "A" || v[1][0] != "B" || v[2][0] != "C" {
+		t.Fatalf("range values want [[A] [B] [C]], but got %v", v)
+	}
+}
+
+func TestSheetNameQuote(t *testing.T) {
+	tests := []struct {
+		name   string
+		quoted string
+	}{
+		{"Sheet1", "Sheet1"},
+		{"My Sheet", "'My Sheet'"},
+		{"Bob's", "'Bob''s'"},
+	}
+	for _, tt := range tests {
+		q := quoteSheetName(tt.name)
+		if q != tt.quoted {
+			t.Fatalf("quote '%s' want %s, but got %s", tt.name, tt.quoted, q)
+		}
+		if n := unquoteSheetName(q); n != tt.name {
+			t.Fatalf("unquote '%s' want %s, but got %s", q, tt.name, n)
+		}
+	}
+
+	if i := indexUnquoted("'a!b'!A1", '!'); i != 5 {
+		t.Fatalf("index of '!' want 5, but got %d", i)
+	}
+}
+
+func TestSplitNamedPath(t *testing.T) {
+	tests := []struct {
+		v    string
+		name string
+		path string
+		ok   bool
+	}{
+		{"sales=test/list.xlsx", "sales", "test/list.xlsx", true},
+		{"test/list.xlsx", "", "test/list.xlsx", false},
+		{"dir/a=b.xlsx", "", "dir/a=b.xlsx", false},
+	}
+	for _, tt := range tests {
+		name, path, ok := splitNamedPath(tt.v)
+		if name != tt.name || path != tt.path || ok != tt.ok {
+			t.Fatalf("split '%s' want (%s, %s, %v), but got (%s, %s, %v)", tt.v, tt.name, tt.path, tt.ok, name, path, ok)
+		}
+	}
+}
+
+func TestStrftimeAndStrptime(t *testing.T) {
+	tm := time.Date(2024, 3, 5, 7, 8, 9, 0, time.Local)
+	s := strftime("%Y%m%d %T %%", tm)
+	if s != "20240305 07:08:09 %" {
+		t.Fatalf("strftime want '%s', but got '%s'", "20240305 07:08:09 %", s)
+	}
+
+	p, err := strptime("20240305 07:08:09 %", "%Y%m%d %T %%")
+	if err != nil || !p.Equal(tm) {
+		t.Fatalf("strptime want '%v', but got '%v'(%v)", tm, p, err)
+	}
+}
+
+func TestSerialToEpoch(t *testing.T) {
+	e, err := serialToEpoch(45351.5, false)
+	if err != nil {
+		t.Fatalf("serialToEpoch error: %v", err)
+	}
+	want := time.Date(2024, 2, 29, 12, 0, 0, 0, time.Local)
+	if !epochToTime(e).Equal(want) {
+		t.Fatalf("serialToEpoch want '%v', but got '%v'", want, epochToTime(e))
+	}
+}
+
+func TestShiftFormula(t *testing.T) {
+	tests := []struct {
+		formula    string
+		cols, rows int
+		want       string
+	}{
+		{"SUM(A1:B1)", 0, 1, "SUM(A2:B2)"},
+		{"A1+$A$1+A$1+$A1", 1, 1, "B2+$A$1+B$1+$A2"},
+		{"LOG10(A1)&\"A1\"", 0, 2, "LOG10(A3)&\"A1\""},
+		{"'Sheet A1'!A1+Sheet1!B2", 1, 0, "'Sheet A1'!B1+Sheet1!C2"},
+		{"SUM(A:A)+SUM(1:1)", 1, 1, "SUM(B:B)+SUM(2:2)"},
+		{"A1+B2", 0, -1, "#REF!+B1"},
+	}
+	for _, tt := range tests {
+		got := shiftFormula(tt.formula, tt.cols, tt.rows)
+		if got != tt.want {
+			t.Fatalf("shift '%s' by (%d, %d) want '%s', but got '%s'", tt.formula, tt.cols, tt.rows, tt.want, got)
+		}
+	}
+}
+
+func TestAdjustFormula(t *testing.T) {
+	tests := []struct {
+		formula     string
+		rows        bool
+		num, offset int
+		want        string
+	}{
+		{"SUM(A1:A5)+$A$4", true, 3, 2, "SUM(A1:A7)+$A$6"},
+		{"SUM(A1:A5)+A3", true, 3, -1, "SUM(A1:A4)+#REF!"},
+		{"SUM(A3:A4)+A5", true, 3, -2, "SUM(#REF!)+A3"},
+		{"SUM(B:D)+SUM(2:2)+C1", false, 2, -1, "SUM(B:C)+SUM(2:2)+B1"},
+		{"Sheet1!A2+Sheet2!A2+'Sheet1'!A2", true, 1, 1, "Sheet1!A3+Sheet2!A2+'Sheet1'!A3"},
+		{"\"A2\"&A2", true, 1, 1, "\"A2\"&A3"},
+	}
+	for _, tt := range tests {
+		got := adjustFormula(tt.formula, "Sheet1", "Sheet1", tt.rows, tt.num, tt.offset)
+		if got != tt.want {
+			t.Fatalf("adjust '%s' at %d by %d want '%s', but got '%s'", tt.formula, tt.num, tt.offset, tt.want, got)
+		}
+	}
+}
+
+func TestCompareSortValues(t *testing.T) {
+	tests := []struct {
+		key  string
+		a, b string
+		want int
+	}{
+		{"A", "10", "9", 1},
+		{"A", "9", "apple", -1},
+		{"A", "Apple", "banana", -1},
+		{"A", "", "apple", 1},
+		{"A desc", "", "apple", 1},
+		{"A desc", "10", "9", -1},
+		{"A str", "10", "9", -1},
+		{"A num", "abc", "1", -1},
+		{"A locale=fr", "émile", "eve", -1},
+		{"A str", "émile", "eve", 1},
+	}
+	for _, tt := range tests {
+		keys, _, err := parseSortKeys([]string{tt.key})
+		if err != nil {
+			t.Fatalf("parse sort key '%s' failed: %v", tt.key, err)
+		}
+		if got := keys[0].compareSortValues(tt.a, tt.b); got != tt.want {
+			t.Fatalf("compare '%s' and '%s' by '%s' want %d, but got %d", tt.a, tt.b, tt.key, tt.want, got)
+		}
+	}
+}
+
+func TestResolveTableRef(t *testing.T) {
+	sheet, _ := NewSpreadsheet("", "")
+	sheet.tables = []*tableInfo{
+		{name: "Sales", sheet: "Sheet1", ref: "B2:E10", columns: []string{"Region", "Qty", "#No", "Amount"}, header: true, totals: true},
+	}
+
+	tests := []struct {
+		ref  string
+		want string
+	}{
+		{"Sales", "B3:E9"},
+		{"sales[Amount]", "E3:E9"},
+		{"Sales[#All]", "B2:E10"},
+		{"Sales[#Totals]", "B10:E10"},
+		{"Sales[[#Headers],[#Data],[Qty]]", "C2:C9"},
+		{"Sales[[Qty]:[Amount]]", "C3:E9"},
+		{"Sales['#No]", "D3:D9"},
+	}
+	for _, tt := range tests {
+		_, got, ok, err := sheet.resolveTableRef(tt.ref)
+		if !ok || err != nil || got != tt.want {
+			t.Fatalf("resolve '%s' want '%s', but got '%s' (%v)", tt.ref, tt.want, got, err)
+		}
+	}
+	if _, _, ok, _ := sheet.resolveTableRef("Orders[Qty]"); ok {
+		t.Fatal("resolve unknown table want not ok, but ok")
+	}
+}
+
+func TestParseFilterCriteria(t *testing.T) {
+	col, expr, conds, err := parseFilterCriteria(`b >= 10 and B != "n/a"`)
+	if err != nil || col != "B" || expr != `x >= 10 and x != "n/a"` {
+		t.Fatalf("parse criteria got '%s' '%s' (%v)", col, expr, err)
+	}
+	for v, want := range map[string]bool{"10": true, "9": false, "n/a": false, "20": true} {
+		if conds.match(v) != want {
+			t.Fatalf("match '%s' want %v, but got %v", v, want, !want)
+		}
+	}
+	if _, _, _, err := parseFilterCriteria("B > 1 and C < 2"); err == nil {
+		t.Fatal("parse criteria of two columns want error, but nil")
+	}
+}
+
+func TestParseCondRule(t *testing.T) {
+	sheet, _ := NewSpreadsheet("", "")
+	r := sheet.getSheetRange("Sheet1", "B2:B5")
+	tests := []struct {
+		rule string
+		want condFormat
+	}{
+		{">100", condFormat{Type: "cell", Criteria: ">", Value: "100"}},
+		{"== East", condFormat{Type: "cell", Criteria: "==", Value: `"East"`}},
+		{"<> =$A$1", condFormat{Type: "cell", Criteria: "!=", Value: "$A$1"}},
+		{"not between 1 and 10", condFormat{Type: "cell", Criteria: "not between", Minimum: "1", Maximum: "10"}},
+		{"top 5%", condFormat{Type: "top", Criteria: "=", Value: "5", Percent: true}},
+		{"bottom 3", condFormat{Type: "formula", Criteria: "AND(ISNUMBER(B2),B2<=SMALL($B$2:$B$5,3))"}},
+		{"below average", condFormat{Type: "average", Criteria: "="}},
+		{"duplicate", condFormat{Type: "duplicate", Criteria: "="}},
+		{"=$C2>$B2", condFormat{Type: "formula", Criteria: "$C2>$B2"}},
+	}
+	for _, tt := range tests {
+		got, err := parseCondRule(tt.rule, r)
+		if err != nil {
+			t.Fatalf("parse rule '%s' failed (%v)", tt.rule, err)
+		}
+		if *got != tt.want {
+			t.Fatalf("parse rule '%s' want %+v, but got %+v", tt.rule, tt.want, *got)
+		}
+	}
+	for _, rule := range []string{"", "between 1", "top x", "above", "like 1"} {
+		if _, err := parseCondRule(rule, r); err == nil {
+			t.Fatalf("parse rule '%s' want error, but nil", rule)
+		}
+	}
+}
+
+func TestParseValidationRule(t *testing.T) {
+	sheet, _ := NewSpreadsheet("", "")
+	tests := []struct {
+		rule     string
+		typ      string
+		operator string
+		formula  string
+	}{
+		{"list Yes, No", "list", "", `<formula1>&#34;Yes,No&#34;</formula1>`},
+		{"list =$E$1:$E$3", "list", "", "<formula1>$E$1:$E$3</formula1>"},
+		{"whole between 1 and 100", "whole", "between", "<formula1>1</formula1><formula2>100</formula2>"},
+		{"decimal >= 0.5", "decimal", "greaterThanOrEqual", "<formula1>0.5</formula1>"},
+		{"textlength <= 10", "textLength", "lessThanOrEqual", "<formula1>10</formula1>"},
+		{"date >= 2020-04-01", "date", "greaterThanOrEqual", "<formula1>43922</formula1>"},
+		{"time not between 9:00 and 18:00", "time", "notBetween", "<formula1>0.375</formula1><formula2>0.75</formula2>"},
+		{"custom =A1<>B1", "custom", "", "<formula1>A1&lt;&gt;B1</formula1>"},
+	}
+	for _, tt := range tests {
+		dv, err := sheet.parseValidationRule(tt.rule)
+		if err != nil {
+			t.Fatalf("parse rule '%s' failed (%v)", tt.rule, err)
+		}
+		if dv.Type != tt.typ || dv.Operator != tt.operator || dv.Formula1+dv.Formula2 != tt.formula {
+			t.Fatalf("parse rule '%s' got '%s' '%s' '%s'", tt.rule, dv.Type, dv.Operator, dv.Formula1+dv.Formula2)
+		}
+	}
+	for _, rule := range []string{"list", "whole 1", "date >= tomorrow", "number > 1", "custom A1"} {
+		if _, err := sheet.parseValidationRule(rule); err == nil {
+			t.Fatalf("parse rule '%s' want error, but nil", rule)
+		}
+	}
+
+	dv, _ := sheet.parseValidationRule("whole > 0")
+	if err := applyValidationOptions(dv, "input='Enter; a number';error-style=warning;blank=false"); err != nil {
+		t.Fatalf("apply options failed (%v)", err)
+	}
+	if !dv.ShowInputMessage || *dv.Prompt != "Enter; a number" || *dv.ErrorStyle != "warning" || dv.AllowBlank {
+		t.Fatalf("apply options got %+v", dv)
+	}
+	if err := applyValidationOptions(dv, "error-style=fatal"); err == nil {
+		t.Fatal("apply invalid error style want error, but nil")
+	}
+}
+
+func TestChartFormat(t *testing.T) {
+	sheet, _ := NewSpreadsheet("", "")
+	sheet.addSheet("My Data")
+	r := sheet.getSheetRange("My Data", "A1:C3")
+
+	format, err := chartFormat(r, "column", "title='Sales; 2020';legend=none")
+	if err != nil {
+		t.Fatalf("chart format failed (%v)", err)
+	}
+	want := `{"legend":{"none":true},"series":[{"categories":"'My Data'!$A$2:$A$3","name":"'My Data'!$B$1","values":"'My Data'!$B$2:$B$3"},` +
+		`{"categories":"'My Data'!$A$2:$A$3","name":"'My Data'!$C$1","values":"'My Data'!$C$2:$C$3"}],"title":{"name":"Sales; 2020"},"type":"col"}`
+	if format != want {
+		t.Fatalf("chart format want '%s', but got '%s'", want, format)
+	}
+
+	format, _ = chartFormat(r, "line", "series=rows;width=640")
+	want = `{"dimension":{"width":640},"series":[{"categories":"'My Data'!$B$1:$C$1","name":"'My Data'!$A$2","values":"'My Data'!$B$2:$C$2"},` +
+		`{"categories":"'My Data'!$B$1:$C$1","name":"'My Data'!$A$3","values":"'My Data'!$B$3:$C$3"}],"type":"line"}`
+	if format != want {
+		t.Fatalf("chart format want '%s', but got '%s'", want, format)
+	}
+
+	for _, c := range [][2]string{{"bubble", ""}, {"line", "legend=middle"}, {"line", "size=10"}} {
+		if _, err := chartFormat(r, c[0], c[1]); err == nil {
+			t.Fatalf("chart format '%s' '%s' want error, but nil", c[0], c[1])
+		}
+	}
+}
+
+func TestDefinedName(t *testing.T) {
+	sheet, _ := NewSpreadsheet("", "")
+	sheet.addSheet("My Data")
+	if err := sheet.setDefinedName("Total", "Sheet1!$B$2", ""); err != nil {
+		t.Fatalf("set name failed (%v)", err)
+	}
+	if err := sheet.setDefinedName("Total", "'My Data'!$A$1:$C$3", "My Data"); err != nil {
+		t.Fatalf("set name failed (%v)", err)
+	}
+	if err := sheet.setDefinedName("Rate", "0.1", ""); err != nil {
+		t.Fatalf("set name failed (%v)", err)
+	}
+
+	tests := []struct {
+		sheet string
+		want  string
+	}{
+		{"Sheet1", "Sheet1!B2"},
+		{"My Data", "My Data!A1:C3"},
+	}
+	for _, tt := range tests {
+		s, ref, ok, err := sheet.resolveDefinedName("total", tt.sheet)
+		if !ok || err != nil || s+"!"+ref != tt.want {
+			t.Fatalf("resolve name from '%s' want '%s', but got '%s!%s' (%v)", tt.sheet, tt.want, s, ref, err)
+		}
+	}
+	if _, _, ok, err := sheet.resolveDefinedName("Rate", "Sheet1"); !ok || err == nil {
+		t.Fatal("resolve name of formula want error, but nil")
+	}
+	if _, _, ok, _ := sheet.resolveDefinedName("Unknown", "Sheet1"); ok {
+		t.Fatal("resolve unknown name want not ok, but ok")
+	}
+	for _, name := range []string{"A1", "AB", "R", "1st", "My Name"} {
+		if err := sheet.setDefinedName(name, "Sheet1!$A$1", ""); err == nil {
+			t.Fatalf("set name '%s' want error, but nil", name)
+		}
+	}
+
+	if !sheet.deleteDefinedName("Total", "My Data") || sheet.deleteDefinedName("Total", "My Data") {
+		t.Fatal("delete name want deleted only once")
+	}
+	if n := len(sheet.getDefinedNames()); n != 2 {
+		t.Fatalf("names want 2, but got %d", n)
+	}
+}
+
+func TestCSVRecord(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("a,\"b,c\",\"d\"\"e\"\r\n\n'f;g'\n\"h\nI\""))
+	tests := []struct {
+		quote rune
+		want  []string
+	}{
+		{'"', []string{"a", "b,c", `d"e`}},
+		{'"', []string{}},
+		{'\'', []string{"f;g"}},
+		{'"', []string{"h\nI"}},
+	}
+	for _, tt := range tests {
+		fields, _, ok, err := readCSVRecord(r, ',', tt.quote)
+		if !ok || err != nil || strings.Join(fields, "|") != strings.Join(tt.want, "|") || len(fields) != len(tt.want) {
+			t.Fatalf("read record want %q, but got %q (%v)", tt.want, fields, err)
+		}
+	}
+	if _, _, ok, _ := readCSVRecord(r, ',', '"'); ok {
+		t.Fatal("read record after EOF want false, but true")
+	}
+	if _, _, _, err := readCSVRecord(bufio.NewReader(strings.NewReader(`"a`)), ',', '"'); err == nil {
+		t.Fatal("read unclosed quote want error, but nil")
+	}
+
+	opts, err := parseCSVOptions("quote=single;crlf", "data.tsv")
+	if err != nil {
+		t.Fatalf("parse options failed (%v)", err)
+	}
+	b := new(strings.Builder)
+	writeCSVRecord(b, []string{"a\tb", "it's", "c"}, opts)
+	if want := "'a\tb'\t'it''s'\tc\r\n"; b.String() != want {
+		t.Fatalf("write record want %q, but got %q", want, b.String())
+	}
+	for _, spec := range []string{"delim=double", "delim=ab", "encoding=unknown", "size=1"} {
+		if _, err := parseCSVOptions(spec, "data.csv"); err == nil {
+			t.Fatalf("parse options '%s' want error, but nil", spec)
+		}
+	}
+}
+
+func TestDumpTable(t *testing.T) {
+	sheet, _ := NewSpreadsheet("", "")
+	sheet.setCellValue("A1", "Q1")
+	sheet.setCellValue("A3", "x")
+	sheet.setCellValue("C3", "")
+	sheet.setCellValue("A6", "")
+	sheet.mergeSheetCells("Sheet1", sheet.getRange("A1:B3"))
+
+	tests := []struct {
+		header bool
+		span   [2]int
+		want   []string
+	}{
+		{false, [2]int{3, 2}, []string{"Q1|", "|", "|"}},
+		{true, [2]int{2, 2}, []string{"Q1|", "|", "|"}},
+	}
+	for _, tt := range tests {
+		d := sheet.getDumpTable(sheet.getSheetUsedRange("Sheet1"), true, tt.header)
+		rows := make([]string, len(d.rows))
+		for i, row := range d.rows {
+			rows[i] = strings.Join(row, "|")
+		}
+		if strings.Join(rows, ",") != strings.Join(tt.want, ",") {
+			t.Fatalf("dump table want %q, but got %q", tt.want, rows)
+		}
+		if tt.header && d.span[[2]int{0, 0}] != [2]int{1, 2} {
+			t.Fatalf("header span want [1 2], but got %v", d.span[[2]int{0, 0}])
+		}
+		if d.span[[2]int{3 - tt.span[0], 0}] != tt.span {
+			t.Fatalf("span want %v, but got %v", tt.span, d.span)
+		}
+	}
+
+	d := sheet.getDumpTable(nil, true, false)
+	for _, format := range []string{"json", "ndjson", "md", "html"} {
+		s, err := d.dumpText(format, false)
+		if err != nil || (format == "json") != (s == "[]\n") || (format != "json" && s != "") {
+			t.Fatalf("dump empty table as %s got %q (%v)", format, s, err)
+		}
+	}
+	if _, err := d.dumpText("xml", false); err == nil {
+		t.Fatal("dump as xml want error, but nil")
+	}
+}
+
+func TestReadJSONRecords(t *testing.T) {
+	records, err := readJSONRecords(strings.NewReader(`[{"b":1,"a":{"y":"x","z":[true,null]}},{}] "s" {"c":2}`))
+	if err != nil {
+		t.Fatalf("read records failed (%v)", err)
+	}
+	want := []string{"b,a.y,a.z.1,a.z.2", "", "value", "c"}
+	if len(records) != len(want) {
+		t.Fatalf("read records want %d records, but got %d", len(want), len(records))
+	}
+	for i, rec := range records {
+		if strings.Join(rec.names, ",") != want[i] {
+			t.Fatalf("record %d want names '%s', but got '%s'", i, want[i], strings.Join(rec.names, ","))
+		}
+	}
+	if v := records[0].values["a.z.1"]; v != true {
+		t.Fatalf("value of a.z.1 want true, but got %v", v)
+	}
+	if _, err := readJSONRecords(strings.NewReader(`[{"a":1},`)); err == nil {
+		t.Fatal("read broken JSON want error, but nil")
+	}
+}
+
+func TestSheetDimension(t *testing.T) {
+	sheet, _ := NewSpreadsheet("", "")
+	sheet.setSheetCellValue("Sheet1", "C2", 1)
+	if rows, cols := sheet.getSheetRowsCount("Sheet1"), sheet.getSheetColsCount("Sheet1"); rows != 2 || cols != 3 {
+		t.Fatalf("dimension want 2x3, but got %dx%d", rows, cols)
+	}
+	sheet.setSheetCellValue("Sheet1", "E4", 1)
+	if rows, cols := sheet.getSheetRowsCount("Sheet1"), sheet.getSheetColsCount("Sheet1"); rows != 4 || cols != 5 {
+		t.Fatalf("dimension want 4x5 after write, but got %dx%d", rows, cols)
+	}
+	sheet.deleteSheetRows("Sheet1", 1, 2)
+	if rows := sheet.getSheetRowsCount("Sheet1"); rows != 2 {
+		t.Fatalf("rows want 2 after delete, but got %d", rows)
+	}
+}
+
+func TestRowCursor(t *testing.T) {
+	sheet, _ := NewSpreadsheet("", "")
+	for row := 1; row <= 3; row++ {
+		sheet.setSheetCellValue("Sheet1", "A"+strconv.Itoa(row), row*10)
+	}
+
+	sheet.seekSheetRow("Sheet1", 2)
+	if v, ok := sheet.getCursorValue("Sheet1", "A2"); !ok || v != "20" {
+		t.Fatalf("cursor value want '20', but got '%s' (%v)", v, ok)
+	}
+	if _, ok := sheet.getCursorValue("Sheet1", "A3"); ok {
+		t.Fatal("cursor value of the other row want not ok, but ok")
+	}
+
+	// the written row is read from the sheet
+	sheet.setSheetCellValue("Sheet1", "A2", "x")
+	if _, ok := sheet.getCursorValue("Sheet1", "A2"); ok {
+		t.Fatal("cursor value of the written row want not ok, but ok")
+	}
+	if v := sheet.getSheetCellValue("Sheet1", "A2"); v != "x" {
+		t.Fatalf("cell value want 'x', but got '%s'", v)
+	}
+
+	// the cursor is dropped if the rows after the current row are written
+	sheet.setSheetCellValue("Sheet1", "A3", "y")
+	if sheet.cursor != nil {
+		t.Fatal("cursor want dropped, but not")
+	}
+	sheet.seekSheetRow("Sheet1", 3)
+	if v := sheet.getSheetCellValue("Sheet1", "A3"); v != "y" {
+		t.Fatalf("cell value want 'y', but got '%s'", v)
+	}
+}
+
+// BenchmarkRowLoop compares the -N loop which counts the rows every iteration like before
+// with the loop by the cached dimension and the cursor.
+func BenchmarkRowLoop(b *testing.B) {
+	sheet, _ := NewSpreadsheet("", "")
+	for row := 1; row <= 500; row++ {
+		sheet.setSheetCellValue("Sheet1", "A"+strconv.Itoa(row), row)
+		sheet.setSheetCellValue("Sheet1", "B"+strconv.Itoa(row), "name")
+	}
+
+	b.Run("GetRows", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for row := 1; ; row++ {
+				rows, _ := sheet.file.GetRows("Sheet1")
+				if len(rows) < row {
+					break
+				}
+				sheet.file.GetCellValue("Sheet1", "A"+strconv.Itoa(row))
+			}
+		}
+	})
+	b.Run("Cursor", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			sheet.invalidateSheets()
+			for row := 1; row <= sheet.getSheetRowsCount("Sheet1"); row++ {
+				sheet.seekSheetRow("Sheet1", row)
+				sheet.getSheetCellValue("Sheet1", "A"+strconv.Itoa(row))
+			}
+		}
+	})
+}
+
+func TestCalcFormula(t *testing.T) {
+	sheet, _ := NewSpreadsheet("", "")
+	sheet.setSheetCellValue("Sheet1", "A1", 10)
+	sheet.setSheetCellValue("Sheet1", "A2", 20)
+	sheet.setSheetCellValue("Sheet1", "A3", "apple")
+	sheet.setSheetCellValue("Sheet1", "B1", "ten")
+	sheet.setSheetCellValue("Sheet1", "B2", "twenty")
+	// the formula cell which is referred is calculated first
+	sheet.setSheetCellFormula("Sheet1", "D1", "A1*2")
+	sheet.setDefinedName("Items", "Sheet1!$A$1:$B$2", "")
+	sheet.setDefinedName("Half", "0.5", "")
+
+	tests := []struct {
+		formula string
+		want    string
+	}{
+		{"1+2*3-4/2", "5"},
+		{"SUM(A1:A3)+AVERAGE(A1,A2)", "45"},
+		{"IF(A1>5,\"big\",\"small\")", "big"},
+		{"VLOOKUP(20,A1:B2,2,FALSE)", "twenty"},
+		{"VLOOKUP(15,Items,2)", "ten"},
+		{"ROUND(2.675,2)", "2.68"},
+		{"1.1+2.2", "3.3"},
+		{"IFERROR(A1/0,\"div\")", "div"},
+		{"D1+Half", "20.5"},
+		{"Sheet1!A1<>'Sheet1'!A2", "TRUE"},
+		{"1/0", "#DIV/0!"},
+		{"UNKNOWN(1)", "#NAME?"},
+		{"Unknown", "#NAME?"},
+		{"A3+1", "#VALUE!"},
+	}
+	for _, tt := range tests {
+		sheet.setSheetCellFormula("Sheet1", "C1", tt.formula)
+		got := newFormulaCalc(sheet).cellValue("Sheet1", "C1")
+		if got != tt.want {
+			t.Fatalf("formula '%s' want '%s', but got '%s'", tt.formula, tt.want, got)
+		}
+		if f := sheet.getSheetCellFormula("Sheet1", "C1"); f != tt.formula {
+			t.Fatalf("formula '%s' is changed to '%s'", tt.formula, f)
+		}
+	}
+}
+
+func TestParseStyleSpec(t *testing.T) {
+	spec, err := parseStyleSpec(" Bold ; color=#ff0000;numfmt='0;-0' ;")
+	if err != nil {
+		t.Fatalf("parseStyleSpec error: %v", err)
+	}
+	want := []styleEntry{{"bold", ""}, {"color", "#ff0000"}, {"numfmt", "0;-0"}}
+	if len(spec.entries) != len(want) {
+		t.Fatalf("want entries %v, but got %v", want, spec.entries)
+	}
+	for i := range want {
+		if spec.entries[i] != want[i] {
+			t.Fatalf("want entries %v, but got %v", want, spec.entries)
+		}
+	}
+
+	for _, invalid := range []string{"blink", "size=0", "color=#12345", "border=wavy", "bold=maybe", "numfmt="} {
+		if _, err := parseStyleSpec(invalid); err == nil {
+			t.Fatalf("parseStyleSpec '%s' want error, but got nil", invalid)
+		}
+	}
+}
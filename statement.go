@@ -15,6 +15,8 @@ const (
 	ContinueStatement
 	FunctionStatement
 	ReturnStatement
+	ForInStatement
+	DeleteStatement
 )
 
 type Statement struct {
@@ -89,6 +91,21 @@ func NewReturnStatement(expr *Expression) *Statement {
 	return s
 }
 
+func NewForInStatement(expr *Expression, then *Statement) *Statement {
+	if expr.exprType != ArrayInExpression || len(expr.args.args) != 1 || expr.args.args[0].exprType != VarReferExpression {
+		fatalError("syntax error: for statement requires '(key in array)'")
+	}
+	s := &Statement{stmtType: ForInStatement, expr: expr, thenStmt: then}
+	return s
+}
+
+// NewDeleteStatement makes 'delete a[k]' statement if expr is an array element,
+// or 'delete a' statement if expr is an array variable.
+func NewDeleteStatement(expr *Expression) *Statement {
+	s := &Statement{stmtType: DeleteStatement, expr: expr}
+	return s
+}
+
 func (s *Statement) eval() Node {
 	switch s.stmtType {
 	case BlankStatement:
@@ -173,6 +190,53 @@ func (s *Statement) eval() Node {
 		execContext.funcRet = s.expr.eval()
 		execContext.doReturn = true
 		return NewBlankStatement()
+	case ForInStatement:
+		ident := s.expr.args.args[0].ident
+		v := s.expr.right.eval()
+
+		var keys []string
+		var a *Array
+		switch t := v.(type) {
+		case *Array:
+			a = t
+			keys = a.keyList()
+		case *Range:
+			// iterate the cell names in the range
+			keys = t.names()
+		default:
+			if v.asString() != "" {
+				fatalError("'for (key in array)' requires an array or a range")
+			}
+			return NewBlankStatement()
+		}
+		for _, k := range keys {
+			// deleted in the loop
+			if a != nil && !a.exist(k) {
+				continue
+			}
+			execContext.scope.set(ident, NewStringExpression(k))
+			s.thenStmt.eval()
+			if execContext.doExit || execContext.doReturn {
+				break
+			}
+			if execContext.doBreak {
+				execContext.doBreak = false
+				break
+			}
+			if execContext.doContinue {
+				execContext.doContinue = false
+				continue
+			}
+		}
+		return NewBlankStatement()
+	case DeleteStatement:
+		a := execContext.scope.getArray(s.expr.ident)
+		if s.expr.exprType == ArrayReferExpression {
+			a.delete(s.expr.subscript())
+		} else {
+			a.clear()
+		}
+		return NewBlankStatement()
 	}
 	panic("evaluate unknown type.")
 }
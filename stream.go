@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/360EntSecGroup-Skylar/excelize/v2"
+)
+
+// In the stream mode(-stream), the rows of the new workbook are written in ascending order by excelize.StreamWriter
+// instead of holding the whole workbook in memory. The cells of the current row are buffered until a cell of a later row
+// is written, and the rows before the current row can not be read or written any more.
+
+// sheetStream writes the rows of a sheet.
+type sheetStream struct {
+	// writer is created when the first row is written out, so the column widths and the panes can be set until then
+	writer *excelize.StreamWriter
+	// row is the current row, or 0 if no cell is written
+	row   int
+	cells []excelize.Cell
+	cols  int
+}
+
+// startStream makes the spreadsheet write the rows by streaming.
+func (s *Spreadsheet) startStream() {
+	s.streams = map[string]*sheetStream{}
+}
+
+func (s *Spreadsheet) isStreaming() bool {
+	return s.streams != nil
+}
+
+// checkNotStreaming stops the operation which can not be done in the stream mode.
+func (s *Spreadsheet) checkNotStreaming(operation string) {
+	if s.isStreaming() {
+		fatalError("%s is not supported in stream mode", operation)
+	}
+}
+
+// checkStreamNotStarted stops the operation which must be done before the rows of the sheet are written out.
+func (s *Spreadsheet) checkStreamNotStarted(sheet string, operation string) {
+	if st := s.streams[sheet]; st != nil && st.writer != nil {
+		fatalError("%s must be set before the second row is written in stream mode", operation)
+	}
+}
+
+// streamCell returns the buffered cell to write. The current row is written out if the cell is in a later row.
+func (s *Spreadsheet) streamCell(sheet string, axis string) *excelize.Cell {
+	col, row, err := excelize.CellNameToCoordinates(axis)
+	if err != nil {
+		fatalError("cell '%s' set value failed", axis)
+	}
+	st := s.streams[sheet]
+	if st == nil {
+		st = &sheetStream{}
+		s.streams[sheet] = st
+	}
+	if row < st.row {
+		fatalError("cell '%s' can not be written because row %d is already written in stream mode", axis, row)
+	}
+	if st.row < row {
+		s.flushStreamRow(sheet, st)
+		st.row = row
+		st.cells = st.cells[:0]
+	}
+	for len(st.cells) < col {
+		st.cells = append(st.cells, excelize.Cell{})
+	}
+	if st.cols < col {
+		st.cols = col
+	}
+	return &st.cells[col-1]
+}
+
+// streamedCell returns the buffered cell to read, or nil if the cell is not written yet.
+// streamed is false if the sheet is not written by streaming.
+func (s *Spreadsheet) streamedCell(sheet string, axis string) (cell *excelize.Cell, streamed bool) {
+	st := s.streams[sheet]
+	if st == nil {
+		return nil, false
+	}
+	col, row, err := excelize.CellNameToCoordinates(axis)
+	if err != nil {
+		fatalError("cell '%s' refer failed", axis)
+	}
+	if row < st.row {
+		fatalError("cell '%s' can not be read because row %d is already written in stream mode", axis, row)
+	}
+	if st.row < row || len(st.cells) < col {
+		return nil, true
+	}
+	return &st.cells[col-1], true
+}
+
+// flushStreamRow writes out the current row of the sheet.
+func (s *Spreadsheet) flushStreamRow(sheet string, st *sheetStream) {
+	if st.row == 0 || len(st.cells) == 0 {
+		return
+	}
+	if st.writer == nil {
+		w, err := s.file.NewStreamWriter(sheet)
+		if err != nil {
+			fatalError("sheet '%s' stream failed (%v)", sheet, err)
+		}
+		st.writer = w
+	}
+	values := make([]interface{}, len(st.cells))
+	for i, c := range st.cells {
+		values[i] = c
+	}
+	axis, _ := excelize.CoordinatesToCellName(1, st.row)
+	if err := st.writer.SetRow(axis, values); err != nil {
+		fatalError("row %d write failed (%v)", st.row, err)
+	}
+}
+
+// closeStreams writes out the rest of the rows of all sheets.
+func (s *Spreadsheet) closeStreams() {
+	for sheet, st := range s.streams {
+		s.flushStreamRow(sheet, st)
+		if st.writer == nil {
+			continue
+		}
+		if err := st.writer.Flush(); err != nil {
+			fatalError("sheet '%s' stream failed (%v)", sheet, err)
+		}
+	}
+	s.streams = nil
+}
+
+// streamValueString returns the buffered value as a string without the number format.
+func streamValueString(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case int:
+		return strconv.Itoa(v)
+	case bool:
+		if v {
+			return "TRUE"
+		}
+		return "FALSE"
+	case time.Time:
+		return v.Format("2006-01-02 15:04:05")
+	}
+	return fmt.Sprint(v)
+}
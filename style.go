@@ -0,0 +1,570 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/360EntSecGroup-Skylar/excelize/v2"
+)
+
+// The style of cells is specified by a compact spec like "bold;size=14;fill=#FFFF00;numfmt=#,##0.00".
+// The entries are separated by ';', and a value can be quoted by '' to contain ';'.
+
+var borderStyles = []string{
+	"none",
+	"thin",
+	"medium",
+	"dashed",
+	"dotted",
+	"thick",
+	"double",
+	"hair",
+	"mediumDashed",
+	"dashDot",
+	"mediumDashDot",
+	"dashDotDot",
+	"mediumDashDotDot",
+	"slantDashDot",
+}
+
+var borderSides = []string{"left", "right", "top", "bottom"}
+
+var colorNames = map[string]string{
+	"black":  "#000000",
+	"white":  "#FFFFFF",
+	"red":    "#FF0000",
+	"green":  "#00FF00",
+	"blue":   "#0000FF",
+	"yellow": "#FFFF00",
+	"gray":   "#808080",
+	"grey":   "#808080",
+	"orange": "#FFA500",
+}
+
+type styleEntry struct {
+	key   string
+	value string
+}
+
+// styleSpec is the parsed spec of the style.
+type styleSpec struct {
+	text    string
+	entries []styleEntry
+}
+
+// parseStyleSpec parses and validates the spec.
+func parseStyleSpec(text string) (*styleSpec, error) {
+	spec := &styleSpec{text: text, entries: make([]styleEntry, 0)}
+	for _, item := range splitStyleSpec(text) {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		key, value := item, ""
+		if i := strings.IndexByte(item, '='); i >= 0 {
+			key = strings.TrimSpace(item[:i])
+			value = unquoteStyleValue(strings.TrimSpace(item[i+1:]))
+		}
+		key = strings.ToLower(key)
+		if err := validateStyleEntry(key, value); err != nil {
+			return nil, err
+		}
+		spec.entries = append(spec.entries, styleEntry{key: key, value: value})
+	}
+	return spec, nil
+}
+
+// splitStyleSpec splits the spec by ';' outside the quotes.
+func splitStyleSpec(text string) []string {
+	items := make([]string, 0)
+	quoted := false
+	start := 0
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '\'':
+			quoted = !quoted
+		case ';':
+			if !quoted {
+				items = append(items, text[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(items, text[start:])
+}
+
+func unquoteStyleValue(v string) string {
+	if 2 <= len(v) && v[0] == '\'' && v[len(v)-1] == '\'' {
+		return v[1 : len(v)-1]
+	}
+	return v
+}
+
+func validateStyleEntry(key string, value string) error {
+	switch key {
+	case "bold", "italic", "underline", "strike", "wrap":
+		if value == "" {
+			return nil
+		}
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("'%s' is invalid value for %s", value, key)
+		}
+	case "font", "numfmt":
+		if value == "" {
+			return fmt.Errorf("%s needs a value", key)
+		}
+	case "size":
+		if f, err := strconv.ParseFloat(value, 64); err != nil || f < 1 {
+			return fmt.Errorf("'%s' is invalid font size", value)
+		}
+	case "color", "border-color":
+		if _, ok := styleColor(value); !ok {
+			return fmt.Errorf("'%s' is invalid color", value)
+		}
+	case "fill":
+		if _, ok := styleColor(value); !ok && value != "none" {
+			return fmt.Errorf("'%s' is invalid color", value)
+		}
+	case "border", "border-left", "border-right", "border-top", "border-bottom":
+		if indexOf(borderStyles, value) < 0 {
+			return fmt.Errorf("'%s' is invalid border style", value)
+		}
+	case "align":
+		switch value {
+		case "general", "left", "center", "right", "fill", "justify", "centerContinuous", "distributed":
+		default:
+			return fmt.Errorf("'%s' is invalid horizontal alignment", value)
+		}
+	case "valign":
+		switch value {
+		case "top", "center", "middle", "bottom", "justify", "distributed":
+		default:
+			return fmt.Errorf("'%s' is invalid vertical alignment", value)
+		}
+	default:
+		return fmt.Errorf("'%s' is unknown style", key)
+	}
+	return nil
+}
+
+func indexOf(list []string, v string) int {
+	for i, s := range list {
+		if s == v {
+			return i
+		}
+	}
+	return -1
+}
+
+// styleColor returns the color as "#RRGGBB".
+func styleColor(v string) (string, bool) {
+	if c, ok := colorNames[strings.ToLower(v)]; ok {
+		return c, true
+	}
+	v = strings.TrimPrefix(v, "#")
+	if len(v) != 6 {
+		return "", false
+	}
+	if _, err := strconv.ParseUint(v, 16, 32); err != nil {
+		return "", false
+	}
+	return "#" + strings.ToUpper(v), true
+}
+
+func styleBool(v string) bool {
+	if v == "" {
+		return true
+	}
+	b, _ := strconv.ParseBool(v)
+	return b
+}
+
+// apply applies the spec to the style.
+func (spec *styleSpec) apply(st *excelize.Style, defaultFont *excelize.Font) {
+	font := func() *excelize.Font {
+		if st.Font == nil {
+			f := *defaultFont
+			st.Font = &f
+		}
+		return st.Font
+	}
+	alignment := func() *excelize.Alignment {
+		if st.Alignment == nil {
+			st.Alignment = &excelize.Alignment{}
+		}
+		return st.Alignment
+	}
+
+	borderColor := ""
+	for _, e := range spec.entries {
+		if e.key == "border-color" {
+			borderColor, _ = styleColor(e.value)
+		}
+	}
+
+	for _, e := range spec.entries {
+		switch e.key {
+		case "bold":
+			font().Bold = styleBool(e.value)
+		case "italic":
+			font().Italic = styleBool(e.value)
+		case "underline":
+			font().Underline = ""
+			if styleBool(e.value) {
+				font().Underline = "single"
+			}
+		case "strike":
+			font().Strike = styleBool(e.value)
+		case "font":
+			font().Family = e.value
+		case "size":
+			font().Size, _ = strconv.ParseFloat(e.value, 64)
+		case "color":
+			font().Color, _ = styleColor(e.value)
+		case "fill":
+			st.Fill = excelize.Fill{}
+			if c, ok := styleColor(e.value); ok {
+				st.Fill = excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{c}}
+			}
+		case "border":
+			for _, side := range borderSides {
+				st.Border = setBorder(st.Border, side, e.value, borderColor)
+			}
+		case "border-left", "border-right", "border-top", "border-bottom":
+			st.Border = setBorder(st.Border, strings.TrimPrefix(e.key, "border-"), e.value, borderColor)
+		case "border-color":
+			for i := range st.Border {
+				st.Border[i].Color = borderColor
+			}
+		case "align":
+			alignment().Horizontal = e.value
+		case "valign":
+			if e.value == "middle" {
+				alignment().Vertical = "center"
+			} else {
+				alignment().Vertical = e.value
+			}
+		case "wrap":
+			alignment().WrapText = styleBool(e.value)
+		case "numfmt":
+			format := e.value
+			st.NumFmt = 0
+			st.CustomNumFmt = &format
+		}
+	}
+}
+
+// setBorder sets the border of the side. The border is removed if the style is "none".
+func setBorder(borders []excelize.Border, side string, style string, color string) []excelize.Border {
+	result := make([]excelize.Border, 0, len(borders)+1)
+	for _, b := range borders {
+		if b.Type != side {
+			result = append(result, b)
+		}
+	}
+	if style == "none" {
+		return result
+	}
+	if color == "" {
+		color = "#000000"
+	}
+	return append(result, excelize.Border{Type: side, Style: indexOf(borderStyles, style), Color: color})
+}
+
+// styleOf returns the settings of the style ID.
+func (s *Spreadsheet) styleOf(id int) *excelize.Style {
+	st := &excelize.Style{}
+	ss := s.file.Styles
+	if ss == nil || ss.CellXfs == nil || id <= 0 || len(ss.CellXfs.Xf) <= id {
+		return st
+	}
+	xf := ss.CellXfs.Xf[id]
+
+	if xf.FontID != nil && 0 < *xf.FontID {
+		st.Font = s.fontOf(*xf.FontID)
+	}
+	if xf.FillID != nil && ss.Fills != nil && 0 < *xf.FillID && *xf.FillID < len(ss.Fills.Fill) {
+		if p := ss.Fills.Fill[*xf.FillID].PatternFill; p != nil && p.PatternType == "solid" {
+			if c, ok := argbColor(p.FgColor.RGB); ok {
+				st.Fill = excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{c}}
+			}
+		}
+	}
+	if xf.BorderID != nil && ss.Borders != nil && 0 < *xf.BorderID && *xf.BorderID < len(ss.Borders.Border) {
+		b := ss.Borders.Border[*xf.BorderID]
+		add := func(side string, style string, rgb func() string) {
+			if i := indexOf(borderStyles, style); 0 < i {
+				color, _ := argbColor(rgb())
+				st.Border = append(st.Border, excelize.Border{Type: side, Style: i, Color: color})
+			}
+		}
+		add("left", b.Left.Style, func() string {
+			if b.Left.Color == nil {
+				return ""
+			}
+			return b.Left.Color.RGB
+		})
+		add("right", b.Right.Style, func() string {
+			if b.Right.Color == nil {
+				return ""
+			}
+			return b.Right.Color.RGB
+		})
+		add("top", b.Top.Style, func() string {
+			if b.Top.Color == nil {
+				return ""
+			}
+			return b.Top.Color.RGB
+		})
+		add("bottom", b.Bottom.Style, func() string {
+			if b.Bottom.Color == nil {
+				return ""
+			}
+			return b.Bottom.Color.RGB
+		})
+	}
+	if xf.Alignment != nil {
+		a := xf.Alignment
+		st.Alignment = &excelize.Alignment{
+			Horizontal:      a.Horizontal,
+			Indent:          a.Indent,
+			JustifyLastLine: a.JustifyLastLine,
+			ReadingOrder:    a.ReadingOrder,
+			RelativeIndent:  a.RelativeIndent,
+			ShrinkToFit:     a.ShrinkToFit,
+			TextRotation:    a.TextRotation,
+			Vertical:        a.Vertical,
+			WrapText:        a.WrapText,
+		}
+	}
+	if xf.NumFmtID != nil {
+		st.NumFmt, st.CustomNumFmt = s.numFmtOf(*xf.NumFmtID)
+	}
+	return st
+}
+
+// fontOf returns the settings of the font ID.
+func (s *Spreadsheet) fontOf(id int) *excelize.Font {
+	font := &excelize.Font{Size: 11, Family: s.file.GetDefaultFont()}
+	ss := s.file.Styles
+	if ss.Fonts == nil || id < 0 || len(ss.Fonts.Font) <= id {
+		return font
+	}
+	f := ss.Fonts.Font[id]
+	font.Bold = f.B != nil
+	font.Italic = f.I != nil
+	font.Strike = f.Strike != nil
+	if f.U != nil {
+		font.Underline = "single"
+		if f.U.Val != nil && *f.U.Val != "" {
+			font.Underline = *f.U.Val
+		}
+	}
+	if f.Sz != nil && f.Sz.Val != nil {
+		font.Size = *f.Sz.Val
+	}
+	if f.Name != nil && f.Name.Val != nil {
+		font.Family = *f.Name.Val
+	}
+	if f.Color != nil {
+		font.Color, _ = argbColor(f.Color.RGB)
+	}
+	return font
+}
+
+// numFmtOf returns the builtin number format or the custom number format of the ID.
+func (s *Spreadsheet) numFmtOf(id int) (int, *string) {
+	if id < 164 {
+		// the builtin formats which are defined without locale
+		if id <= 22 || (37 <= id && id <= 49) {
+			return id, nil
+		}
+		return 0, nil
+	}
+	ss := s.file.Styles
+	if ss.NumFmts != nil {
+		for _, f := range ss.NumFmts.NumFmt {
+			if f.NumFmtID == id {
+				code := f.FormatCode
+				return 0, &code
+			}
+		}
+	}
+	return 0, nil
+}
+
+// argbColor converts the ARGB color like "FFFF0000" to "#FF0000".
+func argbColor(argb string) (string, bool) {
+	if len(argb) == 8 {
+		argb = argb[2:]
+	}
+	if len(argb) != 6 {
+		return "", false
+	}
+	return styleColor(argb)
+}
+
+// styleSpecOf returns the spec of the style. The default settings are omitted.
+func (s *Spreadsheet) styleSpecOf(st *excelize.Style) string {
+	entries := make([]string, 0)
+	if f := st.Font; f != nil {
+		def := s.fontOf(0)
+		if f.Bold {
+			entries = append(entries, "bold")
+		}
+		if f.Italic {
+			entries = append(entries, "italic")
+		}
+		if f.Underline != "" {
+			entries = append(entries, "underline")
+		}
+		if f.Strike {
+			entries = append(entries, "strike")
+		}
+		if f.Family != def.Family {
+			entries = append(entries, "font="+quoteStyleValue(f.Family))
+		}
+		if f.Size != def.Size {
+			entries = append(entries, "size="+strconv.FormatFloat(f.Size, 'f', -1, 64))
+		}
+		if f.Color != "" && f.Color != def.Color && f.Color != "#000000" {
+			entries = append(entries, "color="+f.Color)
+		}
+	}
+	if len(st.Fill.Color) != 0 {
+		entries = append(entries, "fill="+st.Fill.Color[0])
+	}
+
+	borders := make(map[string]excelize.Border)
+	for _, b := range st.Border {
+		borders[b.Type] = b
+	}
+	if len(borders) == 4 && sameBorders(st.Border) {
+		entries = append(entries, "border="+borderStyles[st.Border[0].Style])
+	} else {
+		for _, side := range borderSides {
+			if b, ok := borders[side]; ok {
+				entries = append(entries, "border-"+side+"="+borderStyles[b.Style])
+			}
+		}
+	}
+	if 0 < len(st.Border) && st.Border[0].Color != "" && st.Border[0].Color != "#000000" {
+		entries = append(entries, "border-color="+st.Border[0].Color)
+	}
+
+	if a := st.Alignment; a != nil {
+		if a.Horizontal != "" && a.Horizontal != "general" {
+			entries = append(entries, "align="+a.Horizontal)
+		}
+		if a.Vertical != "" && a.Vertical != "bottom" {
+			entries = append(entries, "valign="+a.Vertical)
+		}
+		if a.WrapText {
+			entries = append(entries, "wrap")
+		}
+	}
+
+	if st.CustomNumFmt != nil {
+		entries = append(entries, "numfmt="+quoteStyleValue(*st.CustomNumFmt))
+	} else if st.NumFmt != 0 {
+		entries = append(entries, "numfmt="+quoteStyleValue(builtinNumFmtCode(st.NumFmt)))
+	}
+	return strings.Join(entries, ";")
+}
+
+func sameBorders(borders []excelize.Border) bool {
+	for _, b := range borders {
+		if b.Style != borders[0].Style || b.Color != borders[0].Color {
+			return false
+		}
+	}
+	return true
+}
+
+func quoteStyleValue(v string) string {
+	if strings.ContainsAny(v, ";'") {
+		return "'" + v + "'"
+	}
+	return v
+}
+
+// builtinNumFmtCode returns the format code of the builtin number format.
+func builtinNumFmtCode(id int) string {
+	codes := map[int]string{
+		1:  "0",
+		2:  "0.00",
+		3:  "#,##0",
+		4:  "#,##0.00",
+		9:  "0%",
+		10: "0.00%",
+		11: "0.00E+00",
+		12: "# ?/?",
+		13: "# ??/??",
+		14: "mm-dd-yy",
+		15: "d-mmm-yy",
+		16: "d-mmm",
+		17: "mmm-yy",
+		18: "h:mm AM/PM",
+		19: "h:mm:ss AM/PM",
+		20: "h:mm",
+		21: "h:mm:ss",
+		22: "m/d/yy h:mm",
+		37: "#,##0 ;(#,##0)",
+		38: "#,##0 ;[Red](#,##0)",
+		39: "#,##0.00;(#,##0.00)",
+		40: "#,##0.00;[Red](#,##0.00)",
+		45: "mm:ss",
+		46: "[h]:mm:ss",
+		47: "mmss.0",
+		48: "##0.0E+0",
+		49: "@",
+	}
+	if code, ok := codes[id]; ok {
+		return code
+	}
+	return "General"
+}
+
+// derivedStyle returns the style ID which is the base style changed by the function.
+// The style IDs are cached by the base and the key of the change, not to create the same styles.
+func (s *Spreadsheet) derivedStyle(base int, key string, change func(st *excelize.Style)) int {
+	cacheKey := strconv.Itoa(base) + "\x00" + key
+	if id, ok := s.styles[cacheKey]; ok {
+		return id
+	}
+	st := s.styleOf(base)
+	change(st)
+	id, err := s.file.NewStyle(st)
+	if err != nil {
+		fatalError("style '%s' is invalid", key)
+	}
+	if s.styles == nil {
+		s.styles = make(map[string]int)
+	}
+	s.styles[cacheKey] = id
+	return id
+}
+
+func (s *Spreadsheet) getSheetCellStyle(sheet string, axis string) int {
+	if c, ok := s.streamedCell(sheet, axis); ok {
+		if c == nil {
+			return 0
+		}
+		return c.StyleID
+	}
+	id, err := s.file.GetCellStyle(sheet, axis)
+	if err != nil {
+		fatalError("cell '%s' refer failed", axis)
+	}
+	return id
+}
+
+// setSheetCellStyleSpec changes the style of the cell by the spec.
+func (s *Spreadsheet) setSheetCellStyleSpec(sheet string, axis string, spec *styleSpec) {
+	base := s.getSheetCellStyle(sheet, axis)
+	id := s.derivedStyle(base, spec.text, func(st *excelize.Style) {
+		spec.apply(st, s.fontOf(0))
+	})
+	s.setSheetCellStyle(sheet, axis, id)
+}
@@ -0,0 +1,634 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/360EntSecGroup-Skylar/excelize/v2"
+)
+
+// DefaultTableStyle is the table style used if the style is omitted.
+const DefaultTableStyle = "TableStyleMedium2"
+
+const relTypeTable = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/table"
+
+var tableNameReg = regexp.MustCompile(`^[A-Za-z_\\][A-Za-z0-9_.]*$`)
+
+// tableInfo is an Excel table(ListObject) in a sheet.
+type tableInfo struct {
+	name  string
+	sheet string
+	// ref is the range of the whole table like "A1:D10"
+	ref     string
+	columns []string
+	header  bool
+	totals  bool
+}
+
+// tableXML is the part of a table definition "xl/tables/tableN.xml".
+type tableXML struct {
+	Name           string `xml:"name,attr"`
+	DisplayName    string `xml:"displayName,attr"`
+	Ref            string `xml:"ref,attr"`
+	HeaderRowCount *int   `xml:"headerRowCount,attr"`
+	TotalsRowCount int    `xml:"totalsRowCount,attr"`
+	Columns        []struct {
+		Name string `xml:"name,attr"`
+	} `xml:"tableColumns>tableColumn"`
+}
+
+type relationship struct {
+	ID     string `xml:"Id,attr"`
+	Type   string `xml:"Type,attr"`
+	Target string `xml:"Target,attr"`
+}
+
+// readRelationships returns the relationships of the part like "xl/_rels/workbook.xml.rels".
+func (s *Spreadsheet) readRelationships(name string) []relationship {
+	var rels []relationship
+	// the relationships changed by excelize are not written to the file list until saving
+	if r, ok := s.file.Relationships[name]; ok && r != nil {
+		for _, v := range r.Relationships {
+			rels = append(rels, relationship{ID: v.ID, Type: v.Type, Target: v.Target})
+		}
+		return rels
+	}
+	content, ok := s.file.XLSX[name]
+	if !ok {
+		return nil
+	}
+	var x struct {
+		Relationships []relationship `xml:"Relationship"`
+	}
+	if err := xml.NewDecoder(bytes.NewReader(content)).Decode(&x); err != nil {
+		return nil
+	}
+	return x.Relationships
+}
+
+// resolvePartPath resolves the target of the relationship from the part.
+func resolvePartPath(from string, target string) string {
+	if strings.HasPrefix(target, "/") {
+		return target[1:]
+	}
+	return path.Join(path.Dir(from), target)
+}
+
+// getSheetParts returns the parts like "xl/worksheets/sheet1.xml" of the sheets by the names.
+func (s *Spreadsheet) getSheetParts() map[string]string {
+	targets := map[string]string{}
+	for _, r := range s.readRelationships("xl/_rels/workbook.xml.rels") {
+		targets[r.ID] = resolvePartPath("xl/workbook.xml", r.Target)
+	}
+	parts := map[string]string{}
+	for _, sheet := range s.file.WorkBook.Sheets.Sheet {
+		if part, ok := targets[sheet.ID]; ok {
+			parts[sheet.Name] = part
+		}
+	}
+	return parts
+}
+
+// getSheetRelationship returns the part of the first relationship of the type from the sheet, or "".
+func (s *Spreadsheet) getSheetRelationship(sheet string, relType string) string {
+	part, ok := s.getSheetParts()[sheet]
+	if !ok {
+		return ""
+	}
+	for _, r := range s.readRelationships(path.Join(path.Dir(part), "_rels", path.Base(part)+".rels")) {
+		if r.Type == relType {
+			return resolvePartPath(part, r.Target)
+		}
+	}
+	return ""
+}
+
+// getTables returns the tables in the workbook.
+// They are read from the file once, and cached until the tables or the sheets are changed.
+func (s *Spreadsheet) getTables() []*tableInfo {
+	if s.tables != nil {
+		return s.tables
+	}
+	s.tables = []*tableInfo{}
+
+	for _, sheet := range s.file.WorkBook.Sheets.Sheet {
+		for _, part := range s.getSheetTableParts(sheet.Name) {
+			x := s.readTableXML(part)
+			if x == nil {
+				continue
+			}
+			t := &tableInfo{
+				name:   x.DisplayName,
+				sheet:  sheet.Name,
+				ref:    x.Ref,
+				header: x.HeaderRowCount == nil || *x.HeaderRowCount != 0,
+				totals: 0 < x.TotalsRowCount,
+			}
+			if t.name == "" {
+				t.name = x.Name
+			}
+			for _, c := range x.Columns {
+				t.columns = append(t.columns, c.Name)
+			}
+			s.tables = append(s.tables, t)
+		}
+	}
+	return s.tables
+}
+
+// getSheetTableParts returns the parts of the tables in the sheet like "xl/tables/table1.xml".
+func (s *Spreadsheet) getSheetTableParts(sheet string) []string {
+	part, ok := s.getSheetParts()[sheet]
+	if !ok {
+		return nil
+	}
+	var parts []string
+	for _, r := range s.readRelationships(path.Join(path.Dir(part), "_rels", path.Base(part)+".rels")) {
+		if r.Type == relTypeTable {
+			if p := resolvePartPath(part, r.Target); s.file.XLSX[p] != nil {
+				parts = append(parts, p)
+			}
+		}
+	}
+	return parts
+}
+
+// readTableXML reads the table definition of the part, or nil.
+func (s *Spreadsheet) readTableXML(part string) *tableXML {
+	var x tableXML
+	if err := xml.NewDecoder(bytes.NewReader(s.file.XLSX[part])).Decode(&x); err != nil {
+		return nil
+	}
+	return &x
+}
+
+// getTable returns the table of the name ignoring case, or nil.
+func (s *Spreadsheet) getTable(name string) *tableInfo {
+	for _, t := range s.getTables() {
+		if strings.EqualFold(t.name, name) {
+			return t
+		}
+	}
+	return nil
+}
+
+// addSheetTable adds the table to the range with the table style like "TableStyleMedium2".
+// The empty style adds the table without the style.
+func (s *Spreadsheet) addSheetTable(r *Range, name string, style string) {
+	s.checkNotStreaming("table")
+	if !tableNameReg.MatchString(name) || isCellLikeName(name) {
+		fatalError("'%s' is invalid table name", name)
+	}
+	if s.getTable(name) != nil {
+		fatalError("table '%s' already exists", name)
+	}
+	for _, t := range s.getTables() {
+		if t.sheet != r.sheet {
+			continue
+		}
+		o := s.getSheetRange(t.sheet, t.ref)
+		if o.startCol <= r.endCol && r.startCol <= o.endCol && o.startRow <= r.endRow && r.startRow <= o.endRow {
+			fatalError("range '%s' overlaps table '%s'", r.ref(), t.name)
+		}
+	}
+
+	format, _ := json.Marshal(map[string]interface{}{
+		"table_name":       name,
+		"table_style":      style,
+		"show_row_stripes": style != "",
+	})
+	if err := s.file.AddTable(r.sheet, r.axis(0, 0), r.axis(r.width()-1, r.height()-1), string(format)); err != nil {
+		fatalError("range '%s' add table failed (%v)", r.ref(), err)
+	}
+	s.tables = nil
+	s.invalidateSheet(r.sheet)
+}
+
+var (
+	tableRefAttrReg    = regexp.MustCompile(`(\sref=")([^"]*)(")`)
+	tableColumnsReg    = regexp.MustCompile(`(?s)(<tableColumns\b[^>]*?count=")\d+("[^>]*>)(.*?)(</tableColumns>)`)
+	tableColumnReg     = regexp.MustCompile(`(?s)<tableColumn\b[^>]*?(?:/>|>.*?</tableColumn>)`)
+	tableColumnIDReg   = regexp.MustCompile(`\sid="(\d+)"`)
+	tableColumnNameReg = regexp.MustCompile(`\sname="([^"]*)"`)
+)
+
+// checkSheetTablesKept stops inserting or deleting which deletes the whole table of the sheet.
+func (s *Spreadsheet) checkSheetTablesKept(sheet string, rows bool, num int, offset int) {
+	for _, t := range s.getTables() {
+		if t.sheet == sheet && adjustFormula(t.ref, sheet, sheet, rows, num, offset) == "#REF!" {
+			fatalError("table '%s' can not be deleted by deleting the rows or the columns", t.name)
+		}
+	}
+}
+
+// adjustSheetTables adjusts the tables of the sheet for inserting(offset > 0) or deleting(offset < 0)
+// the rows or the columns at num like Excel. The columns inserted into a table are named like "Column3".
+func (s *Spreadsheet) adjustSheetTables(sheet string, rows bool, num int, offset int) {
+	for _, part := range s.getSheetTableParts(sheet) {
+		x := s.readTableXML(part)
+		if x == nil {
+			continue
+		}
+		ref := adjustFormula(x.Ref, sheet, sheet, rows, num, offset)
+		if ref == "#REF!" {
+			continue
+		}
+		r := s.getSheetRange(sheet, ref)
+		header := x.HeaderRowCount == nil || *x.HeaderRowCount != 0
+		if header && r.height() == 1 {
+			// a table has a data row at least
+			r.endRow++
+			ref = r.ref()
+		}
+		s.adjustTableRefs(part, func(old string) string {
+			if a := adjustFormula(old, sheet, sheet, rows, num, offset); old != x.Ref && a != "#REF!" {
+				return a
+			}
+			return ref
+		})
+		if rows {
+			continue
+		}
+
+		sc, _, ec, _, _ := parseRangeRef(x.Ref, 0, 0)
+		if 0 < offset && sc < num && num <= ec {
+			names := s.insertTableColumns(part, num-sc, offset)
+			if header {
+				for i, name := range names {
+					axis, _ := excelize.CoordinatesToCellName(num+i, r.startRow)
+					s.setSheetCellValue(sheet, axis, name)
+				}
+			}
+		}
+		if offset < 0 {
+			s.deleteTableColumns(part, num-sc, num-offset-1-sc)
+		}
+	}
+	s.tables = nil
+}
+
+// moveSheetTables moves the tables whose all cells are in src to dst like cut and paste.
+func (s *Spreadsheet) moveSheetTables(src *Range, dst *Range) {
+	for _, part := range s.getSheetTableParts(src.sheet) {
+		x := s.readTableXML(part)
+		if x == nil {
+			continue
+		}
+		if ref := parseFormulaRef(x.Ref); !src.containsRef(&ref) {
+			continue
+		}
+		s.adjustTableRefs(part, func(ref string) string {
+			return moveFormula(ref, src.sheet, src, dst)
+		})
+	}
+	s.tables = nil
+}
+
+// adjustTableRefs replaces the references like ref="A1:D10" of the table and the autofilter in the part with the result of fn.
+func (s *Spreadsheet) adjustTableRefs(part string, fn func(ref string) string) {
+	content := tableRefAttrReg.ReplaceAllStringFunc(string(s.file.XLSX[part]), func(m string) string {
+		sub := tableRefAttrReg.FindStringSubmatch(m)
+		return sub[1] + fn(sub[2]) + sub[3]
+	})
+	s.file.XLSX[part] = []byte(content)
+}
+
+// insertTableColumns inserts count columns named like "Column3" before the index of the table columns.
+// It returns the names of the inserted columns.
+func (s *Spreadsheet) insertTableColumns(part string, index int, count int) []string {
+	var names []string
+	s.editTableColumns(part, func(columns []string) []string {
+		used := map[string]bool{}
+		id := 0
+		for _, c := range columns {
+			if m := tableColumnNameReg.FindStringSubmatch(c); m != nil {
+				used[strings.ToLower(m[1])] = true
+			}
+			if m := tableColumnIDReg.FindStringSubmatch(c); m != nil {
+				if n, _ := strconv.Atoi(m[1]); id < n {
+					id = n
+				}
+			}
+		}
+		inserted := make([]string, count)
+		for i, n := 0, 1; i < count; i++ {
+			for used[strings.ToLower(fmt.Sprintf("Column%d", n))] {
+				n++
+			}
+			name := fmt.Sprintf("Column%d", n)
+			used[strings.ToLower(name)] = true
+			names = append(names, name)
+			id++
+			inserted[i] = fmt.Sprintf(`<tableColumn id="%d" name="%s"/>`, id, name)
+		}
+		return append(columns[:index], append(inserted, columns[index:]...)...)
+	})
+	return names
+}
+
+// deleteTableColumns deletes the table columns from the index to the last index.
+func (s *Spreadsheet) deleteTableColumns(part string, first int, last int) {
+	s.editTableColumns(part, func(columns []string) []string {
+		kept := columns[:0]
+		for i, c := range columns {
+			if i < first || last < i {
+				kept = append(kept, c)
+			}
+		}
+		return kept
+	})
+}
+
+// editTableColumns replaces the tableColumn elements in the part with the result of fn.
+func (s *Spreadsheet) editTableColumns(part string, fn func(columns []string) []string) {
+	content := string(s.file.XLSX[part])
+	m := tableColumnsReg.FindStringSubmatchIndex(content)
+	if m == nil {
+		return
+	}
+	columns := fn(tableColumnReg.FindAllString(content[m[6]:m[7]], -1))
+	s.file.XLSX[part] = []byte(content[:m[0]] + content[m[2]:m[3]] + strconv.Itoa(len(columns)) +
+		content[m[4]:m[5]] + strings.Join(columns, "") + content[m[8]:m[9]] + content[m[1]:])
+}
+
+// isCellLikeName reports whether the name can be read as a cell or a column like "A1" or "AB".
+func isCellLikeName(name string) bool {
+	if _, _, err := excelize.CellNameToCoordinates(name); err == nil {
+		return true
+	}
+	if _, err := excelize.ColumnNameToNumber(name); err == nil && len(name) <= 3 {
+		return true
+	}
+	return false
+}
+
+var structuredRefReg = regexp.MustCompile(`^([A-Za-z_\\][A-Za-z0-9_.]*)(?:\[(.*)\])?$`)
+
+// isStructuredRef reports whether the reference may be a structured reference like "Sales[Amount]" or "Sales".
+func isStructuredRef(ref string) bool {
+	m := structuredRefReg.FindStringSubmatch(ref)
+	return m != nil && (strings.Contains(ref, "[") || !isCellLikeName(ref))
+}
+
+// resolveTableRef resolves the structured reference like "Sales[Amount]", "Sales[#All]" or "Sales[[Qty]:[Amount]]"
+// to the sheet and the range. The table name alone refers to the data rows like Excel.
+// ok is false if the table is not found.
+func (s *Spreadsheet) resolveTableRef(ref string) (sheet string, rng string, ok bool, err error) {
+	m := structuredRefReg.FindStringSubmatch(ref)
+	if m == nil {
+		return "", "", false, nil
+	}
+	t := s.getTable(m[1])
+	if t == nil {
+		return "", "", false, nil
+	}
+
+	sc, sr, ec, er, err := parseRangeRef(t.ref, 0, 0)
+	if err != nil {
+		return "", "", true, err
+	}
+	dataStart, dataEnd := sr, er
+	if t.header {
+		dataStart++
+	}
+	if t.totals {
+		dataEnd--
+	}
+
+	items, err := splitStructuredItems(m[2])
+	if err != nil {
+		return "", "", true, fmt.Errorf("'%s' is invalid structured reference", ref)
+	}
+	fromRow, toRow, fromCol, toCol := 0, 0, sc, ec
+	for _, item := range items {
+		var top, bottom int
+		switch strings.ToLower(item) {
+		case "#all":
+			top, bottom = sr, er
+		case "#data":
+			top, bottom = dataStart, dataEnd
+		case "#headers":
+			if !t.header {
+				return "", "", true, fmt.Errorf("table '%s' has no header row", t.name)
+			}
+			top, bottom = sr, sr
+		case "#totals":
+			if !t.totals {
+				return "", "", true, fmt.Errorf("table '%s' has no totals row", t.name)
+			}
+			top, bottom = er, er
+		default:
+			// columns like "Amount" or "Qty]:[Amount"
+			names := strings.Split(item, "]:[")
+			cols := make([]int, len(names))
+			for i, name := range names {
+				cols[i] = t.columnIndex(unescapeStructuredName(name))
+				if cols[i] < 0 {
+					return "", "", true, fmt.Errorf("column '%s' is not in table '%s'", unescapeStructuredName(name), t.name)
+				}
+			}
+			fromCol, toCol = sc+cols[0], sc+cols[len(cols)-1]
+			if toCol < fromCol {
+				fromCol, toCol = toCol, fromCol
+			}
+			continue
+		}
+		if fromRow == 0 || top < fromRow {
+			fromRow = top
+		}
+		if toRow < bottom {
+			toRow = bottom
+		}
+	}
+	if fromRow == 0 {
+		fromRow, toRow = dataStart, dataEnd
+	}
+	if toRow < fromRow {
+		return "", "", true, fmt.Errorf("table '%s' has no data rows", t.name)
+	}
+
+	from, _ := excelize.CoordinatesToCellName(fromCol, fromRow)
+	to, _ := excelize.CoordinatesToCellName(toCol, toRow)
+	return t.sheet, from + ":" + to, true, nil
+}
+
+// splitStructuredItems splits the items in the brackets like "[#Headers],[Amount]".
+// A single item without the brackets like "Amount" is also allowed.
+func splitStructuredItems(spec string) ([]string, error) {
+	if !strings.HasPrefix(spec, "[") {
+		if spec == "" {
+			return nil, nil
+		}
+		return []string{spec}, nil
+	}
+
+	var items []string
+	for i := 0; i < len(spec); {
+		switch {
+		case spec[i] == ',' || spec[i] == ' ':
+			i++
+			continue
+		case spec[i] != '[':
+			return nil, fmt.Errorf("unexpected character '%c'", spec[i])
+		}
+		j := i + 1
+		for ; j < len(spec) && spec[j] != ']'; j++ {
+			if spec[j] == '\'' {
+				j++
+			}
+		}
+		if len(spec) <= j {
+			return nil, fmt.Errorf("unclosed bracket")
+		}
+		// "[Qty]:[Amount]"
+		if j+2 < len(spec) && spec[j+1] == ':' && spec[j+2] == '[' {
+			k := j + 3
+			for ; k < len(spec) && spec[k] != ']'; k++ {
+				if spec[k] == '\'' {
+					k++
+				}
+			}
+			if len(spec) <= k {
+				return nil, fmt.Errorf("unclosed bracket")
+			}
+			j = k
+		}
+		items = append(items, spec[i+1:j])
+		i = j + 1
+	}
+	return items, nil
+}
+
+// unescapeStructuredName removes the escape character "'" of the column name like "'#No".
+func unescapeStructuredName(name string) string {
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		if name[i] == '\'' && i+1 < len(name) {
+			i++
+		}
+		b.WriteByte(name[i])
+	}
+	return b.String()
+}
+
+// columnIndex returns the index of the column of the name ignoring case, or -1.
+func (t *tableInfo) columnIndex(name string) int {
+	for i, c := range t.columns {
+		if strings.EqualFold(c, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// autoFilterSheetRange adds the autofilter to the range, and hides the rows which do not meet the criteria
+// like "B > 2000" or "B == East or B == West". It returns the number of the shown rows.
+func (s *Spreadsheet) autoFilterSheetRange(r *Range, criteria string) (int, error) {
+	col, expr, conds, err := parseFilterCriteria(criteria)
+	if err != nil {
+		return 0, err
+	}
+	format := ""
+	if col != "" {
+		c, _ := excelize.ColumnNameToNumber(col)
+		if c < r.startCol || r.endCol < c {
+			return 0, fmt.Errorf("column '%s' is out of range '%s'", col, r.ref())
+		}
+		b, _ := json.Marshal(map[string]string{"column": col, "expression": expr})
+		format = string(b)
+	}
+	if err := s.file.AutoFilter(r.sheet, r.axis(0, 0), r.axis(r.width()-1, r.height()-1), format); err != nil {
+		return 0, err
+	}
+
+	shown := 0
+	for y := 1; y < r.height(); y++ {
+		ok := true
+		if col != "" {
+			ok = conds.match(s.getSheetCellRawValue(r.sheet, col+fmt.Sprint(r.startRow+y)))
+			s.setSheetRowVisible(r.sheet, r.startRow+y, r.startRow+y, ok)
+		}
+		if ok {
+			shown++
+		}
+	}
+	return shown, nil
+}
+
+// filterConditions are the conditions of the autofilter joined by "and" or "or".
+type filterConditions struct {
+	criteria []string
+	and      bool
+}
+
+func (c *filterConditions) match(v string) bool {
+	for _, criteria := range c.criteria {
+		ok := false
+		switch criteria {
+		case "=Blanks":
+			ok = v == ""
+		case "<>Blanks":
+			ok = v != ""
+		default:
+			ok = matchCriteria(v, criteria)
+		}
+		if ok != c.and {
+			return ok
+		}
+	}
+	return c.and
+}
+
+var filterTokenReg = regexp.MustCompile(`"(?:[^"]|"")*"|\S+`)
+
+// parseFilterCriteria parses the criteria like "B > 2000" or "B == East or B == West".
+// It returns the column, the expression of excelize like "x > 2000" and the conditions to hide the rows.
+func parseFilterCriteria(criteria string) (col string, expr string, conds *filterConditions, err error) {
+	tokens := filterTokenReg.FindAllString(criteria, -1)
+	if len(tokens) == 0 {
+		return "", "", nil, nil
+	}
+	if len(tokens) != 3 && len(tokens) != 7 {
+		return "", "", nil, fmt.Errorf("'%s' is invalid criteria", criteria)
+	}
+
+	col = strings.ToUpper(tokens[0])
+	if _, err := excelize.ColumnNameToNumber(col); err != nil {
+		return "", "", nil, fmt.Errorf("'%s' is invalid column of criteria", tokens[0])
+	}
+	conds = &filterConditions{}
+	if len(tokens) == 7 {
+		switch strings.ToLower(tokens[3]) {
+		case "and", "&&":
+			conds.and = true
+		case "or", "||":
+		default:
+			return "", "", nil, fmt.Errorf("'%s' is invalid criteria", criteria)
+		}
+		tokens[3] = strings.ToLower(tokens[3])
+	}
+
+	ops := map[string]string{"==": "=", "=": "=", "!=": "<>", "<>": "<>", "<": "<", "<=": "<=", ">": ">", ">=": ">="}
+	for i := 0; i < len(tokens); i += 4 {
+		if !strings.EqualFold(tokens[i], col) {
+			return "", "", nil, fmt.Errorf("'%s' is invalid criteria", criteria)
+		}
+		op, ok := ops[tokens[i+1]]
+		if !ok {
+			return "", "", nil, fmt.Errorf("'%s' is invalid operator of criteria", tokens[i+1])
+		}
+		v := tokens[i+2]
+		if 2 <= len(v) && v[0] == '"' && v[len(v)-1] == '"' {
+			v = strings.ReplaceAll(v[1:len(v)-1], `""`, `"`)
+		}
+		tokens[i] = "x"
+		conds.criteria = append(conds.criteria, op+v)
+	}
+	return col, strings.Join(tokens, " "), conds, nil
+}
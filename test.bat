@@ -60,3 +60,59 @@ if %ERRORLEVEL% neq 6 (
   echo "option -f with file specify could not working"
   exit /B 1
 )
+
+cell.exe -from test/list.xlsx -from other=test/list.xlsx "@@='other';exit(['A2'] eq ['main:A2'])"
+if %ERRORLEVEL% neq 1 (
+  @echo on
+  echo "option -from with workbook name could not working"
+  exit /B 1
+)
+
+cell.exe -from test/[ls]*.xlsx -N "ENDFILE { if (FILENUM == 2) exit(n) ; } n++"
+if %ERRORLEVEL% neq 8 (
+  @echo on
+  echo "option -from with multiple workbooks could not working"
+  exit /B 1
+)
+
+cell.exe "setdate('A1', 'abc')" 2>NUL
+if %ERRORLEVEL% neq 1 (
+  @echo on
+  echo "setdate() accepts the time which is not a number"
+  exit /B 1
+)
+
+cell.exe "if (0) { function f() { return 1; } } puts(f())" 2>NUL
+if %ERRORLEVEL% neq 1 (
+  @echo on
+  echo "the function in the block which is not run is defined"
+  exit /B 1
+)
+
+cell.exe "a[1] = 1; a = 3" 2>NUL
+if %ERRORLEVEL% neq 1 (
+  @echo on
+  echo "the array is replaced by a scalar"
+  exit /B 1
+)
+
+cell.exe "a[1] = 1; b = a" 2>NUL
+if %ERRORLEVEL% neq 1 (
+  @echo on
+  echo "the array is assigned to a variable"
+  exit /B 1
+)
+
+cell.exe "x = 'foo'; split('p q', x)" 2>NUL
+if %ERRORLEVEL% neq 1 (
+  @echo on
+  echo "split() accepts the value which is neither an array nor a cell"
+  exit /B 1
+)
+
+cell.exe "['A1:A2'] += 1" 2>NUL
+if %ERRORLEVEL% neq 1 (
+  @echo on
+  echo "the compound assignment to the range is accepted"
+  exit /B 1
+)
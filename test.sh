@@ -55,3 +55,51 @@ if [[ $? -ne 6 ]]; then
   echo 'option -f with file specify could not working'
   exit 1
 fi
+
+./cell -from test/list.xlsx -from other=test/list.xlsx '@@="other";exit(["A2"] eq ["main:A2"])'
+if [[ $? -ne 1 ]]; then
+  echo 'option -from with workbook name could not working'
+  exit 1
+fi
+
+./cell -from 'test/[ls]*.xlsx' -N 'ENDFILE { if (FILENUM == 2) exit(n) ; } n++'
+if [[ $? -ne 8 ]]; then
+  echo 'option -from with multiple workbooks could not working'
+  exit 1
+fi
+
+./cell 'setdate("A1", "abc")' 2>/dev/null
+if [[ $? -ne 1 ]]; then
+  echo 'setdate() accepts the time which is not a number'
+  exit 1
+fi
+
+./cell 'if (0) { function f() { return 1; } } puts(f())' 2>/dev/null
+if [[ $? -ne 1 ]]; then
+  echo 'the function in the block which is not run is defined'
+  exit 1
+fi
+
+./cell 'a[1] = 1; a = 3' 2>/dev/null
+if [[ $? -ne 1 ]]; then
+  echo 'the array is replaced by a scalar'
+  exit 1
+fi
+
+./cell 'a[1] = 1; b = a' 2>/dev/null
+if [[ $? -ne 1 ]]; then
+  echo 'the array is assigned to a variable'
+  exit 1
+fi
+
+./cell 'x = "foo"; split("p q", x)' 2>/dev/null
+if [[ $? -ne 1 ]]; then
+  echo 'split() accepts the value which is neither an array nor a cell'
+  exit 1
+fi
+
+./cell '["A1:A2"] += 1' 2>/dev/null
+if [[ $? -ne 1 ]]; then
+  echo 'the compound assignment to the range is accepted'
+  exit 1
+fi
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/360EntSecGroup-Skylar/excelize/v2"
+)
+
+// The data validation is specified by a rule like "list Yes,No", "list =$E$1:$E$3", "whole between 1 and 100",
+// "decimal >= 0", "textlength <= 10", "date >= 2020-04-01", "time between 9:00 and 18:00" or "custom =A1<>B1".
+// The messages are specified by the options in the syntax of the style like "input='Enter 1 to 100';error-style=warning".
+
+var validationTypes = map[string]string{
+	"whole":      "whole",
+	"decimal":    "decimal",
+	"textlength": "textLength",
+	"date":       "date",
+	"time":       "time",
+}
+
+var validationOperators = map[string]string{
+	"between":     "between",
+	"not between": "notBetween",
+	"==":          "equal",
+	"!=":          "notEqual",
+	"<>":          "notEqual",
+	">":           "greaterThan",
+	">=":          "greaterThanOrEqual",
+	"<":           "lessThan",
+	"<=":          "lessThanOrEqual",
+}
+
+// parseValidationRule parses the rule of the data validation.
+func (s *Spreadsheet) parseValidationRule(rule string) (*excelize.DataValidation, error) {
+	dv := excelize.NewDataValidation(true)
+	// Excel shows the error message by default
+	dv.ShowErrorMessage = true
+
+	rule = strings.TrimSpace(rule)
+	fields := strings.Fields(rule)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("empty rule")
+	}
+	kind := strings.ToLower(fields[0])
+	rest := strings.TrimSpace(rule[len(fields[0]):])
+
+	switch kind {
+	case "list":
+		if rest == "" {
+			return nil, fmt.Errorf("'%s' needs the items", rule)
+		}
+		dv.Type = "list"
+		if strings.HasPrefix(rest, "=") {
+			dv.Formula1 = validationFormula("formula1", rest[1:])
+		} else {
+			items := strings.Split(rest, ",")
+			for i, item := range items {
+				items[i] = strings.TrimSpace(item)
+			}
+			list := strings.Join(items, ",")
+			if 255 < len(list) {
+				return nil, fmt.Errorf("the items of list must be 0-255 characters")
+			}
+			dv.Formula1 = validationFormula("formula1", `"`+strings.ReplaceAll(list, `"`, `""`)+`"`)
+		}
+		return dv, nil
+	case "custom":
+		if !strings.HasPrefix(rest, "=") {
+			return nil, fmt.Errorf("'%s' needs a formula like 'custom =A1<>B1'", rule)
+		}
+		dv.Type = "custom"
+		dv.Formula1 = validationFormula("formula1", rest[1:])
+		return dv, nil
+	}
+
+	t, ok := validationTypes[kind]
+	if !ok {
+		return nil, fmt.Errorf("'%s' is invalid type of validation", fields[0])
+	}
+	dv.Type = t
+
+	op := ""
+	for _, o := range []string{"not between", "between", ">=", "<=", "<>", "!=", "==", ">", "<"} {
+		if len(o) <= len(rest) && strings.EqualFold(rest[:len(o)], o) {
+			op = o
+			break
+		}
+	}
+	if op == "" {
+		return nil, fmt.Errorf("'%s' needs an operator like 'between' or '>='", rule)
+	}
+	dv.Operator = validationOperators[op]
+	rest = strings.TrimSpace(rest[len(op):])
+
+	values := []string{rest}
+	if strings.HasSuffix(op, "between") {
+		i := strings.Index(strings.ToLower(rest), " and ")
+		if i < 0 {
+			return nil, fmt.Errorf("'%s' needs 2 values like 'between 1 and 10'", rule)
+		}
+		values = []string{rest[:i], rest[i+5:]}
+	}
+	for i, v := range values {
+		f, err := s.validationValue(kind, strings.TrimSpace(v))
+		if err != nil {
+			return nil, err
+		}
+		tag := "formula" + strconv.Itoa(i+1)
+		if i == 0 {
+			dv.Formula1 = validationFormula(tag, f)
+		} else {
+			dv.Formula2 = validationFormula(tag, f)
+		}
+	}
+	return dv, nil
+}
+
+// validationValue returns the value of the rule as a formula.
+// A date like "2020-04-01" and a time like "9:00" are converted to the serial number.
+func (s *Spreadsheet) validationValue(kind string, v string) (string, error) {
+	if strings.HasPrefix(v, "=") {
+		return v[1:], nil
+	}
+	if _, ok := maybeNumber(v); ok {
+		return v, nil
+	}
+	switch kind {
+	case "date":
+		for _, layout := range []string{"2006-01-02", "2006/01/02", "2006-01-02 15:04:05", "2006/01/02 15:04:05"} {
+			if t, err := time.Parse(layout, v); err == nil {
+				return strconv.FormatFloat(s.timeToSerial(t), 'f', -1, 64), nil
+			}
+		}
+		return "", fmt.Errorf("'%s' is invalid date", v)
+	case "time":
+		for _, layout := range []string{"15:04", "15:04:05"} {
+			if t, err := time.Parse(layout, v); err == nil {
+				d := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+				return strconv.FormatFloat(d.Hours()/24, 'f', -1, 64), nil
+			}
+		}
+		return "", fmt.Errorf("'%s' is invalid time", v)
+	}
+	return "", fmt.Errorf("'%s' is not a number", v)
+}
+
+var validationFormulaReg = regexp.MustCompile(`<(formula[12])>(.*?)</formula[12]>`)
+
+// validationFormula returns the inner XML of the formula element for excelize.
+func validationFormula(tag string, formula string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(formula))
+	return "<" + tag + ">" + b.String() + "</" + tag + ">"
+}
+
+// applyValidationOptions sets the options like "input='Enter 1 to 100';input-title=Amount;error-style=warning".
+func applyValidationOptions(dv *excelize.DataValidation, options string) error {
+	for _, item := range splitStyleSpec(options) {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		key, value := item, ""
+		if i := strings.IndexByte(item, '='); i >= 0 {
+			key = strings.TrimSpace(item[:i])
+			value = unquoteStyleValue(strings.TrimSpace(item[i+1:]))
+		}
+		v := value
+		switch strings.ToLower(key) {
+		case "input":
+			dv.ShowInputMessage = true
+			dv.Prompt = &v
+		case "input-title":
+			dv.ShowInputMessage = true
+			dv.PromptTitle = &v
+		case "error":
+			dv.Error = &v
+		case "error-title":
+			dv.ErrorTitle = &v
+		case "error-style":
+			switch strings.ToLower(v) {
+			case "stop", "warning", "information":
+				v = strings.ToLower(v)
+				dv.ErrorStyle = &v
+			case "none":
+				dv.ShowErrorMessage = false
+			default:
+				return fmt.Errorf("'%s' is invalid error style", value)
+			}
+		case "blank":
+			if value == "" {
+				value = "true"
+			}
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("'%s' is invalid value for blank", value)
+			}
+			dv.AllowBlank = b
+		case "dropdown":
+			if value == "" {
+				value = "true"
+			}
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("'%s' is invalid value for dropdown", value)
+			}
+			// the attribute of Excel is inverted
+			dv.ShowDropDown = !b
+		default:
+			return fmt.Errorf("'%s' is unknown option of validation", key)
+		}
+	}
+	return nil
+}
+
+// addSheetValidation adds the data validation of the rule to the range.
+func (s *Spreadsheet) addSheetValidation(r *Range, rule string, options string) error {
+	dv, err := s.parseValidationRule(rule)
+	if err != nil {
+		return err
+	}
+	if err := applyValidationOptions(dv, options); err != nil {
+		return err
+	}
+	dv.Sqref = r.sqref()
+	return s.file.AddDataValidation(r.sheet, dv)
+}
+
+// adjustSheetValidations adjusts the ranges and the formulas of the data validations of the sheet
+// for inserting(offset > 0) or deleting(offset < 0) the rows or the columns at num.
+// The data validations of the deleted ranges are removed.
+func (s *Spreadsheet) adjustSheetValidations(sheet string, rows bool, num int, offset int) {
+	ws := s.file.Sheet[s.getSheetParts()[sheet]]
+	if ws == nil || ws.DataValidations == nil {
+		return
+	}
+	list := ws.DataValidations.DataValidation[:0]
+	for _, dv := range ws.DataValidations.DataValidation {
+		if dv.Sqref = adjustSqref(dv.Sqref, sheet, rows, num, offset); dv.Sqref == "" {
+			continue
+		}
+		adjust := func(inner string) string {
+			return validationFormulaReg.ReplaceAllStringFunc(inner, func(e string) string {
+				m := validationFormulaReg.FindStringSubmatch(e)
+				return validationFormula(m[1], adjustFormula(html.UnescapeString(m[2]), sheet, sheet, rows, num, offset))
+			})
+		}
+		dv.Formula1, dv.Formula2 = adjust(dv.Formula1), adjust(dv.Formula2)
+		list = append(list, dv)
+	}
+	ws.DataValidations.DataValidation = list
+	ws.DataValidations.Count = len(list)
+	if len(list) == 0 {
+		ws.DataValidations = nil
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// MainBookName is the name of the workbook given by -from (or created when it is omitted).
+const MainBookName = "main"
+
+// namedBook is a workbook given by '-from name=path'.
+type namedBook struct {
+	name string
+	path string
+}
+
+var bookNameReg = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func isValidBookName(name string) bool {
+	return bookNameReg.MatchString(name)
+}
+
+// splitNamedPath splits 'name=path'.
+// ok is false if v is just a path.
+func splitNamedPath(v string) (name string, path string, ok bool) {
+	for i := 0; i < len(v); i++ {
+		if v[i] == '=' {
+			if isValidBookName(v[:i]) {
+				return v[:i], v[i+1:], true
+			}
+			break
+		}
+	}
+	return "", v, false
+}
+
+// openBook opens the workbook as the name.
+// If path is empty, an empty workbook is created.
+func openBook(name string, path string) error {
+	if !isValidBookName(name) {
+		return fmt.Errorf("'%s' is invalid workbook name", name)
+	}
+	if _, ok := execContext.books[name]; ok {
+		return fmt.Errorf("workbook '%s' is already open", name)
+	}
+	if path != "" && !fileExist(path) {
+		return fmt.Errorf("file '%s' is not found", path)
+	}
+
+	s, err := NewSpreadsheet(path, "")
+	if err != nil {
+		return err
+	}
+	execContext.books[name] = s
+	return nil
+}
+
+// closeBook closes the workbook without saving.
+// If it is the current workbook, the main workbook becomes current.
+func closeBook(name string) error {
+	if name == MainBookName {
+		return fmt.Errorf("workbook '%s' can not be closed", name)
+	}
+	if _, ok := execContext.books[name]; !ok {
+		return fmt.Errorf("workbook '%s' is not open", name)
+	}
+	if execContext.bookName == name {
+		switchBook(MainBookName)
+	}
+	delete(execContext.books, name)
+	return nil
+}
+
+// switchBook changes the current workbook.
+func switchBook(name string) error {
+	s, ok := execContext.books[name]
+	if !ok {
+		return fmt.Errorf("workbook '%s' is not open", name)
+	}
+	execContext.spreadsheet = s
+	execContext.bookName = name
+	return nil
+}